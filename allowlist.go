@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// allowed reports whether a user or chat ID is on the allowlist, either from
+// TELEGRAM_ALLOWLIST or added at runtime with /allow.
+func allowed(config *Config, store *Store, id int64) bool {
+	for _, a := range config.Allowlist {
+		if a == id {
+			return true
+		}
+	}
+	return store.Allowed[id]
+}
+
+// allowCommand lists, adds or removes runtime allowlist entries:
+// "/allow", "/allow <id>" or "/allow remove <id>".
+func allowCommand(store *Store, args string) string {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		if len(store.Allowed) == 0 {
+			return "Nobody has been allowed. Usage: /allow [remove] <user or chat id>"
+		}
+		var ids []string
+		for id := range store.Allowed {
+			ids = append(ids, strconv.FormatInt(id, 10))
+		}
+		sort.Strings(ids)
+		return "Allowed: " + strings.Join(ids, ", ")
+	}
+	remove := fields[0] == "remove"
+	if remove {
+		fields = fields[1:]
+	}
+	if len(fields) != 1 {
+		return "Usage: /allow [remove] <user or chat id>"
+	}
+	id, err := strconv.ParseInt(fields[0], 10, 64)
+	if err != nil {
+		return fmt.Sprintf("⚠️ Invalid id %q", fields[0])
+	}
+	if remove {
+		delete(store.Allowed, id)
+		return fmt.Sprintf("🚫 %d removed from the allowlist", id)
+	}
+	store.Allowed[id] = true
+	return fmt.Sprintf("✅ %d added to the allowlist", id)
+}
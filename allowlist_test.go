@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllowed(t *testing.T) {
+	store := &Store{}
+	store.init()
+	config := &Config{Allowlist: []int64{-100}}
+	assert.True(t, allowed(config, store, -100))
+	assert.False(t, allowed(config, store, 42))
+	store.Allowed[42] = true
+	assert.True(t, allowed(config, store, 42))
+}
+
+func TestAllowCommand(t *testing.T) {
+	store := &Store{}
+	store.init()
+	assert.Equal(t, "Nobody has been allowed. Usage: /allow [remove] <user or chat id>", allowCommand(store, ""))
+	assert.Equal(t, "✅ 42 added to the allowlist", allowCommand(store, "42"))
+	assert.Equal(t, "✅ -100 added to the allowlist", allowCommand(store, "-100"))
+	assert.Equal(t, "Allowed: -100, 42", allowCommand(store, ""))
+	assert.Equal(t, "🚫 42 removed from the allowlist", allowCommand(store, "remove 42"))
+	assert.Equal(t, map[int64]bool{-100: true}, store.Allowed)
+	assert.Equal(t, `⚠️ Invalid id "me"`, allowCommand(store, "me"))
+	assert.Equal(t, "Usage: /allow [remove] <user or chat id>", allowCommand(store, "remove"))
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Units accepted after numbers in commands, and what they normalise to.
+var quantityUnits = map[string]string{
+	"":      "",
+	"%":     "%",
+	"km":    "km",
+	"mi":    "mi",
+	"mile":  "mi",
+	"miles": "mi",
+}
+
+// parseQuantity parses a number typed in a command, e.g. "80", "70,5",
+// "80%", "30 miles" or "50km". Either a comma or a point is taken as the
+// decimal separator, and if both are present the last one is. Distances
+// are returned in km.
+func parseQuantity(value string) (float64, string, error) {
+	value = strings.TrimSpace(value)
+	i := strings.LastIndexFunc(value, func(r rune) bool { return unicode.IsDigit(r) || r == '.' || r == ',' }) + 1
+	number, suffix := value[:i], strings.ToLower(strings.TrimSpace(value[i:]))
+	unit, ok := quantityUnits[suffix]
+	if !ok {
+		return 0, "", fmt.Errorf("unknown unit %q", suffix)
+	}
+	if dot, comma := strings.LastIndex(number, "."), strings.LastIndex(number, ","); comma > dot {
+		number = strings.ReplaceAll(number, ".", "")
+		number = strings.Replace(number, ",", ".", 1)
+	} else {
+		number = strings.ReplaceAll(number, ",", "")
+	}
+	f, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid number %q", value)
+	}
+	if unit == "mi" {
+		f, unit = f*KMPerMile, "km"
+	}
+	return f, unit, nil
+}
+
+// parseCount parses a whole number of things, such as a number of places.
+func parseCount(value string) (int, error) {
+	f, unit, err := parseQuantity(value)
+	if err != nil || unit != "" || f != math.Trunc(f) {
+		return 0, fmt.Errorf("invalid count %q", value)
+	}
+	return int(f), nil
+}
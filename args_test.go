@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuantity(t *testing.T) {
+	for value, want := range map[string]struct {
+		f    float64
+		unit string
+	}{
+		"80":       {80, ""},
+		"80%":      {80, "%"},
+		"70,5":     {70.5, ""},
+		"70.5":     {70.5, ""},
+		"1.234,5":  {1234.5, ""},
+		"1,234.5":  {1234.5, ""},
+		"50km":     {50, "km"},
+		"50 KM":    {50, "km"},
+		"30 miles": {30 * KMPerMile, "km"},
+	} {
+		f, unit, err := parseQuantity(value)
+		assert.NoError(t, err, value)
+		assert.InDelta(t, want.f, f, 0.001, value)
+		assert.Equal(t, want.unit, unit, value)
+	}
+	_, _, err := parseQuantity("30 furlongs")
+	assert.EqualError(t, err, `unknown unit "furlongs"`)
+	_, _, err = parseQuantity("lots")
+	assert.EqualError(t, err, `unknown unit "lots"`)
+	_, _, err = parseQuantity("%")
+	assert.EqualError(t, err, `invalid number "%"`)
+}
+
+func TestParseCount(t *testing.T) {
+	n, err := parseCount("10")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+	_, err = parseCount("2,5")
+	assert.EqualError(t, err, `invalid count "2,5"`)
+	_, err = parseCount("5%")
+	assert.EqualError(t, err, `invalid count "5%"`)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"log"
+	"math/rand"
+	"time"
+)
+
+// Backoff produces exponentially increasing delays with jitter, so a fleet
+// of clients doesn't retry in lockstep after a broker outage.
+type Backoff struct {
+	Min     time.Duration
+	Max     time.Duration
+	attempt int
+}
+
+func (b *Backoff) Next() time.Duration {
+	d := b.Min << uint(b.attempt)
+	if d > b.Max || d <= 0 {
+		d = b.Max
+	} else {
+		b.attempt++
+	}
+	// jitter in [d/2, d)
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// retry calls fn until it succeeds or giveUp returns true, sleeping with
+// backoff between attempts.
+func retry(what string, fn func() error, giveUp func() bool) error {
+	backoff := Backoff{Min: time.Second, Max: 2 * time.Minute}
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if giveUp != nil && giveUp() {
+			return err
+		}
+		delay := backoff.Next()
+		log.Printf("Failed to %s (attempt %d): %s, retrying in %s", what, attempt, err, delay.Round(time.Millisecond))
+		time.Sleep(delay)
+	}
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 10 * time.Second}
+	for _, want := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		d := b.Next()
+		assert.True(t, d >= want*time.Second/2 && d <= want*time.Second, "got %s want ~%ds", d, want)
+	}
+	b.Reset()
+	assert.True(t, b.Next() <= time.Second)
+}
+
+func TestRetryGiveUp(t *testing.T) {
+	calls := 0
+	err := retry("test", func() error {
+		calls++
+		return errors.New("broker down")
+	}, func() bool { return true })
+	assert.EqualError(t, err, "broker down")
+	assert.Equal(t, 1, calls)
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// BatterySample is the rated range a full battery would give, projected
+// from the end of a charge.
+type BatterySample struct {
+	At          time.Time `json:"at"`
+	FullRangeKm float32   `json:"full_range_km"`
+}
+
+// Lowest level a charge can finish at to project the full range from.
+// Below it a percent of rounding is too large a share.
+const MinProjectLevel = 50
+
+// Samples kept per car, a few years of daily charging.
+const MaxBatterySamples = 1000
+
+// Samples by car ID, persisted in the store.
+var batteryHistory = map[int][]BatterySample{}
+
+// Rated range of the battery when new, from BATTERY_NEW_RANGE_KM, to measure
+// degradation against. 0 if unknown.
+var newRangeKm float32
+
+// recordBattery samples the full range at the end of a charge, keeping the
+// last of each day. It reports whether a sample was taken.
+func (car *Car) recordBattery(s CarState) bool {
+	if s.BatteryLevel < MinProjectLevel || s.RatedBatteryRangeKm == 0 {
+		return false
+	}
+	sample := BatterySample{At: s.At, FullRangeKm: s.RatedBatteryRangeKm * 100 / float32(s.BatteryLevel)}
+	samples := batteryHistory[car.id]
+	if n := len(samples); n > 0 && sameDay(samples[n-1].At, s.At) {
+		samples = samples[:n-1]
+	}
+	samples = append(samples, sample)
+	if len(samples) > MaxBatterySamples {
+		samples = samples[len(samples)-MaxBatterySamples:]
+	}
+	batteryHistory[car.id] = samples
+	return true
+}
+
+func sameDay(a, b time.Time) bool {
+	y1, m1, d1 := a.In(location).Date()
+	y2, m2, d2 := b.In(location).Date()
+	return y1 == y2 && m1 == m2 && d1 == d2
+}
+
+// fullRange averages the samples over the month to now, smoothing out the
+// BMS's day to day estimates.
+func fullRange(samples []BatterySample, now time.Time) (float32, bool) {
+	var total float32
+	var n int
+	for _, s := range samples {
+		if s.At.After(now.AddDate(0, -1, 0)) && !s.At.After(now) {
+			total += s.FullRangeKm
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return total / float32(n), true
+}
+
+// degradation is the percentage of range lost since new, as of the latest
+// sample.
+func degradation(carID int) (float32, bool) {
+	samples := batteryHistory[carID]
+	if len(samples) == 0 || newRangeKm == 0 {
+		return 0, false
+	}
+	full, _ := fullRange(samples, samples[len(samples)-1].At)
+	return (1 - full/newRangeKm) * 100, true
+}
+
+// batteryMessage answers /battery with the projected full range, its monthly
+// averages over the last six months and the degradation since new.
+func batteryMessage(car *Car, now time.Time) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	samples := batteryHistory[car.id]
+	full, ok := fullRange(samples, now)
+	if !ok {
+		return fmt.Sprintf("🔋 No recent charges to estimate battery health from. Charges finishing at %d%% or more are used.", MinProjectLevel)
+	}
+	text := fmt.Sprintf("🔋 Battery health\nAt 100%%: %.0f %s", distance(full), distanceUnit())
+	var months []string
+	for i := 5; i >= 0; i-- {
+		end := now.AddDate(0, -i, 0)
+		if average, ok := fullRange(samples, end); ok {
+			months = append(months, fmt.Sprintf("%s %.0f", end.In(location).Format("Jan"), distance(average)))
+		}
+	}
+	if len(months) > 1 {
+		text += "\nTrend: " + strings.Join(months, " → ") + " " + distanceUnit()
+	}
+	if lost, ok := degradation(car.id); ok {
+		text += fmt.Sprintf("\nDegradation: %.1f%% from %.0f %s new", lost, distance(newRangeKm), distanceUnit())
+	}
+	return text
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatteryMessage(t *testing.T) {
+	defer func(h map[int][]BatterySample, r float32) { batteryHistory, newRangeKm = h, r }(batteryHistory, newRangeKm)
+	batteryHistory = map[int][]BatterySample{}
+	now := time.Date(2021, 4, 9, 12, 0, 0, 0, time.UTC)
+	car := &Car{id: 1}
+	assert.Equal(t, "🔋 No recent charges to estimate battery health from. Charges finishing at 50% or more are used.", batteryMessage(car, now))
+
+	assert.False(t, car.recordBattery(CarState{At: now, BatteryLevel: 30, RatedBatteryRangeKm: 150}))
+	assert.True(t, car.recordBattery(CarState{At: now.AddDate(0, -2, 0), BatteryLevel: 80, RatedBatteryRangeKm: 520}))
+	assert.True(t, car.recordBattery(CarState{At: now.AddDate(0, 0, -1), BatteryLevel: 80, RatedBatteryRangeKm: 510}))
+	assert.True(t, car.recordBattery(CarState{At: now.Add(-time.Hour), BatteryLevel: 90, RatedBatteryRangeKm: 560}))
+	assert.True(t, car.recordBattery(CarState{At: now, BatteryLevel: 90, RatedBatteryRangeKm: 567}))
+	assert.Len(t, batteryHistory[1], 3) // one a day
+	assert.Equal(t, "🔋 Battery health\nAt 100%: 394 miles\nTrend: Feb 404 → Apr 394 miles", batteryMessage(car, now))
+
+	newRangeKm = 660
+	assert.Contains(t, batteryMessage(car, now), "\nDegradation: 4.0% from 410 miles new")
+	assert.Contains(t, handoverMessage(car), "\nDegradation: 4.0%\n")
+	car.wipe()
+	assert.Empty(t, batteryHistory[1])
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// BotUpdate is an update along with the bot that received it, so replies go
+// back through the same bot.
+type BotUpdate struct {
+	tgbotapi.Update
+	bot *tgbotapi.BotAPI
+}
+
+// startBots connects each of the bots, which share one MQTT connection and
+// store, and merges their updates. The first bot is the default.
+func startBots(tokens []string, watchdog *Watchdog) ([]*tgbotapi.BotAPI, <-chan BotUpdate, error) {
+	if len(tokens) == 0 {
+		return nil, nil, fmt.Errorf("TELEGRAM_TOKEN is not set")
+	}
+	var bots []*tgbotapi.BotAPI
+	updates := make(chan BotUpdate)
+	for _, token := range tokens {
+		bot, err := tgbotapi.NewBotAPI(token)
+		if err != nil {
+			return nil, nil, err
+		}
+		log.Printf("Telegram authorized on account %s", bot.Self.UserName)
+		if err := setCommands(bot); err != nil {
+			log.Println("Failed to register commands:", err)
+		}
+		// a request outliving the long poll is hung, so give up and retry
+		bot.Client = &http.Client{Timeout: (PollTimeout + 15) * time.Second}
+		watchdog.Watch(bot.Self.UserName)
+		go pollUpdates(bot, updates, watchdog)
+		bots = append(bots, bot)
+	}
+	return bots, updates, nil
+}
+
+// pollUpdates long polls a bot's updates forever, retrying with backoff
+// after errors and reporting each poll to the watchdog.
+func pollUpdates(bot *tgbotapi.BotAPI, updates chan<- BotUpdate, watchdog *Watchdog) {
+	backoff := Backoff{Min: time.Second, Max: time.Minute}
+	offset := 0
+	for {
+		u := tgbotapi.NewUpdate(offset)
+		u.Timeout = PollTimeout
+		batch, err := bot.GetUpdates(u)
+		if err != nil {
+			watchdog.Failed(bot.Self.UserName)
+			delay := backoff.Next()
+			log.Printf("Failed to get updates for %s: %s, retrying in %s", bot.Self.UserName, err, delay.Round(time.Millisecond))
+			time.Sleep(delay)
+			continue
+		}
+		backoff.Reset()
+		if failures := watchdog.Polled(bot.Self.UserName, time.Now()); failures > 0 {
+			log.Printf("Updates for %s recovered after %d failures", bot.Self.UserName, failures)
+		}
+		for _, update := range batch {
+			if update.UpdateID >= offset {
+				offset = update.UpdateID + 1
+				updates <- BotUpdate{update, bot}
+			}
+		}
+	}
+}
+
+// parseBotRoutes parses TELEGRAM_BOT_ROUTES, e.g. "family_bot=123,456",
+// mapping bot usernames to the chats they deliver to.
+func parseBotRoutes(value string) (map[string][]int64, error) {
+	routes := map[string][]int64{}
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ' ' }) {
+		i := strings.Index(entry, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("expected bot=chat_ids, got %q", entry)
+		}
+		ids, err := parseChatIDs(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		routes[strings.TrimPrefix(entry[:i], "@")] = ids
+	}
+	return routes, nil
+}
+
+// botName picks the bot that delivers to a chat: one routed to it, else the
+// one the chat last wrote to, else the default.
+func botName(routes map[string][]int64, used map[int64]string, chatID int64, fallback string) string {
+	for name, chats := range routes {
+		for _, id := range chats {
+			if id == chatID {
+				return name
+			}
+		}
+	}
+	if name, ok := used[chatID]; ok {
+		return name
+	}
+	return fallback
+}
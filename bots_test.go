@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBotRoutes(t *testing.T) {
+	routes, err := parseBotRoutes("@family_bot=123,456;work_bot=789")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]int64{"family_bot": {123, 456}, "work_bot": {789}}, routes)
+
+	_, err = parseBotRoutes("family_bot")
+	assert.EqualError(t, err, `expected bot=chat_ids, got "family_bot"`)
+}
+
+func TestBotName(t *testing.T) {
+	routes := map[string][]int64{"family_bot": {123}}
+	used := map[int64]string{123: "work_bot", 456: "work_bot"}
+	assert.Equal(t, "family_bot", botName(routes, used, 123, "main_bot"))
+	assert.Equal(t, "work_bot", botName(routes, used, 456, "main_bot"))
+	assert.Equal(t, "main_bot", botName(routes, used, 789, "main_bot"))
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Battery level to arrive with on a calendar trip, %.
+const TripReserve = 10
+
+// calendarEvent is a VEVENT from an iCalendar feed.
+type calendarEvent struct {
+	start       time.Time
+	summary     string
+	description string
+}
+
+var icalText = strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`)
+
+// parseICal reads the events from an iCalendar feed. Only the start,
+// summary and description are kept.
+func parseICal(r io.Reader) ([]calendarEvent, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if n := len(lines); n > 0 && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) {
+			lines[n-1] += line[1:] // folded onto the next line
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	var events []calendarEvent
+	var event *calendarEvent
+	for _, line := range lines {
+		i := strings.Index(line, ":")
+		if i == -1 {
+			continue
+		}
+		params, value := strings.Split(line[:i], ";"), line[i+1:]
+		switch name := strings.ToUpper(params[0]); {
+		case name == "BEGIN" && value == "VEVENT":
+			event = &calendarEvent{}
+		case event == nil:
+		case name == "END" && value == "VEVENT":
+			events = append(events, *event)
+			event = nil
+		case name == "DTSTART":
+			start, err := parseICalTime(params[1:], value)
+			if err != nil {
+				return nil, err
+			}
+			event.start = start
+		case name == "SUMMARY":
+			event.summary = icalText.Replace(value)
+		case name == "DESCRIPTION":
+			event.description = icalText.Replace(value)
+		}
+	}
+	return events, nil
+}
+
+// parseICalTime parses a DTSTART value, in UTC, its TZID or local time.
+// All-day events start at midnight.
+func parseICalTime(params []string, value string) (time.Time, error) {
+	loc := location
+	for _, param := range params {
+		if strings.HasPrefix(param, "TZID=") {
+			if l, err := time.LoadLocation(strings.Trim(param[5:], `"`)); err == nil {
+				loc = l
+			}
+		}
+	}
+	switch {
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	case len(value) == 8:
+		return time.ParseInLocation("20060102", value, loc)
+	}
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+func calendarLookup(url string) ([]calendarEvent, error) {
+	// fetched by the scheduler on the main loop, so a hung host mustn't
+	// hold it up
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("calendar returned %s", resp.Status)
+	}
+	return parseICal(resp.Body)
+}
+
+var tripDistanceRE = regexp.MustCompile(`(?i)(\d+(?:[.,]\d+)?)\s*(km|miles|mile|mi)\b`)
+
+// tripDistance returns the distance of a trip event in km, found in its
+// summary or description. Only events with "trip" in the summary count.
+func tripDistance(e calendarEvent) (float64, bool) {
+	if !strings.Contains(strings.ToLower(e.summary), "trip") {
+		return 0, false
+	}
+	for _, text := range []string{e.summary, e.description} {
+		if match := tripDistanceRE.FindString(text); match != "" {
+			if km, _, err := parseQuantity(match); err == nil {
+				return km, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// tripWarnings checks the trips starting the day after now against the
+// battery level the car will have by morning: its charge limit if it's
+// plugged in, otherwise the level now.
+func tripWarnings(car *Car, events []calendarEvent, now time.Time) []string {
+	s := car.State
+	if s.BatteryLevel == 0 || s.RatedBatteryRangeKm == 0 {
+		return nil
+	}
+	kmPerLevel := float64(s.RatedBatteryRangeKm) / float64(s.BatteryLevel)
+	morning := s.BatteryLevel
+	if s.PluggedIn && s.ChargeLimitSoc > morning {
+		morning = s.ChargeLimitSoc
+	}
+	year, month, day := now.In(location).AddDate(0, 0, 1).Date()
+	var warnings []string
+	for _, e := range events {
+		y, m, d := e.start.In(location).Date()
+		if y != year || m != month || d != day {
+			continue
+		}
+		km, ok := tripDistance(e)
+		if !ok {
+			continue
+		}
+		needed := int(math.Ceil(km/kmPerLevel)) + TripReserve
+		if needed <= morning {
+			continue
+		}
+		text := fmt.Sprintf("🗓 %s at %s needs about %d%% (%.0f %s and %d%% to spare), but %s will have %d%%.",
+			e.summary, clock(e.start), needed, distance(float32(km)), distanceUnit(), TripReserve, car.displayName, morning)
+		switch {
+		case needed > 100:
+			text += " Plan a charge on the way."
+		case s.PluggedIn:
+			text += fmt.Sprintf(" Raise the charge limit to %d%%.", needed)
+		default:
+			text += " Plug in tonight."
+		}
+		warnings = append(warnings, text)
+	}
+	return warnings
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testCalendar = "BEGIN:VCALENDAR\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;TZID=Europe/London:20210410T080000\r\n" +
+	"SUMMARY:Trip to Bristol\r\n" +
+	"DESCRIPTION:About 120 miles\\, each way\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART:20210410T100000Z\r\n" +
+	"SUMMARY:Dentist\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"DTSTART;VALUE=DATE:20210411\r\n" +
+	"SUMMARY:Road trip 45\r\n" +
+	" 0km\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseICal(t *testing.T) {
+	events, err := parseICal(strings.NewReader(testCalendar))
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, "Trip to Bristol", events[0].summary)
+	assert.Equal(t, "About 120 miles, each way", events[0].description)
+	assert.Equal(t, time.Date(2021, 4, 10, 7, 0, 0, 0, time.UTC), events[0].start.UTC())
+	assert.Equal(t, time.Date(2021, 4, 10, 10, 0, 0, 0, time.UTC), events[1].start)
+	assert.Equal(t, "Road trip 450km", events[2].summary)
+
+	km, ok := tripDistance(events[0])
+	assert.True(t, ok)
+	assert.InDelta(t, 193.2, km, 0.1)
+	_, ok = tripDistance(events[1])
+	assert.False(t, ok)
+	km, _ = tripDistance(events[2])
+	assert.Equal(t, 450.0, km)
+}
+
+func TestTripWarnings(t *testing.T) {
+	events, err := parseICal(strings.NewReader(testCalendar))
+	assert.NoError(t, err)
+	now := time.Date(2021, 4, 9, 20, 0, 0, 0, time.UTC)
+	car := &Car{displayName: "Snowflake"}
+	car.State = CarState{BatteryLevel: 50, RatedBatteryRangeKm: 200}
+	assert.Equal(t, []string{"🗓 Trip to Bristol at 07:00 needs about 59% (120 miles and 10% to spare), but Snowflake will have 50%. Plug in tonight."}, tripWarnings(car, events, now))
+	car.State.PluggedIn, car.State.ChargeLimitSoc = true, 55
+	assert.Equal(t, []string{"🗓 Trip to Bristol at 07:00 needs about 59% (120 miles and 10% to spare), but Snowflake will have 55%. Raise the charge limit to 59%."}, tripWarnings(car, events, now))
+	car.State.ChargeLimitSoc = 80
+	assert.Empty(t, tripWarnings(car, events, now))
+	assert.Equal(t, []string{"🗓 Road trip 450km at 00:00 needs about 123% (280 miles and 10% to spare), but Snowflake will have 80%. Plan a charge on the way."}, tripWarnings(car, events, now.AddDate(0, 0, 1)))
+}
+
+func TestCalendarConfig(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{"CALENDAR_URL": "https://example.com/cal.ics"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 20*60, c.CalendarCheck)
+	_, err = parseConfig(lookupMap(map[string]string{"CALENDAR_CHECK": "8pm"}))
+	assert.EqualError(t, err, `invalid CALENDAR_CHECK: invalid time "8pm"`)
+}
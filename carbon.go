@@ -0,0 +1,123 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const carbonTimeFormat = "2006-01-02T15:04Z"
+
+// CarbonPeriod is a half-hour period from the National Grid carbon
+// intensity API.
+type CarbonPeriod struct {
+	From      time.Time
+	To        time.Time
+	Intensity int // gCO2/kWh
+}
+
+type carbonResponse struct {
+	Data []struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Intensity struct {
+			Forecast int `json:"forecast"`
+			Actual   int `json:"actual"`
+		} `json:"intensity"`
+	} `json:"data"`
+}
+
+func carbonLookup(path string) ([]CarbonPeriod, error) {
+	// looked up on the main loop, so a stalled API mustn't hold it up
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get("https://api.carbonintensity.org.uk/intensity/" + path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("carbon intensity lookup: %s", resp.Status)
+	}
+	var result carbonResponse
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, err
+	}
+	var periods []CarbonPeriod
+	for _, d := range result.Data {
+		from, err := time.Parse(carbonTimeFormat, d.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := time.Parse(carbonTimeFormat, d.To)
+		if err != nil {
+			return nil, err
+		}
+		intensity := d.Intensity.Actual
+		if intensity == 0 {
+			intensity = d.Intensity.Forecast
+		}
+		periods = append(periods, CarbonPeriod{From: from, To: to, Intensity: intensity})
+	}
+	return periods, nil
+}
+
+// carbonIntensity returns the recorded intensity between from and to.
+func carbonIntensity(from, to time.Time) ([]CarbonPeriod, error) {
+	return carbonLookup(from.UTC().Format(carbonTimeFormat) + "/" + to.UTC().Format(carbonTimeFormat))
+}
+
+// carbonForecast returns the forecast intensity for the next 24 hours.
+func carbonForecast(from time.Time) ([]CarbonPeriod, error) {
+	return carbonLookup(from.UTC().Format(carbonTimeFormat) + "/fw24h")
+}
+
+func averageIntensity(periods []CarbonPeriod) float64 {
+	if len(periods) == 0 {
+		return 0
+	}
+	total := 0
+	for _, p := range periods {
+		total += p.Intensity
+	}
+	return float64(total) / float64(len(periods))
+}
+
+// lowestCarbonWindow finds the run of n consecutive periods with the lowest
+// average intensity. ok is false if there are fewer than n periods.
+func lowestCarbonWindow(periods []CarbonPeriod, n int) (from, to time.Time, average float64, ok bool) {
+	if n <= 0 || len(periods) < n {
+		return
+	}
+	for i := 0; i+n <= len(periods); i++ {
+		avg := averageIntensity(periods[i : i+n])
+		if !ok || avg < average {
+			from, to, average, ok = periods[i].From, periods[i+n-1].To, avg, true
+		}
+	}
+	return
+}
+
+func carbonChargingMessage(start, end CarState) string {
+	periods, err := carbonIntensity(start.At, end.At)
+	if err != nil || len(periods) == 0 {
+		return ""
+	}
+	avg := averageIntensity(periods)
+	co2 := float64(end.ChargeEnergyAdded-start.ChargeEnergyAdded) * avg / 1000
+	return fmt.Sprintf("\n🌍 %.0fgCO2/kWh average (%.1fkg CO2)", avg, co2)
+}
+
+func carbonWindowMessage(now time.Time, hours int) string {
+	periods, err := carbonForecast(now)
+	if err != nil {
+		return ""
+	}
+	from, to, avg, ok := lowestCarbonWindow(periods, hours*2)
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("\n🌍 Lowest carbon window: %s→%s (%.0fgCO2/kWh)",
+		clock(from), clock(to), avg)
+}
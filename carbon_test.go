@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLowestCarbonWindow(t *testing.T) {
+	at := time.Date(2021, 4, 9, 0, 0, 0, 0, time.UTC)
+	var periods []CarbonPeriod
+	for i, intensity := range []int{200, 150, 90, 100, 80, 180} {
+		from := at.Add(time.Duration(i) * 30 * time.Minute)
+		periods = append(periods, CarbonPeriod{From: from, To: from.Add(30 * time.Minute), Intensity: intensity})
+	}
+	from, to, avg, ok := lowestCarbonWindow(periods, 3)
+	assert.True(t, ok)
+	assert.Equal(t, at.Add(time.Hour), from)
+	assert.Equal(t, at.Add(150*time.Minute), to)
+	assert.InDelta(t, 90, avg, 0.01)
+
+	_, _, _, ok = lowestCarbonWindow(periods, 7)
+	assert.False(t, ok)
+}
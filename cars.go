@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Emoji for each car by ID, set with /car set-emoji and persisted in the
+// store. Prefixed to the car's notifications so cars sharing a chat can be
+// told apart.
+var carEmoji = map[int]string{}
+
+// carPrefix prefixes a car's emoji, if it has one, to a message about it.
+func carPrefix(carID int, text string) string {
+	if emoji := carEmoji[carID]; emoji != "" {
+		return emoji + " " + text
+	}
+	return text
+}
+
+// carsMessage answers /cars, marking the car the chat's commands target.
+func carsMessage(cars map[int]*Car, selected int) string {
+	choices := carChoices(cars)
+	if len(choices) == 0 {
+		return "No car data received yet."
+	}
+	text := "🚗 Cars"
+	for _, c := range choices {
+		mark := ""
+		if c.id == selected {
+			mark = " ✅"
+		}
+		text += fmt.Sprintf("\n%d. %s%s", c.id, carPrefix(c.id, c.name), mark)
+	}
+	return text + "\nChoose one with /car <id|name>"
+}
+
+// findCar returns the car with the ID or display name given, ignoring case.
+func findCar(cars map[int]*Car, arg string) (int, error) {
+	if id, err := strconv.Atoi(arg); err == nil {
+		if _, ok := cars[id]; ok {
+			return id, nil
+		}
+	}
+	for _, c := range carChoices(cars) {
+		if strings.EqualFold(c.name, arg) {
+			return c.id, nil
+		}
+	}
+	return 0, fmt.Errorf("no car %q", arg)
+}
+
+// carCommand shows or selects the car a chat's commands target.
+func carCommand(store *Store, cars map[int]*Car, chatID int64, selected int, args string) string {
+	args = strings.TrimSpace(args)
+	if args == "" {
+		return carsMessage(cars, selected)
+	}
+	if fields := strings.Fields(args); fields[0] == "set-emoji" {
+		return setCarEmoji(cars, selected, fields[1:])
+	}
+	id, err := findCar(cars, args)
+	if err != nil {
+		return fmt.Sprintf("⚠️ %s. See /cars", err)
+	}
+	settings, ok := store.Chats[chatID]
+	if !ok {
+		settings = &ChatSettings{}
+		store.Chats[chatID] = settings
+	}
+	settings.CarID = id
+	return "🚗 Now using " + carChoiceName(cars, id)
+}
+
+func carChoiceName(cars map[int]*Car, id int) string {
+	for _, c := range carChoices(cars) {
+		if c.id == id {
+			return c.name
+		}
+	}
+	return ""
+}
+
+// setCarEmoji sets or, with "off", clears the selected car's emoji.
+func setCarEmoji(cars map[int]*Car, selected int, args []string) string {
+	if _, ok := cars[selected]; !ok {
+		return "No car data received yet."
+	}
+	if len(args) != 1 || utf8.RuneCountInString(args[0]) > 8 || strings.ContainsAny(args[0], "<>&") {
+		return "Usage: /car set-emoji <emoji>|off"
+	}
+	if args[0] == "off" {
+		delete(carEmoji, selected)
+		return "🚗 Emoji removed from " + carChoiceName(cars, selected)
+	}
+	carEmoji[selected] = args[0]
+	return carPrefix(selected, carChoiceName(cars, selected)) + " is set"
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCarCommand(t *testing.T) {
+	store := &Store{Chats: map[int64]*ChatSettings{}}
+	cars := map[int]*Car{1: {displayName: "Snowflake"}, 2: {}}
+	assert.Equal(t, "🚗 Cars\n1. Snowflake ✅\n2. Car 2\nChoose one with /car <id|name>", carCommand(store, cars, 10, 1, ""))
+	assert.Equal(t, "🚗 Now using Car 2", carCommand(store, cars, 10, 1, "2"))
+	assert.Equal(t, 2, store.Chats[10].CarID)
+	assert.Equal(t, "🚗 Now using Snowflake", carCommand(store, cars, 10, 2, "snowflake"))
+	assert.Equal(t, 1, store.Chats[10].CarID)
+	assert.Equal(t, "⚠️ no car \"3\". See /cars", carCommand(store, cars, 10, 1, "3"))
+	assert.Equal(t, "No car data received yet.", carsMessage(nil, 0))
+}
+
+func TestCarEmoji(t *testing.T) {
+	defer func(e map[int]string) { carEmoji = e }(carEmoji)
+	carEmoji = map[int]string{}
+	store := &Store{Chats: map[int64]*ChatSettings{}}
+	cars := map[int]*Car{1: {id: 1, displayName: "Snowflake"}, 2: {id: 2}}
+	assert.Equal(t, "🔴 Snowflake is set", carCommand(store, cars, 10, 1, "set-emoji 🔴"))
+	assert.Equal(t, "Usage: /car set-emoji <emoji>|off", carCommand(store, cars, 10, 1, "set-emoji"))
+	assert.Equal(t, "Usage: /car set-emoji <emoji>|off", carCommand(store, cars, 10, 1, "set-emoji <b>"))
+	assert.Equal(t, "🚗 Cars\n1. 🔴 Snowflake ✅\n2. Car 2\nChoose one with /car <id|name>", carsMessage(cars, 1))
+	assert.Equal(t, "🔴 Charging finished", carPrefix(1, "Charging finished"))
+	assert.Equal(t, "Charging finished", carPrefix(2, "Charging finished"))
+	assert.Contains(t, statusMessage(cars[1], nil, time.Now()), "🔴 <b>Snowflake</b>")
+	assert.Equal(t, "🚗 Emoji removed from Snowflake", carCommand(store, cars, 10, 1, "set-emoji off"))
+	assert.Equal(t, "Snowflake", carPrefix(1, "Snowflake"))
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// fullCharge reports whether a charge finished at 100%, as before a road
+// trip.
+func fullCharge(start, end CarState) bool {
+	return start.BatteryLevel < 100 && end.BatteryLevel >= 100
+}
+
+// milestone returns the odometer milestone, in the configured unit, passed
+// between two readings in km, if any. Milestones are multiples of every.
+func milestone(every, fromKm, toKm float32) (float32, bool) {
+	if every <= 0 {
+		return 0, false
+	}
+	passed := float32(math.Floor(float64(distance(toKm)/every))) * every
+	if passed > 0 && distance(fromKm) < passed {
+		return passed, true
+	}
+	return 0, false
+}
+
+func milestoneMessage(name string, odometer float32) string {
+	return fmt.Sprintf("🎉 %s has passed %.0f %s!", name, odometer, distanceUnit())
+}
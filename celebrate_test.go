@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFullCharge(t *testing.T) {
+	assert.True(t, fullCharge(CarState{BatteryLevel: 60}, CarState{BatteryLevel: 100}))
+	assert.False(t, fullCharge(CarState{BatteryLevel: 60}, CarState{BatteryLevel: 90}))
+	assert.False(t, fullCharge(CarState{BatteryLevel: 100}, CarState{BatteryLevel: 100}))
+}
+
+func TestMilestone(t *testing.T) {
+	// 16090km is just under 10,000 miles
+	_, ok := milestone(10000, 16000, 16090)
+	assert.False(t, ok)
+	passed, ok := milestone(10000, 16090, 16100)
+	assert.True(t, ok)
+	assert.Equal(t, float32(10000), passed)
+	_, ok = milestone(0, 16090, 16100)
+	assert.False(t, ok)
+	_, ok = milestone(10000, 0, 10)
+	assert.False(t, ok)
+	assert.Equal(t, "🎉 Snowflake has passed 10000 miles!", milestoneMessage("Snowflake", passed))
+}
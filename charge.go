@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A charge stopping this many percent or more below the limit while still
+// plugged in may have been interrupted.
+const ChargeInterruptedMargin = 5
+
+// chargeInterrupted reports whether a charge stopped unexpectedly well
+// short of the limit with the cable still in, e.g. a tripped breaker or a
+// wallbox fault. The car must report the charge stopped rather than
+// complete, and have no scheduled charge to come, so the end of an off-peak
+// window isn't mistaken for one.
+func chargeInterrupted(end CarState) bool {
+	if end.ChargingState != "Stopped" && end.ChargingState != "Disconnected" {
+		return false
+	}
+	if end.ScheduledChargingStartTime.After(end.At) {
+		return false
+	}
+	return end.PluggedIn && end.ChargeLimitSoc > 0 && end.BatteryLevel <= end.ChargeLimitSoc-ChargeInterruptedMargin
+}
+
+// interruptedMessage alerts that a charge stopped short of the limit, in
+// place of the usual summary.
+func interruptedMessage(name string, start, end CarState) string {
+	return fmt.Sprintf("⚠️ Charging interrupted: %s stopped at %d%% of its %d%% limit at %s, still plugged in.",
+		name, end.BatteryLevel, end.ChargeLimitSoc, placeName(start))
+}
+
+// ChargeLimitMilestone in CHARGE_MILESTONES stands for the car's charge limit.
+const ChargeLimitMilestone = 0
+
+// parseChargeMilestones parses CHARGE_MILESTONES, e.g. "80,limit", into
+// battery levels.
+func parseChargeMilestones(value string) ([]int, error) {
+	var levels []int
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		if field == "limit" {
+			levels = append(levels, ChargeLimitMilestone)
+			continue
+		}
+		level, err := strconv.Atoi(strings.TrimSuffix(field, "%"))
+		if err != nil || level < 1 || level > 100 {
+			return nil, fmt.Errorf("expected a percentage or limit, got %q", field)
+		}
+		levels = append(levels, level)
+	}
+	return levels, nil
+}
+
+// checkChargeMilestone notes the highest milestone the charge in progress
+// has passed since it was last called, and returns the message for it, or ""
+// if none was passed. Levels the charge started above don't count.
+func (car *Car) checkChargeMilestone(milestones []int) string {
+	s := car.State
+	if !car.Charging {
+		return ""
+	}
+	passed, limit := 0, false
+	for _, level := range milestones {
+		isLimit := level == ChargeLimitMilestone
+		if isLimit {
+			level = s.ChargeLimitSoc
+		}
+		if level <= car.ChargeStart.BatteryLevel || level <= car.milestone || level > s.BatteryLevel {
+			continue
+		}
+		if level > passed || level == passed && isLimit {
+			passed, limit = level, isLimit
+		}
+	}
+	if passed == 0 {
+		return ""
+	}
+	car.milestone = passed
+	added := s.ChargeEnergyAdded - car.ChargeStart.ChargeEnergyAdded
+	if limit {
+		return fmt.Sprintf("🔋 %s has reached its %d%% charge limit (+ %.1fkWh).", car.displayName, passed, added)
+	}
+	text := fmt.Sprintf("🔋 %s is at %d%% (+ %.1fkWh)", car.displayName, s.BatteryLevel, added)
+	if s.TimeToFullCharge > 0 {
+		remaining := time.Duration(float64(s.TimeToFullCharge) * float64(time.Hour))
+		text += fmt.Sprintf(", %s more to %d%%", formatDuration(remaining), s.ChargeLimitSoc)
+	}
+	return text + "."
+}
+
+// chargeMessage answers /charge with the charge in progress, or whether
+// the car is plugged in when it isn't charging.
+func chargeMessage(car *Car, now time.Time) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	s := car.State
+	if !car.Charging {
+		plugged := "unplugged"
+		if s.PluggedIn {
+			plugged = "plugged in"
+		}
+		return fmt.Sprintf("🔌 Not charging, %s.\n🔋 %d%% (limit %d%%)", plugged, s.BatteryLevel, s.ChargeLimitSoc)
+	}
+	text := fmt.Sprintf("⚡ Charging at %dkW (%dV, %dA)\n", s.ChargerPower, s.ChargerVoltage, s.ChargerActualCurrent)
+	text += fmt.Sprintf("🔋 %d→%d%% (limit %d%%)\n", car.ChargeStart.BatteryLevel, s.BatteryLevel, s.ChargeLimitSoc)
+	text += fmt.Sprintf("⚡ + %.1fkWh", s.ChargeEnergyAdded-car.ChargeStart.ChargeEnergyAdded)
+	if s.TimeToFullCharge > 0 {
+		remaining := time.Duration(float64(s.TimeToFullCharge) * float64(time.Hour))
+		text += fmt.Sprintf("\n🕗 %s to go, done at %s", formatDuration(remaining), clock(now.Add(remaining)))
+	}
+	return text
+}
+
+// chargeCosts prices the charge just finished when it was at Home: by the
+// tariff over its energy samples where there are enough, or by dynamic
+// prices from pricesURL if set, falling back to the tariff. Charges
+// elsewhere have no cost.
+func (car *Car) chargeCosts(pricesURL string) []BandCost {
+	if car.ChargeStart.Geofence != "Home" {
+		return nil
+	}
+	added := car.State.ChargeEnergyAdded - car.ChargeStart.ChargeEnergyAdded
+	costs := tariff.Split(car.ChargeStart.At, car.State.At, added)
+	if car.sampleEnergy(); len(car.energy) > 2 {
+		costs = tariff.SplitSamples(car.energy)
+	}
+	if pricesURL != "" {
+		if prices, err := priceLookup(pricesURL, car.ChargeStart.At, car.State.At); err != nil {
+			log.Printf("Failed to fetch prices, using the tariff: %s", err)
+		} else if dynamic, ok := splitPrices(car.energy, prices); ok {
+			costs = dynamic
+		} else {
+			log.Printf("Prices don't cover the charge, using the tariff")
+		}
+	}
+	return costs
+}
+
+// recordFinishedCharge records the charge just finished with its cost, and
+// returns the lines for the summary about how often the car charges there
+// and what it cost.
+func (car *Car) recordFinishedCharge(place string, costs []BandCost) string {
+	n := car.recordCharge(chargeRecord{
+		at:          car.ChargeStart.At,
+		until:       car.State.At,
+		geofence:    car.ChargeStart.Geofence,
+		place:       place,
+		startLevel:  car.ChargeStart.BatteryLevel,
+		endLevel:    car.State.BatteryLevel,
+		energyAdded: car.State.ChargeEnergyAdded - car.ChargeStart.ChargeEnergyAdded,
+		cost:        totalCost(costs),
+	})
+	var text string
+	if car.ChargeStart.Geofence != "" {
+		text += fmt.Sprintf("\n📍 %s charge here this month", ordinal(n))
+	}
+	return text + costMessage(tariff, costs)
+}
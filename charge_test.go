@@ -0,0 +1,110 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChargeMessage(t *testing.T) {
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car := &Car{}
+	car.State = CarState{BatteryLevel: 61, ChargeLimitSoc: 80, PluggedIn: true}
+	assert.Equal(t, "🔌 Not charging, plugged in.\n🔋 61% (limit 80%)", chargeMessage(car, at))
+
+	car.Charging = true
+	car.ChargeStart = CarState{BatteryLevel: 50, ChargeEnergyAdded: 0}
+	car.State = CarState{BatteryLevel: 61, ChargeLimitSoc: 80, PluggedIn: true, ChargerPower: 11, ChargerVoltage: 230, ChargerActualCurrent: 16, ChargeEnergyAdded: 8.2, TimeToFullCharge: 1.5}
+	assert.Equal(t, "⚡ Charging at 11kW (230V, 16A)\n🔋 50→61% (limit 80%)\n⚡ + 8.2kWh\n🕗 1h30m to go, done at 08:09", chargeMessage(car, at))
+	assert.Equal(t, "No car data received yet.", chargeMessage(nil, at))
+}
+
+func TestParseChargeMilestones(t *testing.T) {
+	levels, err := parseChargeMilestones("80%, limit")
+	assert.NoError(t, err)
+	assert.Equal(t, []int{80, ChargeLimitMilestone}, levels)
+	_, err = parseChargeMilestones("eighty")
+	assert.Error(t, err)
+	_, err = parseChargeMilestones("101")
+	assert.Error(t, err)
+}
+
+func TestCheckChargeMilestone(t *testing.T) {
+	milestones := []int{80, ChargeLimitMilestone}
+	car := &Car{displayName: "Snowflake"}
+	car.Charging = true
+	car.ChargeStart = CarState{BatteryLevel: 50}
+	car.State = CarState{BatteryLevel: 79, ChargeLimitSoc: 90, ChargeEnergyAdded: 20}
+	assert.Equal(t, "", car.checkChargeMilestone(milestones))
+	car.State.BatteryLevel, car.State.TimeToFullCharge = 81, 0.75
+	assert.Equal(t, "🔋 Snowflake is at 81% (+ 20.0kWh), 45m more to 90%.", car.checkChargeMilestone(milestones))
+	car.State.BatteryLevel = 82
+	assert.Equal(t, "", car.checkChargeMilestone(milestones))
+	car.State.BatteryLevel, car.State.ChargeEnergyAdded = 90, 27.5
+	assert.Equal(t, "🔋 Snowflake has reached its 90% charge limit (+ 27.5kWh).", car.checkChargeMilestone(milestones))
+
+	// a charge starting above a milestone skips it
+	car.milestone = 0
+	car.ChargeStart = CarState{BatteryLevel: 85}
+	assert.Equal(t, "🔋 Snowflake has reached its 90% charge limit (+ 27.5kWh).", car.checkChargeMilestone(milestones))
+	car.milestone = 0
+	car.State.ChargeLimitSoc = 80
+	assert.Equal(t, "", car.checkChargeMilestone(milestones))
+}
+
+func TestChargeInterrupted(t *testing.T) {
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	start := CarState{At: at, BatteryLevel: 50, Geofence: "Home"}
+	end := CarState{At: at.Add(2 * time.Hour), BatteryLevel: 62, ChargeLimitSoc: 90, ChargeEnergyAdded: 8.2, PluggedIn: true, ChargingState: "Stopped"}
+	assert.True(t, chargeInterrupted(end))
+	assert.Equal(t, "⚠️ Charging interrupted: Snowflake stopped at 62% of its 90% limit at Home, still plugged in.", interruptedMessage("Snowflake", start, end))
+
+	end.ScheduledChargingStartTime = at.Add(22 * time.Hour)
+	assert.False(t, chargeInterrupted(end), "off-peak window over, next one scheduled")
+	end.ScheduledChargingStartTime = time.Time{}
+	end.ChargingState = "Complete"
+	assert.False(t, chargeInterrupted(end), "complete")
+	end.ChargingState, end.BatteryLevel = "Stopped", 86
+	assert.False(t, chargeInterrupted(end), "close enough to the limit")
+	end.BatteryLevel, end.PluggedIn = 62, false
+	assert.False(t, chargeInterrupted(end), "unplugged")
+}
+
+func TestFinishedHomeChargeCost(t *testing.T) {
+	defer func(t Tariff) { tariff = t }(tariff)
+	tariff = Tariff{Price: 0.25, Currency: "£"}
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car := &Car{}
+	car.ChargeStart = CarState{At: at, Geofence: "Home", BatteryLevel: 50}
+	car.State = CarState{At: at.Add(30 * time.Minute), Geofence: "Home", BatteryLevel: 55, ChargeEnergyAdded: 4}
+	car.sampleEnergy()
+	car.State.At, car.State.ChargeEnergyAdded = at.Add(time.Hour), 8
+	car.sampleEnergy()
+
+	costs := car.chargeCosts("")
+	assert.Equal(t, float32(2), totalCost(costs))
+	text := car.recordFinishedCharge("Home", costs)
+	assert.Equal(t, "\n📍 1st charge here this month\n💷 8.0kWh ≈ £2.00", text)
+	assert.Equal(t, float32(2), car.charges[0].cost)
+
+	car.ChargeStart.Geofence = ""
+	assert.Nil(t, car.chargeCosts(""))
+}
+
+func TestChargeCostsPricesFallback(t *testing.T) {
+	defer func(t Tariff) { tariff = t }(tariff)
+	tariff = Tariff{Price: 0.25, Currency: "£"}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car := &Car{}
+	car.ChargeStart = CarState{At: at, Geofence: "Home", BatteryLevel: 50}
+	car.State = CarState{At: at.Add(time.Hour), Geofence: "Home", BatteryLevel: 55, ChargeEnergyAdded: 8}
+
+	assert.InDelta(t, 2, totalCost(car.chargeCosts(server.URL)), 0.001)
+}
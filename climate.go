@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// Cabin must cool this far below the venting threshold before the
+// suggestion is cleared, so readings hovering at the threshold don't flap.
+const VentHysteresis = 5
+
+// checkVenting suggests venting the windows when the parked car's cabin is
+// hot on a hot day, and follows up once it has cooled. It returns the event
+// type and message, or "" if nothing changed.
+func (car *Car) checkVenting(insideLimit, outsideLimit float32) (string, string) {
+	s := car.State
+	if insideLimit == 0 || car.Driving {
+		return "", ""
+	}
+	if !car.venting && s.InsideTemp >= insideLimit && s.OutsideTemp >= outsideLimit {
+		car.venting = true
+		return "vent_suggested", fmt.Sprintf("🌡 Cabin is %s (outside %s). Consider venting the windows.", formatTemperature(s.InsideTemp), formatTemperature(s.OutsideTemp))
+	}
+	if car.venting && s.InsideTemp < insideLimit-VentHysteresis {
+		car.venting = false
+		return "vent_cleared", fmt.Sprintf("✅ Cabin has cooled to %s.", formatTemperature(s.InsideTemp))
+	}
+	return "", ""
+}
+
+// climateMessage answers /climate with the temperatures and whether the
+// climate control is running.
+func climateMessage(car *Car) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	s := car.State
+	text := fmt.Sprintf("🌡 Inside %s · outside %s\n", formatTemperature(s.InsideTemp), formatTemperature(s.OutsideTemp))
+	switch {
+	case s.IsPreconditioning:
+		text += "❄️ Preconditioning"
+	case s.IsClimateOn:
+		text += "❄️ Climate on"
+	default:
+		text += "Climate off"
+	}
+	if s.DriverTempSetting != 0 {
+		text += fmt.Sprintf(", set to %s", formatTemperature(s.DriverTempSetting))
+	}
+	return text
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckVenting(t *testing.T) {
+	car := &Car{}
+	car.State = CarState{InsideTemp: 38, OutsideTemp: 22}
+	event, _ := car.checkVenting(35, 25)
+	assert.Equal(t, "", event, "not hot outside")
+
+	car.State.OutsideTemp = 28
+	event, text := car.checkVenting(35, 25)
+	assert.Equal(t, "vent_suggested", event)
+	assert.Equal(t, "🌡 Cabin is 38.0°C (outside 28.0°C). Consider venting the windows.", text)
+	event, _ = car.checkVenting(35, 25)
+	assert.Equal(t, "", event, "only suggested once")
+
+	car.State.InsideTemp = 32
+	event, _ = car.checkVenting(35, 25)
+	assert.Equal(t, "", event, "within hysteresis")
+	car.State.InsideTemp = 29
+	event, text = car.checkVenting(35, 25)
+	assert.Equal(t, "vent_cleared", event)
+	assert.Equal(t, "✅ Cabin has cooled to 29.0°C.", text)
+
+	event, _ = car.checkVenting(0, 25)
+	assert.Equal(t, "", event, "disabled")
+}
+
+func TestClimateMessage(t *testing.T) {
+	car := &Car{}
+	car.State = CarState{InsideTemp: 21, OutsideTemp: 7.5}
+	assert.Equal(t, "🌡 Inside 21.0°C · outside 7.5°C\nClimate off", climateMessage(car))
+	car.State.IsClimateOn, car.State.DriverTempSetting = true, 20.5
+	assert.Equal(t, "🌡 Inside 21.0°C · outside 7.5°C\n❄️ Climate on, set to 20.5°C", climateMessage(car))
+	car.State.IsPreconditioning = true
+	assert.Contains(t, climateMessage(car), "\n❄️ Preconditioning, set to 20.5°C")
+	assert.Equal(t, "No car data received yet.", climateMessage(nil))
+}
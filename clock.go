@@ -0,0 +1,31 @@
+package main
+
+import (
+	"time"
+	// Embedded zoneinfo, as the container image has none.
+	_ "time/tzdata"
+)
+
+// location is the timezone messages are shown in.
+var location = time.Local
+
+const (
+	Clock12 = "12"
+	Clock24 = "24"
+)
+
+var clockFormat = Clock24
+
+// now is the current time in the configured timezone.
+func now() time.Time {
+	return time.Now().In(location)
+}
+
+// clock formats the time of day in the configured timezone and clock
+// format, e.g. 15:04 or 3:04 PM.
+func clock(t time.Time) string {
+	if clockFormat == Clock12 {
+		return t.In(location).Format("3:04 PM")
+	}
+	return t.In(location).Format("15:04")
+}
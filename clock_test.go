@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMain(m *testing.M) {
+	// expected messages are written in UTC, whatever the machine's timezone
+	location = time.UTC
+	os.Exit(m.Run())
+}
+
+func TestTimezone(t *testing.T) {
+	defer func(l *time.Location) { location = l }(location)
+	c, err := parseConfig(lookupMap(map[string]string{"TIMEZONE": "America/New_York"}))
+	assert.NoError(t, err)
+	location = c.Location
+	assert.Equal(t, "02:39", clock(time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)))
+
+	c, err = parseConfig(lookupMap(map[string]string{"TZ": "Europe/Berlin"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", c.Location.String())
+
+	_, err = parseConfig(lookupMap(map[string]string{"TIMEZONE": "Mars/Olympus"}))
+	assert.EqualError(t, err, "invalid TIMEZONE: unknown time zone Mars/Olympus")
+}
+
+func TestClock12(t *testing.T) {
+	defer func(l *time.Location, f string) { location, clockFormat = l, f }(location, clockFormat)
+	location, clockFormat = time.UTC, Clock12
+	assert.Equal(t, "6:39 AM", clock(time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)))
+	assert.Equal(t, "3:04 PM", clock(time.Date(2021, 4, 9, 15, 4, 0, 0, time.UTC)))
+
+	_, err := parseConfig(lookupMap(map[string]string{"CLOCK": "13"}))
+	assert.EqualError(t, err, "invalid CLOCK: 13")
+}
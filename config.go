@@ -0,0 +1,449 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Config is read from the environment and optionally a file of KEY=VALUE
+// lines named by CONFIG_FILE. Environment variables take precedence over the
+// file, so only settings from the file change on reload.
+type Config struct {
+	TelegramTokens []string
+	ChatIDs        []int64
+	Routes         map[string][]int64 // event category to chats
+	CarRoutes      map[int][]int64    // TeslaMate car ID to chats
+	CarKMPerKwh    map[int]float32    // TeslaMate car ID to rated km per kWh
+	BotRoutes      map[string][]int64 // bot username to chats
+	Allowlist      []int64            // user or chat IDs allowed to use commands
+	Admins         []int64            // user IDs with the admin role
+	Viewers        []int64            // user IDs with the viewer role
+	StateFile      string
+
+	MQTTURL        string
+	MQTTUsername   string
+	MQTTPassword   string
+	MQTTNamespace  string
+	UpdateInterval time.Duration
+	Warmup         time.Duration
+	DriveGrace     time.Duration // out of D/R before a drive finishes
+
+	MQTTTLS         bool
+	MQTTCACert      string
+	MQTTClientCert  string
+	MQTTClientKey   string
+	MQTTTLSInsecure bool
+
+	Language         string
+	Units            string
+	TemperatureUnit  string // C or F
+	Location         *time.Location
+	Clock            string // 12 or 24 hour
+	PrivacyMode      string
+	PrivateGeofences []string
+	SessionRetention time.Duration
+	Geocoding        bool
+
+	CarbonIntensity   bool
+	CarbonWindowHours int
+
+	Weather bool
+
+	Receipts bool // PDF receipt button on charge summaries
+
+	CalendarURL   string // iCalendar feed of trips to check the battery for
+	CalendarCheck int    // minutes since midnight to check tomorrow's trips
+
+	Tariff    Tariff
+	Tariffs   map[string]Tariff // alternatives for /simulate
+	PricesURL string            // dynamic prices, e.g. Octopus Agile
+
+	// Telegram file IDs sent as celebrations, if set
+	FullChargeSticker  string
+	MilestoneAnimation string
+	MilestoneEvery     float32 // in UNITS
+
+	CurveReference chargeCurve // expected DC power by battery level
+	NewRangeKm     float32     // rated range when new, for degradation
+
+	EVSEEnergyTopic   string
+	EVSEEnergyUnit    string
+	EVSECommandTopic  string
+	EVSEPausePayload  string
+	EVSEResumePayload string
+
+	GridImportTopic     string
+	GridImportLimit     float64 // W
+	GridImportAutoPause bool
+
+	MetricsAddr string
+
+	ReleaseCheck         bool
+	ReleaseFeed          string
+	ReleaseCheckInterval time.Duration
+
+	HookCommand string
+	HookTimeout time.Duration
+
+	FilterScript string
+
+	StatusSchedule string // cron expression for the configured chat
+	TTSURL         string // speech engine voicing the scheduled status
+
+	ChargeMilestones []int // battery levels notified during a charge, ChargeLimitMilestone for the limit
+
+	VentInsideTemp  float32 // °C, 0 disables venting suggestions
+	VentOutsideTemp float32 // °C
+
+	// drives and charges below these aren't notified
+	MinDriveDistance float32 // miles, or km with metric units
+	MinDriveDuration time.Duration
+	MinChargeEnergy  float32 // kWh
+
+	// notifications to a chat in a minute before the rest are digested
+	DigestLimit int
+
+	Templates map[string]*template.Template // message name to its replacement format
+
+	LeaderTopic string // retained MQTT topic for active/standby election
+	LeaderID    string
+	LeaderLease time.Duration
+}
+
+func loadConfig() (*Config, error) {
+	file := map[string]string{}
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		var err error
+		if file, err = readConfigFile(path); err != nil {
+			return nil, err
+		}
+	}
+	return parseConfig(func(key string) (string, bool) {
+		if value, ok := os.LookupEnv(key); ok {
+			return value, true
+		}
+		value, ok := file[key]
+		return value, ok
+	})
+}
+
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	values := map[string]string{}
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.Index(line, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		values[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+	}
+	return values, scanner.Err()
+}
+
+func parseConfig(lookup func(string) (string, bool)) (*Config, error) {
+	var err error
+	get := func(key, fallback string) string {
+		if value, ok := lookup(key); ok {
+			return value
+		}
+		return fallback
+	}
+	getInt := func(key string, fallback int) int {
+		value, ok := lookup(key)
+		if !ok || err != nil {
+			return fallback
+		}
+		i, e := strconv.Atoi(value)
+		if e != nil {
+			err = fmt.Errorf("invalid %s: %s", key, e)
+		}
+		return i
+	}
+	getDuration := func(key string, fallback time.Duration) time.Duration {
+		value, ok := lookup(key)
+		if !ok || err != nil {
+			return fallback
+		}
+		d, e := time.ParseDuration(value)
+		if e != nil {
+			err = fmt.Errorf("invalid %s: %s", key, e)
+		}
+		return d
+	}
+	getFloat := func(key string, fallback float64) float64 {
+		value, ok := lookup(key)
+		if !ok || err != nil {
+			return fallback
+		}
+		f, e := strconv.ParseFloat(value, 64)
+		if e != nil {
+			err = fmt.Errorf("invalid %s: %s", key, e)
+		}
+		return f
+	}
+
+	c := &Config{
+		StateFile: get("STATE_FILE", "teslamate-telegram.json"),
+
+		MQTTURL:        get("MQTT_URL", "tcp://mqtt:1883"),
+		MQTTUsername:   get("MQTT_USERNAME", ""),
+		MQTTPassword:   get("MQTT_PASSWORD", ""),
+		MQTTNamespace:  get("MQTT_NAMESPACE", ""),
+		UpdateInterval: getDuration("UPDATE_INTERVAL", time.Second),
+		Warmup:         getDuration("WARMUP", 10*time.Second),
+		DriveGrace:     getDuration("DRIVE_GRACE", 0),
+
+		MQTTTLS:         get("MQTT_TLS", "") == "true",
+		MQTTCACert:      get("MQTT_CA_CERT", ""),
+		MQTTClientCert:  get("MQTT_CLIENT_CERT", ""),
+		MQTTClientKey:   get("MQTT_CLIENT_KEY", ""),
+		MQTTTLSInsecure: get("MQTT_TLS_INSECURE", "") == "true",
+
+		Language:         get("LANGUAGE", "en"),
+		Units:            get("UNITS", UnitsImperial),
+		TemperatureUnit:  get("TEMPERATURE_UNIT", Celsius),
+		Clock:            get("CLOCK", Clock24),
+		PrivacyMode:      get("PRIVACY_MODE", PrivacyOff),
+		SessionRetention: time.Duration(getInt("SESSION_RETENTION_DAYS", 730)) * 24 * time.Hour,
+		Geocoding:        get("GEOCODING", "true") == "true",
+
+		CarbonIntensity:   get("CARBON_INTENSITY", "") == "true",
+		CarbonWindowHours: getInt("CARBON_WINDOW_HOURS", 3),
+
+		Weather: get("WEATHER", "") == "true",
+
+		Receipts: get("RECEIPTS", "") == "true",
+
+		CalendarURL: get("CALENDAR_URL", ""),
+
+		NewRangeKm: float32(getFloat("BATTERY_NEW_RANGE_KM", 0)),
+
+		Tariff: Tariff{
+			Price:    float32(getFloat("TARIFF_PRICE", 0)),
+			Currency: get("TARIFF_CURRENCY", "£"),
+			Interval: getDuration("TARIFF_INTERVAL", 0),
+		},
+		PricesURL: get("PRICES_URL", ""),
+
+		FullChargeSticker:  get("FULL_CHARGE_STICKER", ""),
+		MilestoneAnimation: get("MILESTONE_ANIMATION", ""),
+		MilestoneEvery:     float32(getFloat("MILESTONE_EVERY", 10000)),
+
+		EVSEEnergyTopic:   get("EVSE_ENERGY_TOPIC", ""),
+		EVSEEnergyUnit:    get("EVSE_ENERGY_UNIT", "kWh"),
+		EVSECommandTopic:  get("EVSE_COMMAND_TOPIC", ""),
+		EVSEPausePayload:  get("EVSE_PAUSE_PAYLOAD", "pause"),
+		EVSEResumePayload: get("EVSE_RESUME_PAYLOAD", "resume"),
+
+		GridImportTopic:     get("GRID_IMPORT_TOPIC", ""),
+		GridImportLimit:     getFloat("GRID_IMPORT_LIMIT", 0),
+		GridImportAutoPause: get("GRID_IMPORT_AUTO_PAUSE", "") == "true",
+
+		MetricsAddr: get("METRICS_ADDR", ""),
+
+		ReleaseCheck:         get("RELEASE_CHECK", "") == "true",
+		ReleaseFeed:          get("RELEASE_FEED", DefaultReleaseFeed),
+		ReleaseCheckInterval: getDuration("RELEASE_CHECK_INTERVAL", 24*time.Hour),
+
+		HookCommand: get("HOOK_COMMAND", ""),
+		HookTimeout: getDuration("HOOK_TIMEOUT", 10*time.Second),
+
+		FilterScript: get("FILTER_SCRIPT", ""),
+
+		StatusSchedule: get("STATUS_SCHEDULE", ""),
+		TTSURL:         get("TTS_URL", ""),
+
+		VentInsideTemp:  float32(getFloat("VENT_INSIDE_TEMP", 0)),
+		VentOutsideTemp: float32(getFloat("VENT_OUTSIDE_TEMP", 25)),
+
+		MinDriveDistance: float32(getFloat("MIN_DRIVE_DISTANCE", 0)),
+		MinDriveDuration: getDuration("MIN_DRIVE_DURATION", 0),
+		MinChargeEnergy:  float32(getFloat("MIN_CHARGE_ENERGY", 0)),
+
+		DigestLimit: getInt("DIGEST_LIMIT", 5),
+
+		LeaderTopic: get("LEADER_TOPIC", ""),
+		LeaderID:    get("LEADER_ID", ""),
+		LeaderLease: getDuration("LEADER_LEASE", 30*time.Second),
+	}
+	for _, token := range strings.Split(get("TELEGRAM_TOKEN", ""), ",") {
+		if token = strings.TrimSpace(token); token != "" {
+			c.TelegramTokens = append(c.TelegramTokens, token)
+		}
+	}
+	if i := c.Tariff.Interval; err == nil && (i < 0 || i > 0 && (i%time.Minute != 0 || 24*time.Hour%i != 0)) {
+		err = fmt.Errorf("invalid TARIFF_INTERVAL: %s doesn't divide a day into whole minutes", i)
+	}
+	if err == nil && c.ReleaseCheckInterval < time.Minute {
+		err = fmt.Errorf("invalid RELEASE_CHECK_INTERVAL: %s is under a minute", c.ReleaseCheckInterval)
+	}
+	c.CalendarCheck = 20 * 60
+	if value, ok := lookup("CALENDAR_CHECK"); ok && err == nil {
+		if c.CalendarCheck, err = parseClock(value); err != nil {
+			err = fmt.Errorf("invalid CALENDAR_CHECK: %s", err)
+		}
+	}
+	if value, ok := lookup("CHARGE_MILESTONES"); ok && err == nil {
+		if c.ChargeMilestones, err = parseChargeMilestones(value); err != nil {
+			err = fmt.Errorf("invalid CHARGE_MILESTONES: %s", err)
+		}
+	}
+	if value, ok := lookup("TARIFF_SCHEDULE"); ok && err == nil {
+		if c.Tariff.Bands, err = parseTariffBands(value); err != nil {
+			err = fmt.Errorf("invalid TARIFF_SCHEDULE: %s", err)
+		}
+	}
+	// each alternative has its own TARIFF_<NAME>_PRICE and _SCHEDULE
+	for _, name := range strings.Split(get("TARIFFS", ""), ",") {
+		if name = strings.TrimSpace(name); name == "" || err != nil {
+			continue
+		}
+		key := "TARIFF_" + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+		alt := Tariff{
+			Price:    float32(getFloat(key+"_PRICE", 0)),
+			Currency: c.Tariff.Currency,
+			Interval: getDuration(key+"_INTERVAL", c.Tariff.Interval),
+		}
+		if value, ok := lookup(key + "_SCHEDULE"); ok && err == nil {
+			if alt.Bands, err = parseTariffBands(value); err != nil {
+				err = fmt.Errorf("invalid %s_SCHEDULE: %s", key, err)
+			}
+		}
+		if c.Tariffs == nil {
+			c.Tariffs = map[string]Tariff{}
+		}
+		c.Tariffs[name] = alt
+	}
+	for _, geofence := range strings.Split(get("PRIVATE_GEOFENCES", ""), ",") {
+		if geofence = strings.TrimSpace(geofence); geofence != "" {
+			c.PrivateGeofences = append(c.PrivateGeofences, geofence)
+		}
+	}
+	if value, ok := lookup("TELEGRAM_CHAT_ID"); ok && err == nil {
+		if c.ChatIDs, err = parseChatIDs(value); err != nil {
+			err = fmt.Errorf("invalid TELEGRAM_CHAT_ID: %s", err)
+		}
+	}
+	if value, ok := lookup("TELEGRAM_ALLOWLIST"); ok && err == nil {
+		if c.Allowlist, err = parseChatIDs(value); err != nil {
+			err = fmt.Errorf("invalid TELEGRAM_ALLOWLIST: %s", err)
+		}
+	}
+	if value, ok := lookup("TELEGRAM_ADMINS"); ok && err == nil {
+		if c.Admins, err = parseChatIDs(value); err != nil {
+			err = fmt.Errorf("invalid TELEGRAM_ADMINS: %s", err)
+		}
+	}
+	if value, ok := lookup("TELEGRAM_VIEWERS"); ok && err == nil {
+		if c.Viewers, err = parseChatIDs(value); err != nil {
+			err = fmt.Errorf("invalid TELEGRAM_VIEWERS: %s", err)
+		}
+	}
+	if value, ok := lookup("TELEGRAM_ROUTES"); ok && err == nil {
+		if c.Routes, err = parseRoutes(value); err != nil {
+			err = fmt.Errorf("invalid TELEGRAM_ROUTES: %s", err)
+		}
+	}
+	if value, ok := lookup("TELEGRAM_BOT_ROUTES"); ok && err == nil {
+		if c.BotRoutes, err = parseBotRoutes(value); err != nil {
+			err = fmt.Errorf("invalid TELEGRAM_BOT_ROUTES: %s", err)
+		}
+	}
+	if value, ok := lookup("CHARGE_CURVE_REFERENCE"); ok && err == nil {
+		if c.CurveReference, err = parseCurve(value); err != nil {
+			err = fmt.Errorf("invalid CHARGE_CURVE_REFERENCE: %s", err)
+		}
+	}
+	if value, ok := lookup("TELEGRAM_CAR_ROUTES"); ok && err == nil {
+		if c.CarRoutes, err = parseCarRoutes(value); err != nil {
+			err = fmt.Errorf("invalid TELEGRAM_CAR_ROUTES: %s", err)
+		}
+	}
+	if value, ok := lookup("RATED_KM_PER_KWH"); ok && err == nil {
+		if c.CarKMPerKwh, err = parseCarEfficiency(value); err != nil {
+			err = fmt.Errorf("invalid RATED_KM_PER_KWH: %s", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Templates, err = parseTemplates(lookup); err != nil {
+		return nil, err
+	}
+	if c.StatusSchedule != "" {
+		if _, err := parseCron(c.StatusSchedule); err != nil {
+			return nil, fmt.Errorf("invalid STATUS_SCHEDULE: %s", err)
+		}
+	}
+	if _, ok := catalogs[c.Language]; !ok {
+		return nil, fmt.Errorf("invalid LANGUAGE: %s", c.Language)
+	}
+	if c.Location, err = time.LoadLocation(get("TIMEZONE", get("TZ", "Local"))); err != nil {
+		return nil, fmt.Errorf("invalid TIMEZONE: %s", err)
+	}
+	if c.Clock != Clock12 && c.Clock != Clock24 {
+		return nil, fmt.Errorf("invalid CLOCK: %s", c.Clock)
+	}
+	if c.Units != UnitsImperial && c.Units != UnitsMetric {
+		return nil, fmt.Errorf("invalid UNITS: %s", c.Units)
+	}
+	if c.TemperatureUnit != Celsius && c.TemperatureUnit != Fahrenheit {
+		return nil, fmt.Errorf("invalid TEMPERATURE_UNIT: %s", c.TemperatureUnit)
+	}
+	switch c.PrivacyMode {
+	case PrivacyOff, PrivacyRound, PrivacyStrict:
+	default:
+		return nil, fmt.Errorf("invalid PRIVACY_MODE: %s", c.PrivacyMode)
+	}
+	return c, nil
+}
+
+// parseChatIDs parses a comma separated list of chat IDs.
+func parseChatIDs(value string) ([]int64, error) {
+	var ids []int64
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// apply updates package-wide settings from the config.
+func (c *Config) apply() {
+	language = c.Language
+	units = c.Units
+	temperatureUnit = c.TemperatureUnit
+	location = c.Location
+	clockFormat = c.Clock
+	tariff = c.Tariff
+	tariffs = c.Tariffs
+	carKMPerKwh = c.CarKMPerKwh
+	newRangeKm = c.NewRangeKm
+	privacyMode = c.PrivacyMode
+	privateGeofences = map[string]bool{}
+	for _, geofence := range c.PrivateGeofences {
+		privateGeofences[geofence] = true
+	}
+	sessionRetention = c.SessionRetention
+	features.configure(c)
+}
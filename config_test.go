@@ -0,0 +1,88 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func lookupMap(values map[string]string) func(string) (string, bool) {
+	return func(key string) (string, bool) {
+		value, ok := values[key]
+		return value, ok
+	}
+}
+
+func TestParseConfigDefaults(t *testing.T) {
+	c, err := parseConfig(lookupMap(nil))
+	assert.NoError(t, err)
+	assert.Empty(t, c.ChatIDs)
+	assert.Equal(t, 730*24*time.Hour, c.SessionRetention)
+	assert.Equal(t, 3, c.CarbonWindowHours)
+	assert.Equal(t, "pause", c.EVSEPausePayload)
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{
+		"TELEGRAM_CHAT_ID":       "-1001234, 5678",
+		"SESSION_RETENTION_DAYS": "30",
+		"GRID_IMPORT_LIMIT":      "9200",
+		"PRIVACY_MODE":           "round",
+		"TELEGRAM_CAR_ROUTES":    "1=111;2=222",
+		"TELEGRAM_TOKEN":         "123:abc, 456:def",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, []int64{-1001234, 5678}, c.ChatIDs)
+	assert.Equal(t, 30*24*time.Hour, c.SessionRetention)
+	assert.Equal(t, 9200.0, c.GridImportLimit)
+	assert.Equal(t, PrivacyRound, c.PrivacyMode)
+	assert.Equal(t, map[int][]int64{1: {111}, 2: {222}}, c.CarRoutes)
+	assert.Equal(t, []string{"123:abc", "456:def"}, c.TelegramTokens)
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	_, err := parseConfig(lookupMap(map[string]string{"CARBON_WINDOW_HOURS": "three"}))
+	assert.EqualError(t, err, `invalid CARBON_WINDOW_HOURS: strconv.Atoi: parsing "three": invalid syntax`)
+	_, err = parseConfig(lookupMap(map[string]string{"TELEGRAM_CHAT_ID": "1234,me"}))
+	assert.EqualError(t, err, `invalid TELEGRAM_CHAT_ID: strconv.ParseInt: parsing "me": invalid syntax`)
+	_, err = parseConfig(lookupMap(map[string]string{"PRIVACY_MODE": "paranoid"}))
+	assert.EqualError(t, err, "invalid PRIVACY_MODE: paranoid")
+}
+
+func TestReadConfigFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "teslamate-telegram.env")
+	ioutil.WriteFile(path, []byte("# comment\nTELEGRAM_CHAT_ID = 1234\n\nPRIVACY_MODE=strict\n"), 0644)
+	values, err := readConfigFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"TELEGRAM_CHAT_ID": "1234", "PRIVACY_MODE": "strict"}, values)
+
+	ioutil.WriteFile(path, []byte("BROKEN\n"), 0644)
+	_, err = readConfigFile(path)
+	assert.EqualError(t, err, path+":1: expected KEY=VALUE")
+}
+
+func TestTariffsConfig(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{"TARIFFS": "go, flux", "TARIFF_GO_PRICE": "0.3", "TARIFF_GO_SCHEDULE": "off-peak=00:30-04:30@0.075", "TARIFF_FLUX_PRICE": "0.28"}))
+	assert.NoError(t, err)
+	assert.Len(t, c.Tariffs, 2)
+	assert.Equal(t, float32(0.3), c.Tariffs["go"].Price)
+	assert.Len(t, c.Tariffs["go"].Bands, 1)
+	assert.Equal(t, "£", c.Tariffs["flux"].Currency)
+	_, err = parseConfig(lookupMap(map[string]string{"TARIFFS": "go", "TARIFF_GO_SCHEDULE": "cheap"}))
+	assert.EqualError(t, err, "invalid TARIFF_GO_SCHEDULE: expected name=HH:MM-HH:MM@price, got \"cheap\"")
+}
+
+func TestChargeMilestonesConfig(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{"CHARGE_MILESTONES": "80,limit"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []int{80, ChargeLimitMilestone}, c.ChargeMilestones)
+	_, err = parseConfig(lookupMap(map[string]string{"CHARGE_MILESTONES": "80,full"}))
+	assert.EqualError(t, err, `invalid CHARGE_MILESTONES: expected a percentage or limit, got "full"`)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCron(t *testing.T) {
+	// Friday
+	at := time.Date(2021, 4, 9, 7, 0, 0, 0, time.UTC)
+	c, err := parseCron("0 7 * * *")
+	assert.NoError(t, err)
+	assert.True(t, c.Matches(at))
+	assert.False(t, c.Matches(at.Add(time.Minute)))
+
+	c, _ = parseCron("*/15 6-8 * * 1-5")
+	assert.True(t, c.Matches(at.Add(45*time.Minute)))
+	assert.False(t, c.Matches(at.Add(50*time.Minute)))
+	assert.False(t, c.Matches(at.AddDate(0, 0, 1)))
+
+	c, _ = parseCron("0 7 1 * 0,7")
+	assert.False(t, c.Matches(at))
+	assert.True(t, c.Matches(at.AddDate(0, 0, 2)), "Sunday")
+	assert.True(t, c.Matches(time.Date(2021, 5, 1, 7, 0, 0, 0, time.UTC)), "1st of month")
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	_, err := parseCron("0 7 * *")
+	assert.EqualError(t, err, `cron expression "0 7 * *" needs 5 fields`)
+	_, err = parseCron("60 7 * * *")
+	assert.EqualError(t, err, `cron value "60" out of range 0-59`)
+	_, err = parseCron("*/0 7 * * *")
+	assert.EqualError(t, err, `invalid cron step "*/0"`)
+}
+
+func TestScheduleCommand(t *testing.T) {
+	s := &Store{}
+	s.init()
+	assert.Equal(t, "No status schedule. Set one with /schedule <cron>, e.g. /schedule 0 7 * * *", scheduleCommand(s, 1, ""))
+	assert.Equal(t, "🕗 Status will be sent on schedule 0 7 * * *", scheduleCommand(s, 1, "0 7 * * *"))
+	assert.Equal(t, "0 7 * * *", s.Chats[1].StatusSchedule)
+	assert.Equal(t, `⚠️ cron expression "7am" needs 5 fields`, scheduleCommand(s, 1, "7am"))
+	assert.Equal(t, "🕗 Scheduled status turned off", scheduleCommand(s, 1, "off"))
+	assert.Equal(t, "", s.Chats[1].StatusSchedule)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Deviation from the reference curve worth mentioning.
+const CurveDeviationThreshold = 0.1
+
+// chargeCurve is the peak charger power seen at each battery level.
+type chargeCurve map[int]int // % to kW
+
+// parseCurve parses CHARGE_CURVE_REFERENCE, e.g. "10=250,30=180,50=120",
+// the power expected at each battery level.
+func parseCurve(value string) (chargeCurve, error) {
+	curve := chargeCurve{}
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("expected level=kW, got %q", entry)
+		}
+		level, err := strconv.Atoi(entry[:i])
+		if err != nil {
+			return nil, err
+		}
+		kw, err := strconv.Atoi(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		curve[level] = kw
+	}
+	return curve, nil
+}
+
+// sampleCurve notes the charger power at the current battery level.
+// sampleEnergy notes the energy added so far in the charge in progress,
+// for splitting it between tariff bands.
+func (car *Car) sampleEnergy() {
+	if len(car.energy) == 0 {
+		car.energy = []energySample{{car.ChargeStart.At, car.ChargeStart.ChargeEnergyAdded}}
+	}
+	if car.energy[len(car.energy)-1].added == car.State.ChargeEnergyAdded {
+		return
+	}
+	car.energy = append(car.energy, energySample{car.State.At, car.State.ChargeEnergyAdded})
+}
+
+func (car *Car) sampleCurve() {
+	if car.curve == nil {
+		car.curve = chargeCurve{}
+	}
+	level, power := car.State.BatteryLevel, car.State.ChargerPower
+	if power > car.curve[level] {
+		car.curve[level] = power
+	}
+}
+
+// at interpolates the curve's power at a battery level, returning false
+// outside the levels it covers.
+func (c chargeCurve) at(level int) (float64, bool) {
+	levels := make([]int, 0, len(c))
+	for l := range c {
+		levels = append(levels, l)
+	}
+	sort.Ints(levels)
+	for i, l := range levels {
+		if l == level {
+			return float64(c[l]), true
+		}
+		if l > level && i > 0 {
+			prev := levels[i-1]
+			frac := float64(level-prev) / float64(l-prev)
+			return float64(c[prev]) + frac*float64(c[l]-c[prev]), true
+		}
+	}
+	return 0, false
+}
+
+// curveDeviation compares an observed curve with the reference, returning
+// the average relative difference across the levels both cover. ok is false
+// if there are too few to compare.
+func curveDeviation(observed, reference chargeCurve) (deviation float64, ok bool) {
+	var total float64
+	n := 0
+	for level, kw := range observed {
+		expected, covered := reference.at(level)
+		if !covered || expected == 0 {
+			continue
+		}
+		total += (float64(kw) - expected) / expected
+		n++
+	}
+	if n < 3 {
+		return 0, false
+	}
+	return total / float64(n), true
+}
+
+func curveMessage(observed, reference chargeCurve) string {
+	deviation, ok := curveDeviation(observed, reference)
+	if !ok || (deviation < CurveDeviationThreshold && deviation > -CurveDeviationThreshold) {
+		return ""
+	}
+	if deviation < 0 {
+		return fmt.Sprintf("\n📉 ~%.0f%% below the reference charging curve", -deviation*100)
+	}
+	return fmt.Sprintf("\n📈 ~%.0f%% above the reference charging curve", deviation*100)
+}
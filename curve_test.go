@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCurve(t *testing.T) {
+	curve, err := parseCurve("10=250, 30=180,50=120")
+	assert.NoError(t, err)
+	assert.Equal(t, chargeCurve{10: 250, 30: 180, 50: 120}, curve)
+	_, err = parseCurve("10:250")
+	assert.EqualError(t, err, `expected level=kW, got "10:250"`)
+}
+
+func TestCurveMessage(t *testing.T) {
+	reference := chargeCurve{10: 250, 30: 180, 50: 120}
+	kw, ok := reference.at(20)
+	assert.True(t, ok)
+	assert.Equal(t, 215.0, kw)
+	_, ok = reference.at(60)
+	assert.False(t, ok)
+
+	slow := chargeCurve{10: 212, 20: 183, 30: 153, 80: 50}
+	assert.Equal(t, "\n📉 ~15% below the reference charging curve", curveMessage(slow, reference))
+	typical := chargeCurve{10: 245, 20: 210, 30: 185}
+	assert.Equal(t, "", curveMessage(typical, reference))
+	assert.Equal(t, "", curveMessage(chargeCurve{10: 100}, reference))
+}
+
+func TestSampleEnergy(t *testing.T) {
+	at := time.Date(2021, 4, 9, 1, 30, 0, 0, time.UTC)
+	car := &Car{}
+	car.ChargeStart = CarState{At: at, ChargeEnergyAdded: 0}
+	car.State = CarState{At: at.Add(time.Minute), ChargeEnergyAdded: 0.2}
+	car.sampleEnergy()
+	car.State.At = at.Add(2 * time.Minute)
+	car.sampleEnergy()
+	assert.Equal(t, []energySample{{at, 0}, {at.Add(time.Minute), 0.2}}, car.energy)
+}
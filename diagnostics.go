@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"runtime"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Caps on state a misbehaving broker could otherwise grow without bound.
+const (
+	MaxCars           = 32
+	MaxHistoryRecords = 5000
+)
+
+var droppedMessages int64
+
+// Messages rejected as corrupt, see teslamatebridge.Validate.
+var quarantinedMessages int64
+
+// quarantine counts a corrupt message, logging the first few and then a
+// sample so a broken publisher can't flood the log.
+func quarantine(topic string, err error) {
+	n := atomic.AddInt64(&quarantinedMessages, 1)
+	if n <= 10 || n%100 == 0 {
+		log.Printf("Quarantined %s (%d so far): %s", topic, n, err)
+	}
+}
+
+// Stats is a snapshot of the bridge's internal state, taken by the main loop
+// so it can be read from other goroutines.
+type Stats struct {
+	Cars         int
+	Charges      int
+	Calibrations int
+	CarBacklog   int
+	CarCapacity  int
+	GridBacklog  int
+	GridCapacity int
+}
+
+type Diagnostics struct {
+	mu       sync.Mutex
+	stats    Stats
+	watchdog *Watchdog
+}
+
+func collectStats(cars map[int]*Car, carUpdates chan *Car, gridUpdates chan float64) Stats {
+	stats := Stats{
+		Cars:         len(cars),
+		CarBacklog:   len(carUpdates),
+		CarCapacity:  cap(carUpdates),
+		GridBacklog:  len(gridUpdates),
+		GridCapacity: cap(gridUpdates),
+	}
+	for _, car := range cars {
+		stats.Charges += len(car.charges)
+		stats.Calibrations += len(car.calibrations)
+	}
+	return stats
+}
+
+func (d *Diagnostics) Update(stats Stats) {
+	d.mu.Lock()
+	d.stats = stats
+	d.mu.Unlock()
+}
+
+func (d *Diagnostics) Stats() Stats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
+
+func debugStatsMessage(stats Stats) string {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	return fmt.Sprintf("🛠 Debug stats\nGoroutines: %d\nHeap: %.1fMB\nCars: %d\nCharge history: %d\nCalibrations: %d\nCar update backlog: %d/%d\nGrid update backlog: %d/%d\nDropped messages: %d\nQuarantined messages: %d",
+		runtime.NumGoroutine(), float64(mem.HeapAlloc)/1e6,
+		stats.Cars, stats.Charges, stats.Calibrations,
+		stats.CarBacklog, stats.CarCapacity, stats.GridBacklog, stats.GridCapacity,
+		atomic.LoadInt64(&droppedMessages), atomic.LoadInt64(&quarantinedMessages))
+}
+
+// ServeHTTP exposes the stats in the Prometheus text format.
+func (d *Diagnostics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	stats := d.Stats()
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# TYPE teslamate_telegram_goroutines gauge\nteslamate_telegram_goroutines %d\n", runtime.NumGoroutine())
+	fmt.Fprintf(w, "# TYPE teslamate_telegram_heap_bytes gauge\nteslamate_telegram_heap_bytes %d\n", mem.HeapAlloc)
+	fmt.Fprintf(w, "# TYPE teslamate_telegram_cars gauge\nteslamate_telegram_cars %d\n", stats.Cars)
+	fmt.Fprintf(w, "# TYPE teslamate_telegram_history_records gauge\n")
+	fmt.Fprintf(w, "teslamate_telegram_history_records{kind=\"charge\"} %d\n", stats.Charges)
+	fmt.Fprintf(w, "teslamate_telegram_history_records{kind=\"calibration\"} %d\n", stats.Calibrations)
+	fmt.Fprintf(w, "# TYPE teslamate_telegram_queue_length gauge\n")
+	fmt.Fprintf(w, "teslamate_telegram_queue_length{queue=\"car_updates\"} %d\n", stats.CarBacklog)
+	fmt.Fprintf(w, "teslamate_telegram_queue_length{queue=\"grid_updates\"} %d\n", stats.GridBacklog)
+	fmt.Fprintf(w, "# TYPE teslamate_telegram_dropped_messages_total counter\nteslamate_telegram_dropped_messages_total %d\n", atomic.LoadInt64(&droppedMessages))
+	fmt.Fprintf(w, "# TYPE teslamate_telegram_quarantined_messages_total counter\nteslamate_telegram_quarantined_messages_total %d\n", atomic.LoadInt64(&quarantinedMessages))
+	if d.watchdog != nil {
+		fmt.Fprintf(w, "# TYPE teslamate_telegram_update_poll_age_seconds gauge\n")
+		ages := d.watchdog.Ages(time.Now())
+		bots := make([]string, 0, len(ages))
+		for bot := range ages {
+			bots = append(bots, bot)
+		}
+		sort.Strings(bots)
+		for _, bot := range bots {
+			fmt.Fprintf(w, "teslamate_telegram_update_poll_age_seconds{bot=%q} %.0f\n", bot, ages[bot].Seconds())
+		}
+	}
+}
+
+func (d *Diagnostics) Listen(addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", d)
+	mux.HandleFunc("/api/schema", serveSchema)
+	if d.watchdog != nil {
+		mux.Handle("/healthz", d.watchdog)
+	}
+	go func() {
+		log.Fatal(http.ListenAndServe(addr, mux))
+	}()
+	log.Println("Serving metrics, /healthz and /api/schema on", addr)
+}
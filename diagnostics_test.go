@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectStats(t *testing.T) {
+	cars := map[int]*Car{1: {charges: make([]chargeRecord, 3)}, 2: {calibrations: make([]calibrationRecord, 1)}}
+	carUpdates := make(chan *Car, 1)
+	carUpdates <- cars[1]
+	stats := collectStats(cars, carUpdates, make(chan float64, 1))
+	assert.Equal(t, Stats{Cars: 2, Charges: 3, Calibrations: 1, CarBacklog: 1, CarCapacity: 1, GridCapacity: 1}, stats)
+}
+
+func TestMetrics(t *testing.T) {
+	d := &Diagnostics{}
+	d.Update(Stats{Cars: 2, Charges: 3})
+	w := httptest.NewRecorder()
+	d.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "\nteslamate_telegram_cars 2\n")
+	assert.Contains(t, w.Body.String(), "\nteslamate_telegram_history_records{kind=\"charge\"} 3\n")
+}
+
+func TestPruneBounded(t *testing.T) {
+	car := &Car{charges: make([]chargeRecord, MaxHistoryRecords)}
+	car.recordCharge(chargeRecord{geofence: "Home"})
+	assert.Len(t, car.charges, MaxHistoryRecords)
+	assert.Equal(t, "Home", car.charges[MaxHistoryRecords-1].geofence)
+}
+
+func TestQuarantine(t *testing.T) {
+	before := atomic.LoadInt64(&quarantinedMessages)
+	quarantine("teslamate/cars/1/battery_level", errors.New("battery_level: 101 out of range 0 to 100"))
+	assert.Equal(t, before+1, atomic.LoadInt64(&quarantinedMessages))
+	w := httptest.NewRecorder()
+	(&Diagnostics{}).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Contains(t, w.Body.String(), "\nteslamate_telegram_quarantined_messages_total ")
+}
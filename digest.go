@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Digest counts the notifications sent to each chat in the current minute.
+// Past the limit, further ones are only counted, then summed up in one
+// message when the minute ends, so flapping data can't flood a chat or run
+// into Telegram's rate limits. Drive and charge summaries can't be told
+// again later, so they're always sent.
+type Digest struct {
+	Limit  int // per chat per minute, 0 for no limit
+	sent   map[int64]int
+	counts map[int64]map[string]int // held back, by event type
+}
+
+// Summaries sent past the limit, though they count towards it.
+var digestExempt = map[string]bool{"drive_finished": true, "charge_finished": true}
+
+// Allow reports whether a notification can be sent to a chat now, counting
+// it for the digest if not.
+func (d *Digest) Allow(chatID int64, eventType string) bool {
+	if d.Limit == 0 {
+		return true
+	}
+	if d.sent == nil {
+		d.sent, d.counts = map[int64]int{}, map[int64]map[string]int{}
+	}
+	if d.sent[chatID] < d.Limit || digestExempt[eventType] {
+		d.sent[chatID]++
+		return true
+	}
+	if d.counts[chatID] == nil {
+		d.counts[chatID] = map[string]int{}
+	}
+	d.counts[chatID][eventType]++
+	return false
+}
+
+// Flush starts a new minute, returning the digest for each chat that had
+// notifications held back.
+func (d *Digest) Flush() map[int64]string {
+	digests := map[int64]string{}
+	for chatID, counts := range d.counts {
+		digests[chatID] = digestMessage(counts)
+	}
+	d.sent, d.counts = nil, nil
+	return digests
+}
+
+func digestMessage(counts map[string]int) string {
+	types := make([]string, 0, len(counts))
+	total := 0
+	for eventType, n := range counts {
+		types = append(types, eventType)
+		total += n
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if counts[types[i]] != counts[types[j]] {
+			return counts[types[i]] > counts[types[j]]
+		}
+		return types[i] < types[j]
+	})
+	parts := make([]string, len(types))
+	for i, eventType := range types {
+		parts[i] = fmt.Sprintf("%d %s", counts[eventType], strings.Replace(eventType, "_", " ", -1))
+	}
+	return fmt.Sprintf("📨 %s held back in the last minute: %s", plural(total, "notification"), strings.Join(parts, ", "))
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigest(t *testing.T) {
+	d := &Digest{Limit: 2}
+	assert.True(t, d.Allow(1, "charge_started"))
+	assert.True(t, d.Allow(1, "charge_finished"))
+	assert.True(t, d.Allow(2, "charge_started"))
+	assert.False(t, d.Allow(1, "charge_started"))
+	assert.False(t, d.Allow(1, "charge_milestone"))
+	assert.False(t, d.Allow(1, "charge_started"))
+	assert.True(t, d.Allow(1, "drive_finished"), "summaries aren't held back")
+	assert.Equal(t, map[int64]string{1: "📨 3 notifications held back in the last minute: 2 charge started, 1 charge milestone"}, d.Flush())
+	assert.True(t, d.Allow(1, "charge_started"))
+	assert.Empty(t, (&Digest{}).Flush())
+	assert.True(t, (&Digest{}).Allow(1, "towed"))
+}
+
+func TestDigestConfig(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{}))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, c.DigestLimit)
+	c, err = parseConfig(lookupMap(map[string]string{"DIGEST_LIMIT": "0"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, c.DigestLimit)
+}
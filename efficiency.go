@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rated km per kWh by TeslaMate car ID, from RATED_KM_PER_KWH. Cars not
+// listed use what's been learned from their charges, or RatedKMPerKwh.
+var carKMPerKwh = map[int]float32{}
+
+// Fits learned from charges by car ID, persisted in the store.
+var learnedKMPerKwh = map[int]*EfficiencyFit{}
+
+// Charges needed before a learned fit replaces the default.
+const MinLearnCharges = 3
+
+// EfficiencyFit learns a car's rated km per kWh as the least squares slope,
+// through the origin, of rated range gained against energy added over its
+// charges.
+type EfficiencyFit struct {
+	Charges int     `json:"charges"`
+	SumXY   float64 `json:"sum_xy"` // kWh × km
+	SumXX   float64 `json:"sum_xx"` // kWh²
+}
+
+// Add records a charge, ignoring small ones and implausible ratios, such as
+// from a recalibration the bridge didn't catch. It reports whether the
+// charge was used.
+func (f *EfficiencyFit) Add(kWh, km float32) bool {
+	if kWh < 2 || km/kWh < 3 || km/kWh > 12 {
+		return false
+	}
+	f.Charges++
+	f.SumXY += float64(kWh) * float64(km)
+	f.SumXX += float64(kWh) * float64(kWh)
+	return true
+}
+
+// KMPerKwh is the learned factor, once there are enough charges.
+func (f *EfficiencyFit) KMPerKwh() (float32, bool) {
+	if f == nil || f.Charges < MinLearnCharges {
+		return 0, false
+	}
+	return float32(f.SumXY / f.SumXX), true
+}
+
+// learnEfficiency adds a finished charge to the car's fit, reporting
+// whether it changed.
+func (car *Car) learnEfficiency(start, end CarState) bool {
+	fit, ok := learnedKMPerKwh[car.id]
+	if !ok {
+		fit = &EfficiencyFit{}
+	}
+	if !fit.Add(end.ChargeEnergyAdded-start.ChargeEnergyAdded, end.RatedBatteryRangeKm-start.RatedBatteryRangeKm) {
+		return false
+	}
+	learnedKMPerKwh[car.id] = fit
+	return true
+}
+
+// parseCarEfficiency parses RATED_KM_PER_KWH, e.g. "1=7.47;2=6.9".
+func parseCarEfficiency(value string) (map[int]float32, error) {
+	factors := map[int]float32{}
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ' ' }) {
+		i := strings.Index(entry, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("expected car_id=km_per_kwh, got %q", entry)
+		}
+		carID, err := strconv.Atoi(entry[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid car id %q", entry[:i])
+		}
+		factor, err := strconv.ParseFloat(entry[i+1:], 32)
+		if err != nil || factor <= 0 {
+			return nil, fmt.Errorf("invalid km per kWh %q", entry[i+1:])
+		}
+		factors[carID] = float32(factor)
+	}
+	return factors, nil
+}
+
+// kmPerKwh is the rated range the car gets from a kWh.
+func (car *Car) kmPerKwh() float32 {
+	if factor, ok := carKMPerKwh[car.id]; ok {
+		return factor
+	}
+	if factor, ok := learnedKMPerKwh[car.id].KMPerKwh(); ok {
+		return factor
+	}
+	return RatedKMPerKwh
+}
+
+// driveEfficiency is a drive's energy used per distance unit, in Wh.
+func driveEfficiency(d driveRecord, kmPerKwh float32) float32 {
+	return d.ratedKmUsed / kmPerKwh * 1000 / distance(d.distanceKm)
+}
+
+// efficiencyMessage answers /efficiency with the average over the last 7
+// and 30 days and the best and worst drives of the 30. Drives under a
+// kilometre are left out as too short to measure.
+func efficiencyMessage(car *Car, now time.Time) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	kmPerKwh := car.kmPerKwh()
+	text := "⚡ Efficiency"
+	var best, worst *driveRecord
+	for _, days := range []int{7, 30} {
+		since := now.AddDate(0, 0, -days)
+		var drives int
+		var distanceKm, ratedKmUsed float32
+		for i, d := range car.drives {
+			if d.at.Before(since) || d.distanceKm < 1 {
+				continue
+			}
+			drives++
+			distanceKm += d.distanceKm
+			ratedKmUsed += d.ratedKmUsed
+			if best == nil || driveEfficiency(d, kmPerKwh) < driveEfficiency(*best, kmPerKwh) {
+				best = &car.drives[i]
+			}
+			if worst == nil || driveEfficiency(d, kmPerKwh) > driveEfficiency(*worst, kmPerKwh) {
+				worst = &car.drives[i]
+			}
+		}
+		if drives == 0 {
+			text += fmt.Sprintf("\nLast %d days: no drives", days)
+			continue
+		}
+		text += fmt.Sprintf("\nLast %d days: %.0f%s over %s", days,
+			ratedKmUsed/kmPerKwh*1000/distance(distanceKm), efficiencyUnit(), plural(drives, "drive"))
+	}
+	if best != nil && best != worst {
+		text += fmt.Sprintf("\nBest: %.0f%s, %s→%s on %s", driveEfficiency(*best, kmPerKwh), efficiencyUnit(),
+			best.from, best.to, best.at.In(location).Format("Mon 2 Jan"))
+		text += fmt.Sprintf("\nWorst: %.0f%s, %s→%s on %s", driveEfficiency(*worst, kmPerKwh), efficiencyUnit(),
+			worst.from, worst.to, worst.at.In(location).Format("Mon 2 Jan"))
+	}
+	return text + rainEfficiencyMessage(car, now)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCarEfficiency(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{"RATED_KM_PER_KWH": "1=7.47;2=6.2"}))
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]float32{1: 7.47, 2: 6.2}, c.CarKMPerKwh)
+	_, err = parseConfig(lookupMap(map[string]string{"RATED_KM_PER_KWH": "1=0"}))
+	assert.EqualError(t, err, `invalid RATED_KM_PER_KWH: invalid km per kWh "0"`)
+	_, err = parseConfig(lookupMap(map[string]string{"RATED_KM_PER_KWH": "7.47"}))
+	assert.EqualError(t, err, `invalid RATED_KM_PER_KWH: expected car_id=km_per_kwh, got "7.47"`)
+
+	defer func(f map[int]float32) { carKMPerKwh = f }(carKMPerKwh)
+	carKMPerKwh = c.CarKMPerKwh
+	assert.Equal(t, float32(6.2), (&Car{id: 2}).kmPerKwh())
+	assert.Equal(t, float32(RatedKMPerKwh), (&Car{id: 3}).kmPerKwh())
+
+	start := CarState{Odometer: 976, RatedBatteryRangeKm: 400}
+	end := CarState{Odometer: 986, RatedBatteryRangeKm: 390}
+	assert.InDelta(t, 260, efficiency(start, end, 6.2), 1)
+}
+
+func TestLearnEfficiency(t *testing.T) {
+	defer func(l map[int]*EfficiencyFit) { learnedKMPerKwh = l }(learnedKMPerKwh)
+	learnedKMPerKwh = map[int]*EfficiencyFit{}
+	car := &Car{id: 1}
+	charge := func(kWh, km float32) bool {
+		return car.learnEfficiency(CarState{RatedBatteryRangeKm: 200}, CarState{ChargeEnergyAdded: kWh, RatedBatteryRangeKm: 200 + km})
+	}
+	assert.True(t, charge(10, 62))
+	assert.False(t, charge(1, 6), "too small")
+	assert.False(t, charge(10, 200), "implausible")
+	assert.True(t, charge(20, 124))
+	assert.Equal(t, float32(RatedKMPerKwh), car.kmPerKwh(), "not enough charges yet")
+	assert.True(t, charge(30, 186))
+	assert.InDelta(t, 6.2, car.kmPerKwh(), 0.001)
+}
+
+func TestEfficiencyMessage(t *testing.T) {
+	now := time.Date(2021, 4, 9, 12, 0, 0, 0, time.UTC)
+	car := &Car{}
+	assert.Equal(t, "⚡ Efficiency\nLast 7 days: no drives\nLast 30 days: no drives", efficiencyMessage(car, now))
+	at := now.AddDate(0, 0, -10)
+	car.recordDrive(CarState{At: at, Odometer: 900, RatedBatteryRangeKm: 400}, CarState{At: at, Odometer: 950, RatedBatteryRangeKm: 340}, "Home", "Shop", NoPrecipitation)
+	at = now.AddDate(0, 0, -2)
+	car.recordDrive(CarState{At: at, Odometer: 976, RatedBatteryRangeKm: 400}, CarState{At: at, Odometer: 986, RatedBatteryRangeKm: 390}, "Home", "Work", NoPrecipitation)
+	car.recordDrive(CarState{At: at, Odometer: 986, RatedBatteryRangeKm: 390}, CarState{At: at, Odometer: 986.5, RatedBatteryRangeKm: 389}, "Work", "Cafe", NoPrecipitation)
+	assert.Equal(t, "⚡ Efficiency\nLast 7 days: 216Wh/mi over 1 drive\nLast 30 days: 251Wh/mi over 2 drives\nBest: 216Wh/mi, Home→Work on Wed 7 Apr\nWorst: 259Wh/mi, Home→Shop on Tue 30 Mar", efficiencyMessage(car, now))
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// EVSE is an optional smart wallbox (e.g. OpenEVSE) reachable over MQTT.
+type EVSE struct {
+	energyTopic   string
+	energyScale   float32
+	commandTopic  string
+	pausePayload  string
+	resumePayload string
+
+	mu     sync.Mutex
+	energy float32 // kWh as last reported by the wallbox
+}
+
+// newEVSE returns the configured EVSE, or nil if the integration is disabled.
+func newEVSE(config *Config) *EVSE {
+	e := &EVSE{
+		energyTopic:   config.EVSEEnergyTopic,
+		energyScale:   1,
+		commandTopic:  config.EVSECommandTopic,
+		pausePayload:  config.EVSEPausePayload,
+		resumePayload: config.EVSEResumePayload,
+	}
+	if e.energyTopic == "" && e.commandTopic == "" {
+		return nil
+	}
+	if config.EVSEEnergyUnit == "Wh" {
+		e.energyScale = 0.001
+	}
+	return e
+}
+
+func (e *EVSE) Subscribe(client mqtt.Client) error {
+	if e.energyTopic == "" {
+		return nil
+	}
+	token := client.Subscribe(e.energyTopic, 0, func(client mqtt.Client, msg mqtt.Message) {
+		fvalue, err := strconv.ParseFloat(string(msg.Payload()), 32)
+		if err != nil {
+			log.Println("Failed to parse EVSE energy:", string(msg.Payload()))
+			return
+		}
+		e.mu.Lock()
+		e.energy = float32(fvalue) * e.energyScale
+		e.mu.Unlock()
+	})
+	token.Wait()
+	return token.Error()
+}
+
+func (e *EVSE) Energy() float32 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.energy
+}
+
+func (e *EVSE) Pause(client mqtt.Client) error {
+	return e.command(client, e.pausePayload)
+}
+
+func (e *EVSE) Resume(client mqtt.Client) error {
+	return e.command(client, e.resumePayload)
+}
+
+func (e *EVSE) command(client mqtt.Client, payload string) error {
+	if e.commandTopic == "" {
+		return errors.New("EVSE_COMMAND_TOPIC not set")
+	}
+	token := client.Publish(e.commandTopic, 1, false, payload)
+	token.Wait()
+	return token.Error()
+}
+
+// evseEnergyMessage describes the energy the wallbox measured over a charge
+// session, alongside the charging efficiency compared to what the car added.
+func evseEnergyMessage(carEnergy, start, end float32) string {
+	energy := end - start
+	if energy <= 0 {
+		// wallbox reset its session counter when the charge started
+		energy = end
+	}
+	if energy <= 0 {
+		return ""
+	}
+	text := fmt.Sprintf("\n🏠 Wallbox: %.1fkWh", energy)
+	if carEnergy > 0 {
+		text += fmt.Sprintf(" (%.0f%% efficiency)", carEnergy/energy*100)
+	}
+	return text
+}
+
+func evseCommand(e *EVSE, client mqtt.Client, args string) string {
+	if e == nil {
+		return "EVSE integration is not configured."
+	}
+	var err error
+	switch args {
+	case "pause":
+		err = e.Pause(client)
+	case "resume":
+		err = e.Resume(client)
+	default:
+		return "Usage: /evse pause|resume"
+	}
+	if err != nil {
+		log.Println("EVSE command failed:", err)
+		return fmt.Sprintf("⚠️ EVSE %s failed: %s", args, err)
+	}
+	return fmt.Sprintf("🏠 EVSE %s sent", args)
+}
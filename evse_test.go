@@ -0,0 +1,14 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEVSEEnergyMessage(t *testing.T) {
+	assert.Equal(t, "\n🏠 Wallbox: 10.0kWh (90% efficiency)", evseEnergyMessage(9, 120, 130))
+	assert.Equal(t, "\n🏠 Wallbox: 4.2kWh", evseEnergyMessage(0, 0, 4.2))
+	assert.Equal(t, "\n🏠 Wallbox: 5.0kWh (80% efficiency)", evseEnergyMessage(4, 20, 5))
+	assert.Equal(t, "", evseEnergyMessage(4, 0, 0))
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parseExportArgs parses /export's kind and optional period, which
+// defaults to the last month.
+func parseExportArgs(args string) (kind, period string, err error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || len(fields) > 2 || (fields[0] != "drives" && fields[0] != "charges") {
+		return "", "", fmt.Errorf("expected drives or charges")
+	}
+	kind, period = fields[0], "month"
+	if len(fields) == 2 {
+		if _, ok := placesPeriods[fields[1]]; !ok {
+			return "", "", fmt.Errorf("unknown period %q", fields[1])
+		}
+		period = fields[1]
+	}
+	return kind, period, nil
+}
+
+func formatFloat(f float32, precision int) string {
+	return strconv.FormatFloat(float64(f), 'f', precision, 32)
+}
+
+// exportCSV writes the car's drives or charges over the period as CSV, with
+// distances in the configured units.
+func exportCSV(car *Car, kind, period string, now time.Time) ([]byte, int) {
+	var since time.Time
+	if d := placesPeriods[period]; d != 0 {
+		since = now.Add(-d)
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	rows := 0
+	if kind == "drives" {
+		unit := distanceUnit()
+		speedColumn := strings.ReplaceAll(speedUnit(), "/", "")
+		w.Write([]string{"start", "end", "from", "to", "distance_" + unit, "rated_range_used_" + unit, "kwh", "outside_temp", "avg_speed_" + speedColumn, "max_speed_" + speedColumn})
+		kmPerKwh := car.kmPerKwh()
+		for _, d := range car.drives {
+			if d.at.Before(since) {
+				continue
+			}
+			maxSpeed := ""
+			if d.maxSpeedKmh > 0 {
+				maxSpeed = formatFloat(speed(float32(d.maxSpeedKmh)), 0)
+			}
+			w.Write([]string{d.at.In(location).Format(time.RFC3339), d.until.In(location).Format(time.RFC3339), d.from, d.to,
+				formatFloat(distance(d.distanceKm), 1), formatFloat(distance(d.ratedKmUsed), 1), formatFloat(d.ratedKmUsed/kmPerKwh, 2),
+				formatFloat(temperature(d.outsideTemp), 1), formatFloat(speed(d.averageSpeedKmh()), 0), maxSpeed})
+			rows++
+		}
+	} else {
+		w.Write([]string{"start", "end", "place", "start_level", "end_level", "kwh_added", "cost"})
+		for _, c := range car.charges {
+			if c.at.Before(since) {
+				continue
+			}
+			w.Write([]string{c.at.In(location).Format(time.RFC3339), c.until.In(location).Format(time.RFC3339), c.place,
+				strconv.Itoa(c.startLevel), strconv.Itoa(c.endLevel), formatFloat(c.energyAdded, 2), formatFloat(c.cost, 2)})
+			rows++
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), rows
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseExportArgs(t *testing.T) {
+	kind, period, err := parseExportArgs("drives")
+	assert.NoError(t, err)
+	assert.Equal(t, "drives", kind)
+	assert.Equal(t, "month", period)
+	kind, period, err = parseExportArgs("charges all")
+	assert.NoError(t, err)
+	assert.Equal(t, "charges", kind)
+	assert.Equal(t, "all", period)
+	_, _, err = parseExportArgs("visits")
+	assert.Error(t, err)
+	_, _, err = parseExportArgs("drives decade")
+	assert.Error(t, err)
+}
+
+func TestExportCSV(t *testing.T) {
+	now := time.Date(2021, 4, 9, 12, 0, 0, 0, time.UTC)
+	car := &Car{}
+	at := now.Add(-48 * time.Hour)
+	car.recordDrive(CarState{At: at.AddDate(0, -2, 0), Odometer: 900}, CarState{At: at.AddDate(0, -2, 0), Odometer: 910}, "Home", "Shop", NoPrecipitation)
+	car.recordDrive(CarState{At: at, Odometer: 976, RatedBatteryRangeKm: 400, OutsideTemp: 7.5}, CarState{At: at.Add(8 * time.Minute), Odometer: 986, RatedBatteryRangeKm: 390}, "Home", "Work, Unit 4", NoPrecipitation)
+	data, rows := exportCSV(car, "drives", "month", now)
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, "start,end,from,to,distance_miles,rated_range_used_miles,kwh,outside_temp,avg_speed_mph,max_speed_mph\n"+
+		"2021-04-07T12:00:00Z,2021-04-07T12:08:00Z,Home,\"Work, Unit 4\",6.2,6.2,1.34,7.5,47,\n", string(data))
+	_, rows = exportCSV(car, "drives", "all", now)
+	assert.Equal(t, 2, rows)
+
+	car.recordCharge(chargeRecord{at: at, until: at.Add(90 * time.Minute), place: "Home", startLevel: 50, endLevel: 80, energyAdded: 8, cost: 2})
+	data, rows = exportCSV(car, "charges", "week", now)
+	assert.Equal(t, 1, rows)
+	assert.Equal(t, "start,end,place,start_level,end_level,kwh_added,cost\n2021-04-07T12:00:00Z,2021-04-07T13:30:00Z,Home,50,80,8.00,2.00\n", string(data))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var featureDescriptions = map[string]string{
+	"geocoding": "Reverse geocode place names",
+	"calendar":  "Battery warnings for calendar trips",
+	"carbon":    "Grid carbon intensity",
+	"costs":     "Charge costs from the tariff or prices",
+	"evse":      "Wallbox energy and commands",
+	"loadshed":  "Grid import limit warnings",
+	"location":  "Car position with /location",
+	"receipts":  "PDF receipts for charges",
+	"voice":     "Voice notes of the scheduled status",
+	"weather":   "Rain at the end of drives",
+}
+
+// Features are optional subsystems that can be switched off at runtime.
+// Toggles override the configured defaults, survive a config reload and are
+// kept in the store across restarts.
+type Features struct {
+	configured map[string]bool
+	overrides  map[string]bool
+}
+
+var features = &Features{configured: map[string]bool{"geocoding": true, "costs": true, "location": true}, overrides: map[string]bool{}}
+
+func (f *Features) configure(config *Config) {
+	f.configured = map[string]bool{
+		"geocoding": config.Geocoding,
+		"calendar":  config.CalendarURL != "",
+		"carbon":    config.CarbonIntensity,
+		"costs":     true,
+		"evse":      config.EVSEEnergyTopic != "" || config.EVSECommandTopic != "",
+		"loadshed":  config.GridImportTopic != "",
+		"location":  true,
+		"receipts":  config.Receipts,
+		"voice":     config.TTSURL != "",
+		"weather":   config.Weather,
+	}
+}
+
+func (f *Features) Enabled(name string) bool {
+	if on, ok := f.overrides[name]; ok {
+		return on
+	}
+	return f.configured[name]
+}
+
+func (f *Features) Set(name string, on bool) error {
+	if _, ok := featureDescriptions[name]; !ok {
+		return fmt.Errorf("unknown feature %s", name)
+	}
+	f.overrides[name] = on
+	return nil
+}
+
+func settingsCommand(f *Features, args string) string {
+	fields := strings.Fields(args)
+	if len(fields) == 2 && (fields[1] == "on" || fields[1] == "off") {
+		if err := f.Set(fields[0], fields[1] == "on"); err != nil {
+			return fmt.Sprintf("⚠️ %s", err)
+		}
+	} else if len(fields) > 0 {
+		return "Usage: /settings [feature on|off]"
+	}
+	return settingsMessage(f)
+}
+
+func settingsMessage(f *Features) string {
+	names := make([]string, 0, len(featureDescriptions))
+	for name := range featureDescriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	text := "⚙️ Features"
+	for _, name := range names {
+		mark := "❌"
+		if f.Enabled(name) {
+			mark = "✅"
+		}
+		text += fmt.Sprintf("\n%s %s: %s", mark, name, featureDescriptions[name])
+	}
+	return text + "\nToggle with /settings <feature> on|off"
+}
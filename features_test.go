@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatures(t *testing.T) {
+	f := &Features{overrides: map[string]bool{}}
+	f.configure(&Config{Geocoding: true, GridImportTopic: "home/grid"})
+	assert.True(t, f.Enabled("geocoding"))
+	assert.True(t, f.Enabled("loadshed"))
+	assert.False(t, f.Enabled("carbon"))
+	assert.False(t, f.Enabled("voice"))
+	assert.True(t, f.Enabled("costs"))
+
+	assert.NoError(t, f.Set("geocoding", false))
+	f.configure(&Config{Geocoding: true, CarbonIntensity: true})
+	assert.False(t, f.Enabled("geocoding"))
+	assert.True(t, f.Enabled("carbon"))
+	assert.EqualError(t, f.Set("charts", true), "unknown feature charts")
+}
+
+func TestSettingsCommand(t *testing.T) {
+	f := &Features{overrides: map[string]bool{}}
+	f.configure(&Config{Geocoding: true})
+	assert.Equal(t, "⚙️ Features\n❌ calendar: Battery warnings for calendar trips\n✅ carbon: Grid carbon intensity\n✅ costs: Charge costs from the tariff or prices\n❌ evse: Wallbox energy and commands\n✅ geocoding: Reverse geocode place names\n❌ loadshed: Grid import limit warnings\n✅ location: Car position with /location\n❌ receipts: PDF receipts for charges\n❌ voice: Voice notes of the scheduled status\n❌ weather: Rain at the end of drives\nToggle with /settings <feature> on|off", settingsCommand(f, "carbon on"))
+	assert.Equal(t, "Usage: /settings [feature on|off]", settingsCommand(f, "carbon"))
+	assert.Equal(t, "⚠️ unknown feature charts", settingsCommand(f, "charts on"))
+}
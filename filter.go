@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.starlark.net/starlark"
+)
+
+// Filter is a user Starlark script defining filter(event), run against each
+// outgoing notification. It returns None or False to suppress the message,
+// True to send it unchanged, a string to replace the text, or a dict with
+// optional "message" and "chat_id" keys to rewrite and reroute it.
+type Filter struct {
+	fn starlark.Callable
+}
+
+func loadFilter(path string) (*Filter, error) {
+	thread := &starlark.Thread{Name: "load"}
+	globals, err := starlark.ExecFile(thread, path, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	fn, ok := globals["filter"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("%s: no filter(event) function", path)
+	}
+	return &Filter{fn: fn}, nil
+}
+
+// Apply runs the filter, returning the text to send and the chat to send it
+// to (0 for the default). ok is false if the message is suppressed.
+func (f *Filter) Apply(event Event) (text string, chatID int64, ok bool, err error) {
+	arg, err := toStarlark(event)
+	if err != nil {
+		return "", 0, false, err
+	}
+	thread := &starlark.Thread{Name: "filter"}
+	result, err := starlark.Call(thread, f.fn, starlark.Tuple{arg}, nil)
+	if err != nil {
+		return "", 0, false, err
+	}
+	text = event.Message
+	switch r := result.(type) {
+	case starlark.NoneType:
+		return "", 0, false, nil
+	case starlark.Bool:
+		return text, 0, bool(r), nil
+	case starlark.String:
+		return string(r), 0, true, nil
+	case *starlark.Dict:
+		if v, found, _ := r.Get(starlark.String("message")); found {
+			s, isString := starlark.AsString(v)
+			if !isString {
+				return "", 0, false, fmt.Errorf("filter message must be a string, got %s", v.Type())
+			}
+			text = s
+		}
+		if v, found, _ := r.Get(starlark.String("chat_id")); found {
+			if err := starlark.AsInt(v, &chatID); err != nil {
+				return "", 0, false, fmt.Errorf("filter chat_id: %s", err)
+			}
+		}
+		return text, chatID, true, nil
+	}
+	return "", 0, false, fmt.Errorf("filter returned %s", result.Type())
+}
+
+// toStarlark converts a value to Starlark via its JSON representation.
+func toStarlark(v interface{}) (starlark.Value, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(payload, &decoded); err != nil {
+		return nil, err
+	}
+	return fromJSON(decoded), nil
+}
+
+func fromJSON(v interface{}) starlark.Value {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		dict := starlark.NewDict(len(v))
+		for key, value := range v {
+			dict.SetKey(starlark.String(key), fromJSON(value))
+		}
+		return dict
+	case []interface{}:
+		list := make([]starlark.Value, len(v))
+		for i, value := range v {
+			list[i] = fromJSON(value)
+		}
+		return starlark.NewList(list)
+	case string:
+		return starlark.String(v)
+	case float64:
+		if v == float64(int64(v)) {
+			return starlark.MakeInt64(int64(v))
+		}
+		return starlark.Float(v)
+	case bool:
+		return starlark.Bool(v)
+	}
+	return starlark.None
+}
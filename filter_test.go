@@ -0,0 +1,61 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testFilter = `
+def filter(event):
+    if event["type"] == "plugged_in":
+        return None
+    if event["type"] == "drive_finished" and event["end"]["geofence"] == "Work":
+        return {"chat_id": -1001, "message": "At work: " + event["message"]}
+    if event["end"]["battery_level"] < 20:
+        return event["message"] + " ⚠️ low battery"
+    return True
+`
+
+func writeFilter(t *testing.T, script string) (*Filter, error) {
+	dir, err := ioutil.TempDir("", "filter")
+	assert.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	path := filepath.Join(dir, "filter.star")
+	ioutil.WriteFile(path, []byte(script), 0644)
+	return loadFilter(path)
+}
+
+func TestFilter(t *testing.T) {
+	f, err := writeFilter(t, testFilter)
+	assert.NoError(t, err)
+
+	_, _, ok, err := f.Apply(Event{Type: "plugged_in", End: &CarState{}})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	text, chatID, ok, err := f.Apply(Event{Type: "drive_finished", End: &CarState{Geofence: "Work", BatteryLevel: 50}, Message: "🚗"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(-1001), chatID)
+	assert.Equal(t, "At work: 🚗", text)
+
+	text, chatID, ok, err = f.Apply(Event{Type: "drive_finished", End: &CarState{BatteryLevel: 15}, Message: "🚗"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, int64(0), chatID)
+	assert.Equal(t, "🚗 ⚠️ low battery", text)
+
+	text, _, ok, err = f.Apply(Event{Type: "charge_finished", End: &CarState{BatteryLevel: 80}, Message: "🔌"})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "🔌", text)
+}
+
+func TestFilterMissingFunction(t *testing.T) {
+	_, err := writeFilter(t, "x = 1\n")
+	assert.Error(t, err)
+}
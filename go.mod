@@ -7,4 +7,5 @@ require (
 	github.com/go-telegram-bot-api/telegram-bot-api v4.6.4+incompatible
 	github.com/stretchr/testify v1.7.0
 	github.com/technoweenie/multipartstreamer v1.0.1 // indirect
+	go.starlark.net v0.0.0-20210901212718-87f333178d59
 )
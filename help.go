@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+type botCommand struct {
+	Command     string `json:"command"`
+	Description string `json:"description"`
+}
+
+// commands are registered with Telegram for autocompletion and listed by
+// /help, in this order.
+var commands = []botCommand{
+	{"status", "Battery, range and location"},
+	{"location", "Where the car is, on a map"},
+	{"odometer", "Current mileage"},
+	{"range", "Battery level and range"},
+	{"charge", "Live charging details"},
+	{"climate", "Temperatures and climate control"},
+	{"cars", "List the cars"},
+	{"car", "Choose the car commands are about"},
+	{"drives", "Recent drives"},
+	{"charges", "Recent charges"},
+	{"stats", "Weekly or monthly totals"},
+	{"efficiency", "Energy use over recent drives"},
+	{"battery", "Battery health and degradation"},
+	{"export", "Drives or charges as CSV"},
+	{"statement", "Monthly home charging for reimbursement"},
+	{"timeline", "What the car did today"},
+	{"places", "Where the car parks most"},
+	{"locations", "Where the car charges most"},
+	{"handover", "Summary for handing the car over"},
+	{"simulate", "Last month's charging on another tariff"},
+	{"schedule", "Send status on a cron schedule"},
+	{"watch", "Report when a field changes"},
+	{"quiet", "Set quiet hours for notifications"},
+	{"language", "Language for /status in this chat"},
+	{"evse", "Pause or resume the wallbox"},
+	{"settings", "Notifications, units and features"},
+	{"pair", "Create a link to add a user"},
+	{"allow", "Allow users or chats to use the bot"},
+	{"reload", "Reload the config file"},
+	{"debug", "Show internal statistics"},
+	{"selftest", "Check connections and storage"},
+	{"wipe", "Delete recorded history"},
+	{"start", "Change your setup"},
+	{"help", "List commands"},
+}
+
+// setCommands registers the commands with Telegram so clients autocomplete
+// them.
+func setCommands(bot *tgbotapi.BotAPI) error {
+	data, err := json.Marshal(commands)
+	if err != nil {
+		return err
+	}
+	_, err = bot.MakeRequest("setMyCommands", url.Values{"commands": {string(data)}})
+	return err
+}
+
+// helpMessage lists the commands available to a role.
+func helpMessage(role string) string {
+	var lines []string
+	for _, c := range commands {
+		if (adminCommands[c.Command] || c.Command == "start") && role != RoleAdmin {
+			continue
+		}
+		lines = append(lines, "/"+c.Command+" - "+c.Description)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHelpMessage(t *testing.T) {
+	viewer := helpMessage(RoleViewer)
+	assert.Equal(t, "/status - Battery, range and location\n/location - Where the car is, on a map\n/odometer - Current mileage\n/range - Battery level and range\n/charge - Live charging details\n/climate - Temperatures and climate control\n/cars - List the cars\n/car - Choose the car commands are about\n/drives - Recent drives\n/charges - Recent charges\n/stats - Weekly or monthly totals\n/efficiency - Energy use over recent drives\n/battery - Battery health and degradation\n/export - Drives or charges as CSV\n/statement - Monthly home charging for reimbursement\n/timeline - What the car did today\n/places - Where the car parks most\n/locations - Where the car charges most\n/handover - Summary for handing the car over\n/simulate - Last month's charging on another tariff\n/help - List commands", viewer)
+	admin := helpMessage(RoleAdmin)
+	assert.Contains(t, admin, "/wipe - Delete recorded history")
+	assert.Contains(t, admin, "/start - Change your setup")
+}
+
+func TestCommandsDescribed(t *testing.T) {
+	for command := range adminCommands {
+		found := false
+		for _, c := range commands {
+			found = found || c.Command == command
+		}
+		assert.True(t, found, command)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/barnybug/teslamate-telegram/pkg/teslamatebridge"
+)
+
+// Event is passed as JSON on stdin to the HOOK_COMMAND script, see
+// eventSchema.
+type Event = teslamatebridge.Event
+
+func carEvent(eventType teslamatebridge.EventType, car *Car, start, end CarState, text string) Event {
+	return Event{Type: eventType, CarID: car.id, Car: car.displayName, At: time.Now(), Start: &start, End: &end, Message: text}
+}
+
+// Hook runs a user script for each event, with the event type as its
+// argument. Anything the script prints is sent to the configured chat.
+type Hook struct {
+	command string
+	timeout time.Duration
+	replies chan<- string
+}
+
+// newHook returns the configured hook, or nil if HOOK_COMMAND is unset.
+func newHook(config *Config, replies chan<- string) *Hook {
+	if config.HookCommand == "" {
+		return nil
+	}
+	return &Hook{command: config.HookCommand, timeout: config.HookTimeout, replies: replies}
+}
+
+func (h *Hook) Emit(event Event) {
+	if h == nil {
+		return
+	}
+	go func() {
+		out, err := runHook(h.command, h.timeout, event)
+		if err != nil {
+			log.Printf("Hook failed for %s: %s", event.Type, err)
+			return
+		}
+		if out != "" {
+			h.replies <- out
+		}
+	}()
+}
+
+func runHook(command string, timeout time.Duration, event Event) (string, error) {
+	payload, err := marshalEvent(event)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command, string(event.Type))
+	cmd.Stdin = bytes.NewReader(payload)
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
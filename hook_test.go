@@ -0,0 +1,35 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCarStateJSON(t *testing.T) {
+	state := CarState{At: time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC), Geofence: "Home", BatteryLevel: 61}
+	payload, err := json.Marshal(state)
+	assert.NoError(t, err)
+	var decoded map[string]interface{}
+	assert.NoError(t, json.Unmarshal(payload, &decoded))
+	assert.Equal(t, "2021-04-09T06:39:00Z", decoded["at"])
+	assert.Equal(t, "Home", decoded["geofence"])
+	assert.Equal(t, 61.0, decoded["battery_level"])
+}
+
+func TestRunHook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hook")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "hook.sh")
+	ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$1\"\ngrep -o '\"car\":\"[^\"]*\"'\n"), 0755)
+
+	out, err := runHook(script, time.Second, Event{Type: "drive_finished", Car: "Snowflake"})
+	assert.NoError(t, err)
+	assert.Equal(t, "drive_finished\n\"car\":\"Snowflake\"", out)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Languages notification messages can be sent in, chosen with LANGUAGE.
+// Each catalog holds the format strings for a message; anything missing
+// falls back to English. Only the drive and charge summaries, the /status
+// dashboard and distance units are translated, everything else is English.
+var catalogs = map[string]map[string]string{
+	"en": {
+		"charge_finished":    "🔌 Charging finished at %s.\n🕗 %s→%s (%s)\n🔋 %d→%d%% (+ %d%%)\n🚗 %0.f→%.0f %s (+ %.1f %s).\n⚡ + %.1fkWh\nAverage Power: %.2fkW (Peak %dkW at %d%%)",
+		"drive_finished":     "🚗 %s->%s <code>%.1f</code> %s 🌡 %s\n🕗 %s→%s (%s)\n🔋 %d→%d%% (%d%%)\n🚘 %0.f→%.0f %s (%.1f %s @ %.0f%s)",
+		"status":             "🔋%d%%",
+		"miles":              "miles",
+		"km":                 "km",
+		"status_driving":     "🚗 Driving",
+		"status_charging":    "⚡ Charging at %dkW",
+		"status_asleep":      "😴 Asleep",
+		"status_offline":     "📴 Offline",
+		"status_parked":      "🅿️ Parked",
+		"status_plugged_in":  "🔌 Plugged in",
+		"status_unplugged":   "🔌 Unplugged",
+		"status_temperature": "🌡 Inside %s · outside %s",
+		"status_updated_now": "🕗 Updated just now",
+		"status_updated":     "🕗 Updated %s ago",
+	},
+	"de": {
+		"charge_finished":    "🔌 Laden beendet bei %s.\n🕗 %s→%s (%s)\n🔋 %d→%d%% (+ %d%%)\n🚗 %0.f→%.0f %s (+ %.1f %s).\n⚡ + %.1fkWh\nDurchschnittliche Leistung: %.2fkW (Spitze %dkW bei %d%%)",
+		"miles":              "Meilen",
+		"drive_finished":     "🚗 %s->%s <code>%.1f</code> %s 🌡 %s\n🕗 %s→%s (%s)\n🔋 %d→%d%% (%d%%)\n🚘 %0.f→%.0f %s (%.1f %s @ %.0f%s)",
+		"status_driving":     "🚗 Fährt",
+		"status_charging":    "⚡ Lädt mit %dkW",
+		"status_asleep":      "😴 Schläft",
+		"status_offline":     "📴 Offline",
+		"status_parked":      "🅿️ Geparkt",
+		"status_plugged_in":  "🔌 Angesteckt",
+		"status_unplugged":   "🔌 Nicht angesteckt",
+		"status_temperature": "🌡 Innen %s · außen %s",
+		"status_updated_now": "🕗 Gerade aktualisiert",
+		"status_updated":     "🕗 Vor %s aktualisiert",
+	},
+	"fr": {
+		"charge_finished":    "🔌 Recharge terminée à %s.\n🕗 %s→%s (%s)\n🔋 %d→%d%% (+ %d%%)\n🚗 %0.f→%.0f %s (+ %.1f %s).\n⚡ + %.1fkWh\nPuissance moyenne : %.2fkW (pic %dkW à %d%%)",
+		"drive_finished":     "🚗 %s->%s <code>%.1f</code> %s 🌡 %s\n🕗 %s→%s (%s)\n🔋 %d→%d%% (%d%%)\n🚘 %0.f→%.0f %s (%.1f %s à %.0f%s)",
+		"status_driving":     "🚗 En route",
+		"status_charging":    "⚡ En charge à %dkW",
+		"status_asleep":      "😴 En veille",
+		"status_offline":     "📴 Hors ligne",
+		"status_parked":      "🅿️ Stationnée",
+		"status_plugged_in":  "🔌 Branchée",
+		"status_unplugged":   "🔌 Débranchée",
+		"status_temperature": "🌡 Intérieur %s · extérieur %s",
+		"status_updated_now": "🕗 Mis à jour à l'instant",
+		"status_updated":     "🕗 Mis à jour il y a %s",
+	},
+	"nl": {
+		"charge_finished":    "🔌 Laden voltooid bij %s.\n🕗 %s→%s (%s)\n🔋 %d→%d%% (+ %d%%)\n🚗 %0.f→%.0f %s (+ %.1f %s).\n⚡ + %.1fkWh\nGemiddeld vermogen: %.2fkW (piek %dkW bij %d%%)",
+		"miles":              "mijl",
+		"drive_finished":     "🚗 %s->%s <code>%.1f</code> %s 🌡 %s\n🕗 %s→%s (%s)\n🔋 %d→%d%% (%d%%)\n🚘 %0.f→%.0f %s (%.1f %s @ %.0f%s)",
+		"status_driving":     "🚗 Rijdt",
+		"status_charging":    "⚡ Laadt met %dkW",
+		"status_asleep":      "😴 Slaapt",
+		"status_offline":     "📴 Offline",
+		"status_parked":      "🅿️ Geparkeerd",
+		"status_plugged_in":  "🔌 Aangesloten",
+		"status_unplugged":   "🔌 Niet aangesloten",
+		"status_temperature": "🌡 Binnen %s · buiten %s",
+		"status_updated_now": "🕗 Zojuist bijgewerkt",
+		"status_updated":     "🕗 %s geleden bijgewerkt",
+	},
+}
+
+var language = "en"
+
+// tr returns the format string for a message in the configured language.
+func tr(key string) string {
+	if format, ok := catalogs[language][key]; ok {
+		return format
+	}
+	return catalogs["en"][key]
+}
+
+// replyLanguage returns the language for a reply to a command: a language
+// code given as its arguments, as in /status de, else the chat's setting,
+// else fallback. Of command replies, only /status and distance units have
+// translations.
+func replyLanguage(settings *ChatSettings, args, fallback string) string {
+	if _, ok := catalogs[args]; ok {
+		return args
+	}
+	if settings != nil && settings.Language != "" {
+		return settings.Language
+	}
+	return fallback
+}
+
+func languageCodes() string {
+	codes := make([]string, 0, len(catalogs))
+	for code := range catalogs {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return strings.Join(codes, "|")
+}
+
+// languageCommand shows or sets the language of /status replies to a chat.
+func languageCommand(store *Store, chatID int64, args string) string {
+	settings, ok := store.Chats[chatID]
+	if !ok {
+		settings = &ChatSettings{}
+		store.Chats[chatID] = settings
+	}
+	switch args = strings.TrimSpace(args); {
+	case args == "":
+		if settings.Language == "" {
+			return "🌐 /status uses the default language. Set one with /language " + languageCodes()
+		}
+		return "🌐 /status in " + settings.Language
+	case args == "default":
+		settings.Language = ""
+		return "🌐 /status uses the default language"
+	}
+	if _, ok := catalogs[args]; !ok {
+		return "Usage: /language " + languageCodes() + "|default"
+	}
+	settings.Language = args
+	return "🌐 /status in " + args
+}
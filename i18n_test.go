@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/barnybug/teslamate-telegram/pkg/teslamatebridge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTranslatedDriveMessage(t *testing.T) {
+	defer func(l string) { language = l }(language)
+	language = "de"
+	startAt := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	start := CarState{At: startAt, BatteryLevel: 50, Odometer: 976, OutsideTemp: 7.5, RatedBatteryRangeKm: 400, Geofence: "Home"}
+	end := CarState{At: startAt.Add(8 * time.Minute), BatteryLevel: 48, Odometer: 986, RatedBatteryRangeKm: 390, Geofence: "Work"}
+	assert.Equal(t, "🚗 Home->Work <code>6.2</code> Meilen 🌡 7.5°C\n🕗 06:39→06:47 (8m)\n🔋 50→48% (-2%)\n🚘 248→242 Meilen (6.2 Meilen @ 216Wh/mi)", finishDriveMessage(start, end, RatedKMPerKwh))
+	status := statusMessage(&Car{Session: teslamatebridge.Session{State: CarState{BatteryLevel: 61}}}, nil, startAt)
+	assert.Contains(t, status, "\n🔋61% · 0 Meilen\n")
+	assert.Contains(t, status, "🅿️ Geparkt\n")
+	assert.Contains(t, status, "\n🔌 Nicht angesteckt\n🌡 Innen ")
+}
+
+func TestLanguageConfig(t *testing.T) {
+	_, err := parseConfig(lookupMap(map[string]string{"LANGUAGE": "xx"}))
+	assert.EqualError(t, err, "invalid LANGUAGE: xx")
+}
+
+func TestReplyLanguage(t *testing.T) {
+	assert.Equal(t, "en", replyLanguage(nil, "", "en"))
+	assert.Equal(t, "fr", replyLanguage(&ChatSettings{Language: "fr"}, "", "en"))
+	assert.Equal(t, "de", replyLanguage(&ChatSettings{Language: "fr"}, "de", "en"))
+	assert.Equal(t, "fr", replyLanguage(&ChatSettings{Language: "fr"}, "5 week", "en"))
+}
+
+func TestLanguageCommand(t *testing.T) {
+	store := &Store{Chats: map[int64]*ChatSettings{}}
+	assert.Equal(t, "🌐 /status uses the default language. Set one with /language de|en|fr|nl", languageCommand(store, 1, ""))
+	assert.Equal(t, "🌐 /status in nl", languageCommand(store, 1, "nl"))
+	assert.Equal(t, "nl", store.Chats[1].Language)
+	assert.Equal(t, "Usage: /language de|en|fr|nl|default", languageCommand(store, 1, "xx"))
+	assert.Equal(t, "🌐 /status uses the default language", languageCommand(store, 1, "default"))
+	assert.Equal(t, "", store.Chats[1].Language)
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"strings"
+	"text/template"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// inlineCommands can be shared into any chat with an inline query, e.g.
+// "@mytesla_bot status", in this order.
+var inlineCommands = []string{"status", "range", "odometer", "charge", "climate"}
+
+// inlineResults answers an inline query with a card for each command
+// starting with the query, or all of them for an empty one.
+func inlineResults(car *Car, templates map[string]*template.Template, query string, now time.Time) []interface{} {
+	results := []interface{}{}
+	if car == nil {
+		return results
+	}
+	for _, command := range inlineCommands {
+		if !strings.HasPrefix(command, query) {
+			continue
+		}
+		var article tgbotapi.InlineQueryResultArticle
+		switch command {
+		case "status":
+			article = tgbotapi.NewInlineQueryResultArticleHTML(command, "Status", statusMessage(car, templates, now))
+		case "range":
+			article = tgbotapi.NewInlineQueryResultArticle(command, "Range", rangeMessage(car))
+		case "odometer":
+			article = tgbotapi.NewInlineQueryResultArticle(command, "Odometer", odometerMessage(car))
+		case "charge":
+			article = tgbotapi.NewInlineQueryResultArticle(command, "Charging", chargeMessage(car, now))
+		case "climate":
+			article = tgbotapi.NewInlineQueryResultArticle(command, "Climate", climateMessage(car))
+		}
+		for _, c := range commands {
+			if c.Command == command {
+				article.Description = c.Description
+			}
+		}
+		results = append(results, article)
+	}
+	return results
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInlineResults(t *testing.T) {
+	now := time.Date(2021, 4, 9, 12, 0, 0, 0, time.UTC)
+	assert.Empty(t, inlineResults(nil, nil, "status", now))
+
+	car := &Car{displayName: "Snowflake"}
+	car.State = CarState{Odometer: 19876, BatteryLevel: 61, RatedBatteryRangeKm: 335}
+	assert.Len(t, inlineResults(car, nil, "", now), len(inlineCommands))
+	results := inlineResults(car, nil, "ra", now)
+	assert.Len(t, results, 1)
+	article := results[0].(tgbotapi.InlineQueryResultArticle)
+	assert.Equal(t, "range", article.ID)
+	assert.Equal(t, "Battery level and range", article.Description)
+	assert.Equal(t, rangeMessage(car), article.InputMessageContent.(tgbotapi.InputTextMessageContent).Text)
+
+	status := inlineResults(car, nil, "status", now)[0].(tgbotapi.InlineQueryResultArticle)
+	assert.Equal(t, "HTML", status.InputMessageContent.(tgbotapi.InputTextMessageContent).ParseMode)
+	assert.Empty(t, inlineResults(car, nil, "wipe", now))
+}
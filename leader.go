@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+// LeaderSettle is how long a replica waits for the retained lease, and
+// after claiming it for any competing claims, before acting as leader.
+const LeaderSettle = 2 * time.Second
+
+// Leader elects one active replica using a retained lease on an MQTT topic,
+// so that standby replicas don't send duplicate notifications.
+type Leader struct {
+	topic string
+	id    string
+	lease time.Duration
+
+	mu      sync.Mutex
+	holder  string
+	expires time.Time
+}
+
+type leaderLease struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires"`
+}
+
+// newLeader returns the configured leader election, or nil if disabled.
+func newLeader(config *Config) *Leader {
+	if config.LeaderTopic == "" {
+		return nil
+	}
+	id := config.LeaderID
+	if id == "" {
+		id, _ = os.Hostname()
+	}
+	return &Leader{topic: config.LeaderTopic, id: id, lease: config.LeaderLease}
+}
+
+func (l *Leader) Subscribe(client mqtt.Client) error {
+	token := client.Subscribe(l.topic, 1, func(client mqtt.Client, msg mqtt.Message) {
+		l.observe(msg.Payload())
+	})
+	token.Wait()
+	return token.Error()
+}
+
+func (l *Leader) observe(payload []byte) {
+	var lease leaderLease
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &lease); err != nil {
+			log.Printf("Ignoring invalid leader lease: %s", payload)
+			return
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.holder, l.expires = lease.ID, lease.Expires
+}
+
+// claimable reports whether the lease is free, expired or already held by
+// this replica.
+func (l *Leader) claimable(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.holder == "" || l.holder == l.id || now.After(l.expires)
+}
+
+// held reports whether this replica holds the lease, or nobody does.
+func (l *Leader) held() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.holder == "" || l.holder == l.id
+}
+
+func (l *Leader) claim(client mqtt.Client, now time.Time) {
+	payload, _ := json.Marshal(leaderLease{ID: l.id, Expires: now.Add(l.lease)})
+	token := client.Publish(l.topic, 1, true, payload)
+	if token.Wait() && token.Error() != nil {
+		log.Println("Failed to claim leader lease:", token.Error())
+	}
+}
+
+// Acquire blocks until this replica holds the lease.
+func (l *Leader) Acquire(client mqtt.Client) {
+	time.Sleep(LeaderSettle)
+	for {
+		if l.claimable(time.Now()) {
+			l.claim(client, time.Now())
+			time.Sleep(LeaderSettle)
+			if l.held() {
+				return
+			}
+		}
+		time.Sleep(l.lease / 3)
+	}
+}
+
+// Hold renews the lease until another replica takes it over, then sends
+// the new holder on lost.
+func (l *Leader) Hold(client mqtt.Client, lost chan<- string) {
+	for range time.Tick(l.lease / 3) {
+		if !l.held() {
+			l.mu.Lock()
+			holder := l.holder
+			l.mu.Unlock()
+			lost <- holder
+			return
+		}
+		l.claim(client, time.Now())
+	}
+}
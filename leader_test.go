@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLeaderLease(t *testing.T) {
+	l := newLeader(&Config{LeaderTopic: "teslamate-telegram/leader", LeaderID: "a", LeaderLease: 30 * time.Second})
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+	assert.True(t, l.claimable(now), "nobody holds it")
+	assert.True(t, l.held())
+
+	l.observe([]byte(`{"id":"b","expires":"2021-09-01T12:00:30Z"}`))
+	assert.False(t, l.claimable(now))
+	assert.False(t, l.held())
+	assert.True(t, l.claimable(now.Add(31*time.Second)), "expired")
+
+	l.observe([]byte(`{"id":"a","expires":"2021-09-01T12:00:30Z"}`))
+	assert.True(t, l.claimable(now))
+	assert.True(t, l.held())
+
+	l.observe([]byte(`garbage`))
+	assert.True(t, l.held(), "invalid leases are ignored")
+	l.observe(nil)
+	assert.True(t, l.claimable(now), "released")
+
+	assert.Nil(t, newLeader(&Config{}))
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"log"
+	"strconv"
+	"sync/atomic"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+)
+
+type loadShedAction int
+
+const (
+	loadShedNone loadShedAction = iota
+	loadShedExceeded
+	loadShedCleared
+)
+
+// LoadShedder watches household grid import from a home energy monitor and
+// flags when home charging pushes it over the main fuse limit.
+type LoadShedder struct {
+	topic     string
+	limit     float64 // W
+	autoPause bool
+
+	shedding bool
+	paused   bool
+	power    float64 // W charging when the limit was exceeded
+}
+
+// newLoadShedder returns the configured load shedder, or nil if disabled.
+func newLoadShedder(config *Config) *LoadShedder {
+	if config.GridImportTopic == "" || config.GridImportLimit <= 0 {
+		return nil
+	}
+	return &LoadShedder{
+		topic:     config.GridImportTopic,
+		limit:     config.GridImportLimit,
+		autoPause: config.GridImportAutoPause,
+	}
+}
+
+func (l *LoadShedder) Subscribe(client mqtt.Client, updates chan<- float64) error {
+	token := client.Subscribe(l.topic, 0, func(client mqtt.Client, msg mqtt.Message) {
+		watts, err := strconv.ParseFloat(string(msg.Payload()), 64)
+		if err != nil {
+			log.Println("Failed to parse grid import:", string(msg.Payload()))
+			return
+		}
+		select {
+		case updates <- watts:
+		default:
+			// main loop is behind, drop rather than block the mqtt client
+			atomic.AddInt64(&droppedMessages, 1)
+		}
+	})
+	token.Wait()
+	return token.Error()
+}
+
+// Update takes the latest grid import and the power currently drawn by home
+// charging (both W). Once exceeded, the limit is only cleared when the import
+// has dropped far enough for a paused charge to resume without tripping it
+// again.
+func (l *LoadShedder) Update(importW, chargingW float64) loadShedAction {
+	if !l.shedding {
+		if importW > l.limit && chargingW > 0 {
+			l.shedding = true
+			l.power = chargingW
+			return loadShedExceeded
+		}
+		return loadShedNone
+	}
+	threshold := l.limit
+	if l.paused {
+		threshold -= l.power
+	}
+	if importW <= threshold {
+		l.shedding = false
+		return loadShedCleared
+	}
+	return loadShedNone
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadShedderWarn(t *testing.T) {
+	l := &LoadShedder{limit: 10000}
+	assert.Equal(t, loadShedNone, l.Update(12000, 0))
+	assert.Equal(t, loadShedExceeded, l.Update(12000, 7000))
+	assert.Equal(t, loadShedNone, l.Update(11000, 7000))
+	assert.Equal(t, loadShedCleared, l.Update(9000, 7000))
+}
+
+func TestLoadShedderPaused(t *testing.T) {
+	l := &LoadShedder{limit: 10000, autoPause: true}
+	assert.Equal(t, loadShedExceeded, l.Update(12000, 7000))
+	l.paused = true
+	assert.Equal(t, loadShedNone, l.Update(5000, 0))
+	assert.Equal(t, loadShedCleared, l.Update(2500, 0))
+}
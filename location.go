@@ -0,0 +1,22 @@
+package main
+
+import tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+
+// locationReply answers /location with a venue pin at the car, which opens
+// in a map when tapped.
+func locationReply(chatID int64, car *Car) tgbotapi.Chattable {
+	if car == nil {
+		return tgbotapi.NewMessage(chatID, "No car data received yet.")
+	}
+	s := car.State
+	if privateGeofences[s.Geofence] {
+		s = redactPlace(s)
+	}
+	if s.Latitude == 0 && s.Longitude == 0 {
+		if s.Geofence != "" {
+			return tgbotapi.NewMessage(chatID, "📍 "+s.Geofence)
+		}
+		return tgbotapi.NewMessage(chatID, "📍 Location unknown")
+	}
+	return tgbotapi.NewVenue(chatID, car.displayName, placeName(s), float64(s.Latitude), float64(s.Longitude))
+}
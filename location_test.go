@@ -0,0 +1,28 @@
+package main
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLocationReply(t *testing.T) {
+	car := &Car{displayName: "Snowflake"}
+	car.State = CarState{Geofence: "Home", Latitude: 51.5, Longitude: -0.125}
+	venue := locationReply(1, car).(tgbotapi.VenueConfig)
+	assert.Equal(t, "Snowflake", venue.Title)
+	assert.Equal(t, "Home", venue.Address)
+	assert.Equal(t, 51.5, venue.Latitude)
+	assert.Equal(t, -0.125, venue.Longitude)
+
+	// coordinates dropped by PRIVACY_MODE=strict
+	car.State = CarState{Geofence: "Home"}
+	assert.Equal(t, "📍 Home", locationReply(1, car).(tgbotapi.MessageConfig).Text)
+	defer func(p map[string]bool) { privateGeofences = p }(privateGeofences)
+	privateGeofences = map[string]bool{"Clinic": true}
+	car.State = CarState{Geofence: "Clinic", Latitude: 51.5, Longitude: -0.125}
+	assert.Equal(t, "📍 "+PrivatePlace, locationReply(1, car).(tgbotapi.MessageConfig).Text)
+	car.State = CarState{}
+	assert.Equal(t, "📍 Location unknown", locationReply(1, car).(tgbotapi.MessageConfig).Text)
+}
@@ -1,44 +1,41 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
+	"html"
+	"io/ioutil"
 	"log"
+	"math"
 	"net/http"
 	"net/url"
 	"os"
-	"strconv"
+	"os/signal"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"text/template"
 	"time"
 
+	"github.com/barnybug/teslamate-telegram/pkg/teslamatebridge"
 	mqtt "github.com/eclipse/paho.mqtt.golang"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 )
 
-// 61% 334.87km 73.5 kWh usuable
+// 61% 334.87km 73.5 kWh usuable. The default, see RATED_KM_PER_KWH for
+// other cars.
 const RatedKMPerKwh = 7.47
 const KMPerMile = 1.61
 
-type CarState struct {
-	at                   time.Time
-	geofence             string
-	chargerPower         int
-	chargerVoltage       int
-	timeToFullCharge     float32
-	chargerActualCurrent int
-	chargeEnergyAdded    float32
-	estBatteryRangeKm    float32
-	ratedBatteryRangeKm  float32
-	idealBatteryRangeKm  float32
-	batteryLevel         int
-	shiftState           string
-	odometer             float32
-	outsideTemp          float32
-	insideTemp           float32
-	pluggedIn            bool
-	latitude             float32
-	longitude            float32
-}
+// Peak charger power above which a session is counted as DC fast charging.
+const DCChargerPowerKw = 22
+
+// CarState is a car's latest values from TeslaMate.
+type CarState = teslamatebridge.State
 
 func truncate(s string, limit int) string {
 	// try to cut at a comma
@@ -52,11 +49,35 @@ func truncate(s string, limit int) string {
 	return s[:limit]
 }
 
-func (s CarState) placeName() string {
-	if s.geofence != "" {
-		return s.geofence
+const (
+	PrivacyOff    = ""
+	PrivacyRound  = "round"  // coordinates rounded to ~1km, no reverse geocoding
+	PrivacyStrict = "strict" // coordinates dropped, no reverse geocoding
+)
+
+var privacyMode = PrivacyOff
+
+func redactCoordinate(f float32) float32 {
+	switch privacyMode {
+	case PrivacyRound:
+		return float32(math.Round(float64(f)*100) / 100)
+	case PrivacyStrict:
+		return 0
+	}
+	return f
+}
+
+func placeName(s CarState) string {
+	if s.Geofence != "" {
+		return s.Geofence
+	}
+	if privacyMode != PrivacyOff {
+		return "away"
 	}
-	result, err := nominatimLookup(s.latitude, s.longitude)
+	if !features.Enabled("geocoding") {
+		return "?"
+	}
+	result, err := nominatimLookup(s.Latitude, s.Longitude)
 	if err == nil {
 		name := result.Name
 		if name == "" {
@@ -71,238 +92,1287 @@ func (s CarState) placeName() string {
 }
 
 type Car struct {
+	id          int
 	displayName string
 	state       string
-	carState    CarState
 
-	charging    bool
-	chargeStart CarState
-	chargePeak  CarState
+	teslamatebridge.Session
+
+	evseStart float32
+	venting   bool
+	curve     chargeCurve    // of the charge in progress
+	milestone int            // highest CHARGE_MILESTONES level of the charge in progress
+	energy    []energySample // of the charge in progress
+	watched   CarState       // state when /watch last compared
+
+	charges      []chargeRecord
+	drives       []driveRecord
+	calibrations []calibrationRecord
+	timeline     []timelineEntry
+	visits       []visitRecord
+	totals       Totals
+
+	// when the main loop took the car on, which starts its warm-up
+	added time.Time
+
+	// updates from mqtt waiting to be applied by the main loop
+	mu        sync.Mutex
+	pending   map[string]string
+	scheduled bool
+	update    *time.Timer
+}
+
+// Queue records an update from mqtt, replacing any earlier unapplied value
+// for the same key. The main loop is woken at most once per interval however
+// chatty the broker is.
+func (car *Car) Queue(key, value string, interval time.Duration) {
+	car.mu.Lock()
+	defer car.mu.Unlock()
+	if car.pending == nil {
+		car.pending = map[string]string{}
+	}
+	car.pending[key] = value
+	if !car.scheduled {
+		car.scheduled = true
+		car.update.Reset(interval)
+	}
+}
+
+// ApplyPending applies the queued updates to the car state.
+func (car *Car) ApplyPending() {
+	car.mu.Lock()
+	pending := car.pending
+	car.pending = nil
+	car.scheduled = false
+	car.mu.Unlock()
+
+	// sorted so battery_level is applied before the range it relates to
+	keys := make([]string, 0, len(pending))
+	for key := range pending {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		car.Update(key, pending[key])
+	}
+}
+
+// Totals are lifetime counters since the bridge started.
+type Totals struct {
+	drives      int
+	distanceKm  float32
+	ratedKmUsed float32
+	acCharges   int
+	dcCharges   int
+	chargeCost  float32 // at Home, in the tariff's currency
+}
+
+func (car *Car) recordDrive(start, end CarState, from, to string, precipitation float32) {
+	car.totals.drives++
+	car.totals.distanceKm += end.Odometer - start.Odometer
+	car.totals.ratedKmUsed += start.RatedBatteryRangeKm - end.RatedBatteryRangeKm
+	car.drives = append(car.drives, driveRecord{
+		at:            start.At,
+		until:         end.At,
+		from:          from,
+		to:            to,
+		distanceKm:    end.Odometer - start.Odometer,
+		ratedKmUsed:   start.RatedBatteryRangeKm - end.RatedBatteryRangeKm,
+		outsideTemp:   start.OutsideTemp,
+		precipitation: precipitation,
+		maxSpeedKmh:   car.DriveMaxSpeed,
+	})
+	car.prune(end.At)
+}
+
+// NoPrecipitation marks a drive without a weather lookup.
+const NoPrecipitation = -1
+
+// driveRecord keeps a finished drive with the weather it was driven in.
+type driveRecord struct {
+	at            time.Time
+	until         time.Time
+	from, to      string // place names
+	distanceKm    float32
+	ratedKmUsed   float32
+	outsideTemp   float32 // °C
+	precipitation float32 // mm, or NoPrecipitation
+	maxSpeedKmh   int     // 0 if the speed topic wasn't seen
+}
+
+// averageSpeedKmh is the drive's distance over its duration.
+func (d driveRecord) averageSpeedKmh() float32 {
+	hours := d.until.Sub(d.at).Hours()
+	if hours <= 0 {
+		return 0
+	}
+	return d.distanceKm / float32(hours)
+}
+
+type chargeRecord struct {
+	at                   time.Time
+	until                time.Time
+	geofence             string
+	place                string
+	startLevel, endLevel int
+	energyAdded          float32 // kWh
+	cost                 float32 // at Home, 0 if unknown
+}
+
+// How long session history is kept for.
+var sessionRetention = 730 * 24 * time.Hour
+
+// prune drops session history older than the retention period, keeping at
+// most MaxHistoryRecords of each kind.
+func (car *Car) prune(now time.Time) {
+	cutoff := now.Add(-sessionRetention)
+	car.charges = car.charges[keepFrom(len(car.charges), func(i int) time.Time { return car.charges[i].at }, cutoff):]
+	car.drives = car.drives[keepFrom(len(car.drives), func(i int) time.Time { return car.drives[i].at }, cutoff):]
+	car.calibrations = car.calibrations[keepFrom(len(car.calibrations), func(i int) time.Time { return car.calibrations[i].at }, cutoff):]
+	car.timeline = car.timeline[keepFrom(len(car.timeline), func(i int) time.Time { return car.timeline[i].at }, cutoff):]
+	car.visits = car.visits[keepFrom(len(car.visits), func(i int) time.Time { return car.visits[i].at }, cutoff):]
+}
 
-	driving    bool
-	driveStart CarState
+// keepFrom returns the index of the first of n time ordered records to keep.
+func keepFrom(n int, at func(int) time.Time, cutoff time.Time) int {
+	i := 0
+	for i < n && at(i).Before(cutoff) {
+		i++
+	}
+	if over := n - MaxHistoryRecords; over > i {
+		i = over
+	}
+	return i
+}
 
-	update *time.Timer
+// wipe forgets all recorded history for the car.
+func (car *Car) wipe() {
+	car.charges = nil
+	car.drives = nil
+	car.calibrations = nil
+	car.timeline = nil
+	car.visits = nil
+	car.totals = Totals{}
+	delete(batteryHistory, car.id)
+	delete(learnedKMPerKwh, car.id)
+	delete(carEmoji, car.id)
+}
+
+type calibrationRecord struct {
+	at           time.Time
+	batteryLevel int
+	fromKm       float32
+	toKm         float32
+}
+
+// recordCalibration keeps a BMS recalibration in the car's history.
+func (car *Car) recordCalibration(event Event) {
+	fromKm, toKm := event.Start.RatedBatteryRangeKm, event.End.RatedBatteryRangeKm
+	log.Printf("Rated range recalibrated at %d%%: %.1f→%.1fkm", event.End.BatteryLevel, fromKm, toKm)
+	car.calibrations = append(car.calibrations, calibrationRecord{
+		at: event.At, batteryLevel: event.End.BatteryLevel, fromKm: fromKm, toKm: toKm,
+	})
+	car.prune(event.At)
+}
+
+// speedMessage gives a drive's average speed, and its top speed if known.
+func speedMessage(d driveRecord) string {
+	avg := d.averageSpeedKmh()
+	if avg == 0 {
+		return ""
+	}
+	text := fmt.Sprintf("\n🏁 Average %.0f %s", speed(avg), speedUnit())
+	if d.maxSpeedKmh > 0 {
+		text += fmt.Sprintf(", max %.0f %s", speed(float32(d.maxSpeedKmh)), speedUnit())
+	}
+	return text
+}
+
+func calibrationMessage(deltaKm float32) string {
+	if deltaKm == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n🔧 Rated range recalibrated %+.1f %s (excluded)", distance(deltaKm), distanceUnit())
+}
+
+// recordCharge logs a finished charge session and returns how many sessions
+// have taken place at the same geofence this month, including this one.
+func (car *Car) recordCharge(r chargeRecord) int {
+	car.charges = append(car.charges, r)
+	car.prune(r.at)
+	count := 0
+	year, month, _ := r.at.Date()
+	for _, c := range car.charges {
+		y, m, _ := c.at.Date()
+		if c.geofence == r.geofence && y == year && m == month {
+			count++
+		}
+	}
+	return count
 }
 
 func (car *Car) Update(key string, value string) {
-	car.carState.at = time.Now()
 	switch key {
 	case "display_name":
 		car.displayName = value
 	case "state":
+		if car.state != "" && car.state != value {
+			car.recordStateChange(car.state, value, time.Now())
+		}
 		car.state = value
-	case "shift_state":
-		car.carState.shiftState = value
-	case "geofence":
-		car.carState.geofence = value
-	case "charger_power":
-		if ivalue, err := strconv.Atoi(value); err == nil {
-			car.carState.chargerPower = ivalue
-		}
-	case "charger_voltage":
-		if ivalue, err := strconv.Atoi(value); err == nil {
-			car.carState.chargerVoltage = ivalue
-		}
-	case "time_to_full_charge":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.timeToFullCharge = float32(fvalue)
-		}
-	case "charger_actual_current":
-		if ivalue, err := strconv.Atoi(value); err == nil {
-			car.carState.chargerActualCurrent = ivalue
-		}
-	case "charge_energy_added":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.chargeEnergyAdded = float32(fvalue)
-		}
-	case "est_battery_range_km":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.estBatteryRangeKm = float32(fvalue)
-		}
-	case "ideal_battery_range_km":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.idealBatteryRangeKm = float32(fvalue)
-		}
-	case "rated_battery_range_km":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.ratedBatteryRangeKm = float32(fvalue)
-		}
-	case "battery_level":
-		if ivalue, err := strconv.Atoi(value); err == nil {
-			car.carState.batteryLevel = ivalue
-		}
-	case "odometer":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.odometer = float32(fvalue)
-		}
-	case "outside_temp":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.outsideTemp = float32(fvalue)
-		}
-	case "inside_temp":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.insideTemp = float32(fvalue)
-		}
-	case "plugged_in":
-		car.carState.pluggedIn = (value == "true")
+	}
+	car.Session.Update(key, value)
+	switch key {
 	case "latitude":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.latitude = float32(fvalue)
-		}
+		car.State.Latitude = redactCoordinate(car.State.Latitude)
 	case "longitude":
-		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
-			car.carState.longitude = float32(fvalue)
+		car.State.Longitude = redactCoordinate(car.State.Longitude)
+	}
+}
+
+func efficiency(start, end CarState, kmPerKwh float32) float32 {
+	kwh := (start.RatedBatteryRangeKm - end.RatedBatteryRangeKm) / kmPerKwh
+	return kwh * 1000 / distance(end.Odometer-start.Odometer) // Wh per distance unit
+}
+
+func tlsConfig(config *Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.MQTTTLSInsecure}
+	if config.MQTTCACert != "" {
+		pem, err := ioutil.ReadFile(config.MQTTCACert)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", config.MQTTCACert)
 		}
 	}
+	if config.MQTTClientCert != "" {
+		cert, err := tls.LoadX509KeyPair(config.MQTTClientCert, config.MQTTClientKey)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	return tlsConfig, nil
 }
 
-func driveShiftState(s string) bool {
-	return s == "D" || s == "R"
+// brokerURL picks the transport from the MQTT_URL scheme, upgrading plain
+// tcp:// and ws:// to their TLS equivalents when MQTT_TLS is set.
+func brokerURL(config *Config) (*url.URL, error) {
+	u, err := url.Parse(config.MQTTURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		if config.MQTTTLS {
+			u.Scheme = "ssl"
+		}
+	case "ws":
+		if config.MQTTTLS {
+			u.Scheme = "wss"
+		}
+	case "ssl", "tls", "mqtts", "tcps", "wss":
+	default:
+		return nil, fmt.Errorf("unsupported MQTT_URL scheme: %s", u.Scheme)
+	}
+	return u, nil
 }
 
-func efficiency(start, end CarState) float32 {
-	kwh := (start.ratedBatteryRangeKm - end.ratedBatteryRangeKm) / RatedKMPerKwh
-	return kwh * 1000 / (end.odometer - start.odometer) * KMPerMile // Wh/mi
+func secureScheme(scheme string) bool {
+	switch scheme {
+	case "ssl", "tls", "mqtts", "tcps", "wss":
+		return true
+	}
+	return false
 }
 
-func clientOptions() *mqtt.ClientOptions {
+func clientOptions(config *Config) (*mqtt.ClientOptions, error) {
 	hostname, _ := os.Hostname()
 	clientID := fmt.Sprintf("teslamate-telegram-%s", hostname)
 	opts := mqtt.NewClientOptions()
-	opts.AddBroker("tcp://mqtt:1883")
+	broker, err := brokerURL(config)
+	if err != nil {
+		return nil, err
+	}
+	if secureScheme(broker.Scheme) {
+		tlsConfig, err := tlsConfig(config)
+		if err != nil {
+			return nil, err
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+	opts.AddBroker(broker.String())
+	opts.SetUsername(config.MQTTUsername)
+	opts.SetPassword(config.MQTTPassword)
 	opts.SetClientID(clientID)  // set unique client id
 	opts.SetAutoReconnect(true) // auto reconnect (default)
 	opts.SetCleanSession(false) // server will queue messages whilst client is offline
-	return opts
+	return opts, nil
 }
 
 const TimeFormat = "2006-01-02 15:04:05.000"
 
 func main() {
-	// discover cars
+	config, err := loadConfig()
+	if err != nil {
+		log.Fatalf("Error loading config: %s", err)
+	}
+	config.apply()
+
+	// discover cars. The handler runs on the mqtt client's goroutine, so it
+	// keeps its own map and cars reach the main loop's cars over carUpdates.
 	carUpdates := make(chan *Car, 1)
 	var defaultCar int
 	cars := map[int]*Car{}
+	discovered := map[int]*Car{}
+	// fixed at startup, as the subscription depends on them
+	namespace, updateInterval := config.MQTTNamespace, config.UpdateInterval
 	carHandler := func(client mqtt.Client, msg mqtt.Message) {
-		var carId int
-		var key string
-		_, err := fmt.Sscanf(msg.Topic(), "teslamate/cars/%d/%s", &carId, &key)
+		carId, key, err := teslamatebridge.ParseTopic(namespace, msg.Topic())
 		if err != nil {
 			log.Println("Failed to parse topic:", msg.Topic())
 			return
 		}
+		if err := teslamatebridge.Validate(key, string(msg.Payload())); err != nil {
+			quarantine(msg.Topic(), err)
+			return
+		}
 		var car *Car
 		var exists bool
-		if car, exists = cars[carId]; !exists {
+		if car, exists = discovered[carId]; !exists {
+			if len(discovered) >= MaxCars {
+				atomic.AddInt64(&droppedMessages, 1)
+				return
+			}
 			log.Printf("New car discovered %d: %s\n", carId, msg.Payload())
 			car = &Car{
-				update: time.NewTimer(2 * time.Second),
+				id:        carId,
+				update:    time.NewTimer(2 * time.Second),
+				scheduled: true,
 			}
-			cars[carId] = car
+			discovered[carId] = car
 			go func() {
 				// relay update events to common channel
-				for range cars[carId].update.C {
+				for range car.update.C {
 					carUpdates <- car
 				}
 			}()
-			defaultCar = carId
 		}
-		car.Update(key, string(msg.Payload()))
-		car.update.Reset(time.Second)
+		car.Queue(key, string(msg.Payload()), updateInterval)
 	}
 
-	opts := clientOptions()
+	watchdog := newWatchdog(time.Now())
+	diagnostics := &Diagnostics{watchdog: watchdog}
+	if config.MetricsAddr != "" {
+		diagnostics.Listen(config.MetricsAddr)
+	}
+	evse := newEVSE(config)
+	loadShedder := newLoadShedder(config)
+	gridUpdates := make(chan float64, 1)
+	leader := newLeader(config)
+	opts, err := clientOptions(config)
+	if err != nil {
+		log.Fatalf("Error configuring mqtt: %s", err)
+	}
+	subscribe := func(client mqtt.Client) error {
+		if token := client.Subscribe(teslamatebridge.TopicPrefix(config.MQTTNamespace)+"#", 0, carHandler); token.Wait() && token.Error() != nil {
+			return token.Error()
+		}
+		if evse != nil {
+			if err := evse.Subscribe(client); err != nil {
+				return err
+			}
+		}
+		if loadShedder != nil {
+			if err := loadShedder.Subscribe(client, gridUpdates); err != nil {
+				return err
+			}
+		}
+		if leader != nil {
+			if err := leader.Subscribe(client); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
 	opts.SetOnConnectHandler(func(client mqtt.Client) {
-		if token := client.Subscribe("teslamate/cars/#", 0, carHandler); token.Wait() && token.Error() != nil {
-			panic(token.Error())
+		// if the connection drops paho reconnects and this runs again
+		err := retry("subscribe", func() error { return subscribe(client) }, func() bool { return !client.IsConnected() })
+		if err != nil {
+			log.Println("Gave up subscribing, disconnected:", err)
 		}
 	})
 	client := mqtt.NewClient(opts)
-	if token := client.Connect(); token.Wait() && token.Error() != nil {
-		panic(token.Error())
-	}
+	retry("connect to mqtt", func() error {
+		token := client.Connect()
+		token.Wait()
+		return token.Error()
+	}, nil)
 	log.Println("Connected to mqtt")
 
-	token := os.Getenv("TELEGRAM_TOKEN")
-	chatId, _ := strconv.ParseInt(os.Getenv("TELEGRAM_CHAT_ID"), 10, 64)
-	bot, err := tgbotapi.NewBotAPI(token)
+	// standby replicas wait here until the leader's lease expires
+	leaderLost := make(chan string)
+	if leader != nil {
+		log.Printf("Waiting to become leader as %s", leader.id)
+		leader.Acquire(client)
+		log.Println("Became leader")
+		go leader.Hold(client, leaderLost)
+	}
+
+	bots, botUpdates, err := startBots(config.TelegramTokens, watchdog)
 	if err != nil {
 		log.Fatalf("Error connecting to telegram: %s", err)
 	}
 
-	log.Printf("Telegram authorized on account %s", bot.Self.UserName)
+	store, err := openStore(config.StateFile)
+	if err != nil {
+		log.Fatalf("Error opening state: %s", err)
+	}
+	features.overrides = store.Features
+	learnedKMPerKwh = store.Efficiency
+	batteryHistory = store.Battery
+	carEmoji = store.CarEmoji
+	// notifyChats are where notifications go, and may run admin commands
+	notifyChats := func() []int64 {
+		if len(config.ChatIDs) > 0 {
+			return config.ChatIDs
+		}
+		if store.ChatID != 0 {
+			return []int64{store.ChatID}
+		}
+		return nil
+	}
+	isNotifyChat := func(chatID int64) bool {
+		for _, id := range notifyChats() {
+			if id == chatID {
+				return true
+			}
+		}
+		return chatCar(config.CarRoutes, chatID) != 0
+	}
+	carIDFor := func(chatID int64) int {
+		if settings, ok := store.Chats[chatID]; ok {
+			if _, exists := cars[settings.CarID]; exists {
+				return settings.CarID
+			}
+		}
+		if carID := chatCar(config.CarRoutes, chatID); carID != 0 {
+			return carID
+		}
+		return defaultCar
+	}
+	// role returns the role of a message's sender. Without a declared or
+	// paired role, members of notification chats are admins and allowed users
+	// and chats are viewers.
+	role := func(message *tgbotapi.Message) string {
+		fallback := ""
+		if isNotifyChat(message.Chat.ID) {
+			fallback = RoleAdmin
+		} else if allowed(config, store, message.Chat.ID) || allowed(config, store, int64(message.From.ID)) {
+			fallback = RoleViewer
+		}
+		return userRole(config, store, message.From.ID, fallback)
+	}
+	// botFor returns the bot that delivers to a chat
+	botFor := func(chatID int64) *tgbotapi.BotAPI {
+		name := botName(config.BotRoutes, store.Bots, chatID, bots[0].Self.UserName)
+		for _, bot := range bots {
+			if bot.Self.UserName == name {
+				return bot
+			}
+		}
+		return bots[0]
+	}
+	saveStore := func() {
+		for _, car := range cars {
+			store.keepHistory(car)
+		}
+		if err := store.Save(); err != nil {
+			log.Println("Failed to save state:", err)
+		}
+	}
+	onboardings := map[int64]*Onboarding{}
+
+	hookReplies := make(chan string)
+	hook := newHook(config, hookReplies)
+
+	var filter *Filter
+	if config.FilterScript != "" {
+		if filter, err = loadFilter(config.FilterScript); err != nil {
+			log.Fatalf("Error loading filter: %s", err)
+		}
+	}
+	// notifications held back during chats' quiet hours
+	held := map[int64][]tgbotapi.MessageConfig{}
+	digest := &Digest{Limit: config.DigestLimit}
+	// notifyTo sends an outgoing event's message to the chats, subject to
+	// the filter script, each chat's preferences and quiet hours, and the
+	// digest
+	notifyTo := func(event Event, parseMode string, chats []int64) {
+		text := event.Message
+		if filter != nil {
+			filtered, route, ok, err := filter.Apply(event)
+			if err != nil {
+				log.Printf("Filter failed for %s: %s", event.Type, err)
+			} else if !ok {
+				log.Printf("Filter suppressed %s", event.Type)
+				return
+			} else {
+				text = filtered
+				if route != 0 {
+					chats = []int64{route}
+				}
+			}
+		}
+		text = carPrefix(event.CarID, text)
+		for _, chatID := range chats {
+			if !notifyWanted(store.Chats[chatID], string(event.Type)) {
+				continue
+			}
+			if belowThreshold(chatThresholds(store.Chats[chatID], config), event) {
+				log.Printf("%s below the notification threshold for %d", event.Type, chatID)
+				continue
+			}
+			msg := tgbotapi.NewMessage(chatID, text)
+			msg.ParseMode = parseMode
+			msg.DisableNotification = silent(event)
+			if event.Type == teslamatebridge.ChargeFinished && event.Start != nil && features.Enabled("receipts") {
+				msg.ReplyMarkup = receiptKeyboard(event.CarID, event.Start.At)
+			}
+			if settings := store.Chats[chatID]; settings != nil && !urgent(event) && inQuietHours(settings.QuietHours, now()) {
+				if settings.QuietQueue {
+					held[chatID] = append(held[chatID], msg)
+					continue
+				}
+				msg.DisableNotification = true
+			}
+			if !urgent(event) && !digest.Allow(chatID, string(event.Type)) {
+				continue
+			}
+			if _, err := botFor(chatID).Send(msg); err != nil {
+				log.Printf("Failed to send %s to %d: %s", event.Type, chatID, err)
+			}
+		}
+	}
+	// notify sends an event to the chats routed for its type and car
+	notify := func(event Event, parseMode string) {
+		notifyTo(event, parseMode, routeChats(config.Routes, string(event.Type), carChats(config.CarRoutes, event.CarID, notifyChats())))
+	}
+
+	// celebrate sends a sticker or animation to the chats notified of an
+	// event, except during quiet hours
+	celebrate := func(event Event, media func(chatID int64) tgbotapi.Chattable) {
+		for _, chatID := range routeChats(config.Routes, string(event.Type), carChats(config.CarRoutes, event.CarID, notifyChats())) {
+			settings := store.Chats[chatID]
+			if !notifyWanted(settings, string(event.Type)) || (settings != nil && inQuietHours(settings.QuietHours, now())) {
+				continue
+			}
+			if _, err := botFor(chatID).Send(media(chatID)); err != nil {
+				log.Printf("Failed to send celebration to %d: %s", chatID, err)
+			}
+		}
+	}
+
+	releases := make(chan *Release)
+	// each release is notified once, unless it's the one running
+	notifiedRelease := version
+	if config.ReleaseCheck && version == "dev" {
+		log.Println("Not checking for releases of a dev build")
+	}
+
+	// scheduled status snapshots are checked at the start of each minute
+	nextMinute := func() time.Duration {
+		return time.Until(time.Now().Truncate(time.Minute).Add(time.Minute))
+	}
+	minuteTimer := time.NewTimer(nextMinute())
+	statusSchedules := func() map[int64]string {
+		schedules := map[int64]string{}
+		if config.StatusSchedule != "" {
+			for _, chatID := range routeChats(config.Routes, "status", notifyChats()) {
+				schedules[chatID] = config.StatusSchedule
+			}
+		}
+		for chatID, settings := range store.Chats {
+			if settings.StatusSchedule != "" {
+				schedules[chatID] = settings.StatusSchedule
+			}
+		}
+		return schedules
+	}
+
+	scheduler := newScheduler(store.Jobs)
+	sendStatus := func(chatID int64, now time.Time) {
+		car, ok := cars[carIDFor(chatID)]
+		if !ok {
+			return
+		}
+		language = replyLanguage(store.Chats[chatID], "", config.Language)
+		units = chatUnits(store.Chats[chatID], config.Units)
+		status := statusMessage(car, config.Templates, now)
+		script := digestScript(car, status, now)
+		language, units = config.Language, config.Units
+		msg := tgbotapi.NewMessage(chatID, status)
+		msg.ParseMode = "HTML"
+		bot := botFor(chatID)
+		bot.Send(msg)
+		if config.TTSURL != "" && features.Enabled("voice") {
+			// resolved here, as the main loop changes config and the bots
+			go func(bot *tgbotapi.BotAPI, url, script string) {
+				audio, err := synthesize(url, script)
+				if err != nil {
+					log.Printf("Failed to synthesize digest: %s", err)
+					return
+				}
+				bot.Send(tgbotapi.NewVoiceUpload(chatID, tgbotapi.FileBytes{Name: "digest.ogg", Bytes: audio}))
+			}(bot, config.TTSURL, script)
+		}
+	}
+	checkCalendar := func(now time.Time) {
+		events, err := calendarLookup(config.CalendarURL)
+		if err != nil {
+			log.Printf("Failed to fetch calendar: %s", err)
+			return
+		}
+		for _, car := range cars {
+			for _, text := range tripWarnings(car, events, now) {
+				notify(carEvent("trip_warning", car, car.State, car.State, text), "")
+			}
+		}
+	}
+	// syncJobs schedules the jobs for the current config and chat settings,
+	// which /reload, /schedule and the setup can change.
+	syncJobs := func() {
+		scheduler.Remove("status:")
+		for chatID, schedule := range statusSchedules() {
+			if c, err := parseCron(schedule); err == nil {
+				chatID := chatID
+				scheduler.Add(&Job{Name: fmt.Sprintf("status:%d", chatID), Schedule: c, CatchUp: 30 * time.Minute,
+					Run: func(now time.Time) { sendStatus(chatID, now) }})
+			}
+		}
+		scheduler.Remove("calendar")
+		if config.CalendarURL != "" && features.Enabled("calendar") {
+			c, _ := parseCron(fmt.Sprintf("%d %d * * *", config.CalendarCheck%60, config.CalendarCheck/60))
+			scheduler.Add(&Job{Name: "calendar", Schedule: c, CatchUp: 2 * time.Hour, Run: checkCalendar})
+		}
+		scheduler.Remove("release")
+		if config.ReleaseCheck && version != "dev" {
+			scheduler.Add(&Job{Name: "release", Every: config.ReleaseCheckInterval,
+				Run: func(now time.Time) { checkRelease(config.ReleaseFeed, releases) }})
+		}
+	}
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	// housekeeping every minute
+	everyMinute, _ := parseCron("* * * * *")
+	scheduler.Add(&Job{Name: "held", Schedule: everyMinute, Untracked: true, Run: func(now time.Time) {
+		for chatID, msgs := range held {
+			if settings := store.Chats[chatID]; settings != nil && inQuietHours(settings.QuietHours, now) {
+				continue
+			}
+			for _, msg := range msgs {
+				if _, err := botFor(chatID).Send(msg); err != nil {
+					log.Printf("Failed to send held message to %d: %s", chatID, err)
+				}
+			}
+			delete(held, chatID)
+		}
+	}})
+	scheduler.Add(&Job{Name: "drive-grace", Schedule: everyMinute, Untracked: true, Run: func(now time.Time) {
+		for _, car := range cars {
+			// finish drives whose grace period ran out without updates
+			if car.DrivePaused() {
+				select {
+				case carUpdates <- car:
+				default:
+				}
+			}
+		}
+	}})
+	scheduler.Add(&Job{Name: "digest", Schedule: everyMinute, Untracked: true, Run: func(now time.Time) {
+		digest.Limit = config.DigestLimit
+		for chatID, text := range digest.Flush() {
+			msg := tgbotapi.NewMessage(chatID, text)
+			msg.DisableNotification = true
+			if _, err := botFor(chatID).Send(msg); err != nil {
+				log.Printf("Failed to send digest to %d: %s", chatID, err)
+			}
+		}
+	}})
 
-	botUpdates, err := bot.GetUpdatesChan(u)
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	reload := func() string {
+		c, err := loadConfig()
+		if err != nil {
+			log.Println("Error reloading config:", err)
+			return fmt.Sprintf("⚠️ Config not reloaded: %s", err)
+		}
+		config = c
+		config.apply()
+		if loadShedder != nil {
+			loadShedder.limit = config.GridImportLimit
+			loadShedder.autoPause = config.GridImportAutoPause
+		}
+		log.Println("Reloaded config")
+		return "✅ Config reloaded"
+	}
 
 	for {
+		diagnostics.Update(collectStats(cars, carUpdates, gridUpdates))
 		select {
 		case update := <-botUpdates:
+			if query := update.CallbackQuery; query != nil && query.Message != nil && strings.HasPrefix(query.Data, "receipt:") {
+				text := ""
+				chat := query.Message.Chat.ID
+				carID, at, ok := parseReceiptData(query.Data)
+				car := cars[carID]
+				var charge chargeRecord
+				if ok && car != nil {
+					charge, ok = findCharge(car, at)
+				}
+				switch {
+				case role(&tgbotapi.Message{Chat: query.Message.Chat, From: query.From}) == "":
+					text = "Not allowed."
+				case !ok || car == nil:
+					text = "That charge is no longer recorded."
+				default:
+					name := fmt.Sprintf("receipt-%s.pdf", charge.at.In(location).Format("2006-01-02-1504"))
+					doc := tgbotapi.NewDocumentUpload(chat, tgbotapi.FileBytes{Name: name, Bytes: receiptPDF(car, charge, time.Now())})
+					if _, err := update.bot.Send(doc); err != nil {
+						log.Printf("Failed to send receipt to %d: %s", chat, err)
+						text = "Failed to send the receipt."
+					}
+				}
+				update.bot.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, text))
+				break
+			}
+			if query := update.CallbackQuery; query != nil && query.Message != nil {
+				text := "Only admins can change settings."
+				if role(&tgbotapi.Message{Chat: query.Message.Chat, From: query.From}) == RoleAdmin {
+					chat := query.Message.Chat.ID
+					if store.Chats[chat] == nil {
+						store.Chats[chat] = &ChatSettings{}
+					}
+					// in the chat's units, which the units button may change
+					units = chatUnits(store.Chats[chat], config.Units)
+					text = settingsCallback(store.Chats[chat], features, query.Data)
+					saveStore()
+					units = chatUnits(store.Chats[chat], config.Units)
+					menu, keyboard := settingsMenu(store.Chats[chat], features)
+					units = config.Units
+					edit := tgbotapi.NewEditMessageText(chat, query.Message.MessageID, menu)
+					edit.ReplyMarkup = &keyboard
+					update.bot.Send(edit)
+				}
+				update.bot.AnswerCallbackQuery(tgbotapi.NewCallback(query.ID, text))
+				break
+			}
+			if query := update.InlineQuery; query != nil {
+				// answered as the user's private chat would be
+				user := int64(query.From.ID)
+				results := []interface{}{}
+				if role(&tgbotapi.Message{Chat: &tgbotapi.Chat{ID: user}, From: query.From}) != "" {
+					language = replyLanguage(store.Chats[user], "", config.Language)
+					units = chatUnits(store.Chats[user], config.Units)
+					results = inlineResults(cars[carIDFor(user)], config.Templates, strings.ToLower(strings.TrimSpace(query.Query)), time.Now())
+					language, units = config.Language, config.Units
+				}
+				if _, err := update.bot.AnswerInlineQuery(tgbotapi.InlineConfig{InlineQueryID: query.ID, Results: results, IsPersonal: true}); err != nil {
+					log.Printf("Failed to answer inline query: %s", err)
+				}
+				break
+			}
 			if update.Message == nil {
 				break
 			}
 			log.Printf("[%s] %s", update.Message.From.UserName, update.Message.Text)
 
+			chat, bot := update.Message.Chat.ID, update.bot
+			if len(bots) > 1 && store.Bots[chat] != bot.Self.UserName {
+				store.Bots[chat] = bot.Self.UserName
+				saveStore()
+			}
+			if o, ok := onboardings[chat]; ok && !update.Message.IsCommand() {
+				text, keyboard, done, ok := o.Handle(update.Message.Text)
+				if done {
+					delete(onboardings, chat)
+				}
+				if done && ok {
+					if len(config.ChatIDs) == 0 {
+						store.ChatID = chat
+					}
+					store.Chats[chat] = &o.settings
+					saveStore()
+				}
+				msg := tgbotapi.NewMessage(chat, text)
+				msg.ReplyMarkup = replyKeyboard(keyboard)
+				bot.Send(msg)
+				break
+			}
+			if update.Message.Command() == "start" && update.Message.CommandArguments() != "" {
+				text := "⚠️ This pairing link is invalid or has expired."
+				if role, ok := store.Redeem(update.Message.CommandArguments(), update.Message.From.ID, time.Now()); ok {
+					log.Printf("Paired user %d (%s) as %s", update.Message.From.ID, update.Message.From.UserName, role)
+					text = fmt.Sprintf("✅ Paired as %s.", role)
+				}
+				saveStore()
+				bot.Send(tgbotapi.NewMessage(chat, text))
+				break
+			}
+			if len(notifyChats()) == 0 || (update.Message.Command() == "start" && isNotifyChat(chat) && role(update.Message) == RoleAdmin) {
+				o := newOnboarding(chat, carChoices(cars))
+				onboardings[chat] = o
+				text, keyboard := o.Start()
+				msg := tgbotapi.NewMessage(chat, text)
+				msg.ReplyMarkup = replyKeyboard(keyboard)
+				bot.Send(msg)
+				break
+			}
+			switch role(update.Message) {
+			case "":
+				log.Printf("Ignored /%s from unknown user %d in chat %d", update.Message.Command(), update.Message.From.ID, chat)
+				bot.Send(tgbotapi.NewMessage(chat, "Sorry, this bot is private."))
+				continue
+			case RoleViewer:
+				if adminOnly(update.Message.Command(), update.Message.CommandArguments()) {
+					bot.Send(tgbotapi.NewMessage(chat, fmt.Sprintf("Only admins can use /%s.", strings.TrimSpace(update.Message.Command()+" "+update.Message.CommandArguments()))))
+					continue
+				}
+			}
+
+			// replies are in the chat's language and units, notifications
+			// in LANGUAGE and UNITS. Only /status takes a language argument.
+			var languageArg string
+			if update.Message.Command() == "status" {
+				languageArg = update.Message.CommandArguments()
+			}
+			language = replyLanguage(store.Chats[chat], languageArg, config.Language)
+			units = chatUnits(store.Chats[chat], config.Units)
 			switch update.Message.Command() {
 			case "status":
-				car := cars[defaultCar]
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, statusMessage(car))
+				car := cars[carIDFor(chat)]
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, statusMessage(car, config.Templates, time.Now()))
+				msg.ParseMode = "HTML"
+				bot.Send(msg)
+			case "charge":
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, chargeMessage(cars[carIDFor(chat)], time.Now())))
+			case "climate":
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, climateMessage(cars[carIDFor(chat)])))
+			case "odometer":
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, odometerMessage(cars[carIDFor(chat)])))
+			case "range":
+				bot.Send(tgbotapi.NewMessage(update.Message.Chat.ID, rangeMessage(cars[carIDFor(chat)])))
+			case "cars":
+				bot.Send(tgbotapi.NewMessage(chat, carsMessage(cars, carIDFor(chat))))
+			case "car":
+				text := carCommand(store, cars, chat, carIDFor(chat), update.Message.CommandArguments())
+				saveStore()
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "location":
+				if !features.Enabled("location") {
+					bot.Send(tgbotapi.NewMessage(chat, "Location is disabled."))
+					break
+				}
+				bot.Send(locationReply(update.Message.Chat.ID, cars[carIDFor(chat)]))
+			case "drives":
+				text := "Usage: /drives [count]"
+				if n, err := parseRecentArgs(update.Message.CommandArguments()); err == nil {
+					text = drivesMessage(cars[carIDFor(chat)], n)
+				}
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "charges":
+				text := "Usage: /charges [count]"
+				if n, err := parseRecentArgs(update.Message.CommandArguments()); err == nil {
+					text = chargesMessage(cars[carIDFor(chat)], n)
+				}
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "simulate":
+				bot.Send(tgbotapi.NewMessage(chat, simulateMessage(cars[carIDFor(chat)], update.Message.CommandArguments(), now())))
+			case "stats":
+				bot.Send(tgbotapi.NewMessage(chat, statsMessage(cars[carIDFor(chat)], update.Message.CommandArguments(), now())))
+			case "efficiency":
+				bot.Send(tgbotapi.NewMessage(chat, efficiencyMessage(cars[carIDFor(chat)], now())))
+			case "battery":
+				bot.Send(tgbotapi.NewMessage(chat, batteryMessage(cars[carIDFor(chat)], now())))
+			case "export":
+				kind, period, err := parseExportArgs(update.Message.CommandArguments())
+				car := cars[carIDFor(chat)]
+				switch {
+				case err != nil:
+					bot.Send(tgbotapi.NewMessage(chat, "Usage: /export drives|charges [week|month|year|all]"))
+				case car == nil:
+					bot.Send(tgbotapi.NewMessage(chat, "No car data received yet."))
+				default:
+					data, rows := exportCSV(car, kind, period, now())
+					if rows == 0 {
+						bot.Send(tgbotapi.NewMessage(chat, fmt.Sprintf("No %s recorded in that period.", kind)))
+						break
+					}
+					doc := tgbotapi.NewDocumentUpload(chat, tgbotapi.FileBytes{Name: kind + ".csv", Bytes: data})
+					doc.Caption = plural(rows, strings.TrimSuffix(kind, "s"))
+					bot.Send(doc)
+				}
+			case "statement":
+				month, format, err := parseStatementArgs(update.Message.CommandArguments(), now())
+				car := cars[carIDFor(chat)]
+				switch {
+				case err != nil:
+					bot.Send(tgbotapi.NewMessage(chat, "Usage: /statement [YYYY-MM] [pdf|csv]"))
+				case car == nil:
+					bot.Send(tgbotapi.NewMessage(chat, "No car data received yet."))
+				case len(statementCharges(car, month)) == 0:
+					bot.Send(tgbotapi.NewMessage(chat, "No home charges recorded in "+month.Format("January 2006")+"."))
+				default:
+					name := "statement-" + month.Format("2006-01") + "." + format
+					data, _ := statementCSV(car, month)
+					if format == "pdf" {
+						data = statementPDF(car, month, now())
+					}
+					bot.Send(tgbotapi.NewDocumentUpload(chat, tgbotapi.FileBytes{Name: name, Bytes: data}))
+				}
+			case "places":
+				text := "Usage: /places [count] [week|month|year|all]"
+				if n, period, err := parsePlacesArgs(update.Message.CommandArguments()); err == nil {
+					text = placesMessage(cars[carIDFor(chat)], n, period, now())
+				}
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "locations":
+				car := cars[carIDFor(chat)]
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, locationsMessage(car))
+				bot.Send(msg)
+			case "evse":
+				text := "EVSE integration is disabled."
+				if features.Enabled("evse") {
+					text = evseCommand(evse, client, update.Message.CommandArguments())
+				}
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				bot.Send(msg)
+			case "timeline":
+				text := "Usage: /timeline [yesterday|YYYY-MM-DD]"
+				if day, err := parseDay(update.Message.CommandArguments(), now()); err == nil {
+					text = timelineMessage(cars[carIDFor(chat)], day)
+				}
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "handover":
+				car := cars[carIDFor(chat)]
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, handoverMessage(car))
+				bot.Send(msg)
+			case "settings":
+				if update.Message.CommandArguments() == "" {
+					settings := store.Chats[chat]
+					if settings == nil {
+						settings = &ChatSettings{}
+					}
+					text, keyboard := settingsMenu(settings, features)
+					msg := tgbotapi.NewMessage(chat, text)
+					msg.ReplyMarkup = keyboard
+					bot.Send(msg)
+					break
+				}
+				text := settingsCommand(features, update.Message.CommandArguments())
+				saveStore()
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				bot.Send(msg)
+			case "debug":
+				text := "Usage: /debug stats"
+				if update.Message.CommandArguments() == "stats" {
+					text = debugStatsMessage(diagnostics.Stats())
+				}
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
+				bot.Send(msg)
+			case "schedule":
+				text := scheduleCommand(store, chat, update.Message.CommandArguments())
+				saveStore()
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "watch":
+				text := watchCommand(store, chat, update.Message.CommandArguments())
+				saveStore()
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "quiet":
+				text := quietCommand(store, chat, update.Message.CommandArguments())
+				saveStore()
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "language":
+				text := languageCommand(store, chat, update.Message.CommandArguments())
+				saveStore()
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "pair":
+				role := update.Message.CommandArguments()
+				if role == "" {
+					role = RoleViewer
+				}
+				token, err := store.NewPairing(role, time.Now())
+				text := fmt.Sprintf("⚠️ %s. Usage: /pair [admin|viewer]", err)
+				if err == nil {
+					saveStore()
+					text = pairingMessage(bot.Self.UserName, token)
+				}
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "allow":
+				text := allowCommand(store, update.Message.CommandArguments())
+				saveStore()
+				bot.Send(tgbotapi.NewMessage(chat, text))
+			case "selftest":
+				checks := []selfCheck{checkMQTT(client.IsConnected())}
+				for _, chatID := range configuredChats(config, notifyChats()) {
+					checks = append(checks, checkChat(botFor(chatID), chatID))
+				}
+				checks = append(checks, checkGeocoder(), checkStorage(store))
+				bot.Send(tgbotapi.NewMessage(chat, selftestMessage(checks)))
+			case "help":
+				bot.Send(tgbotapi.NewMessage(chat, helpMessage(role(update.Message))))
+			case "reload":
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, reload())
+				bot.Send(msg)
+			case "wipe":
+				text := wipeCommand(store, cars, carIDFor(chat), update.Message.CommandArguments())
+				syncJobs()
+				saveStore()
+				msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
 				bot.Send(msg)
 			default:
-				msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Hello. Set TELEGRAM_CHAT_ID=%d", update.Message.Chat.ID))
+				msg := tgbotapi.NewMessage(chat, helpMessage(role(update.Message)))
 				msg.ReplyToMessageID = update.Message.MessageID
 				bot.Send(msg)
 			}
+			language, units = config.Language, config.Units
+		case holder := <-leaderLost:
+			log.Fatalf("Lost leadership to %s", holder)
+		case tick := <-minuteTimer.C:
+			minuteTimer.Reset(nextMinute())
+			now := tick.In(location)
+			syncJobs()
+			if scheduler.Tick(now) {
+				saveStore()
+			}
+		case <-hangup:
+			reload()
+		case text := <-hookReplies:
+			notify(Event{Type: "hook_reply", At: time.Now(), Message: text}, "")
+		case release := <-releases:
+			if release.TagName != "" && release.TagName != notifiedRelease {
+				notifiedRelease = release.TagName
+				notify(Event{Type: "release", At: time.Now(), Message: releaseMessage(release)}, "")
+			}
+		case watts := <-gridUpdates:
+			var charging float64
+			for _, car := range cars {
+				if car.Charging && car.State.Geofence == "Home" {
+					charging += float64(car.State.ChargerPower) * 1000
+				}
+			}
+			var text string
+			if !features.Enabled("loadshed") && !loadShedder.shedding {
+				break
+			}
+			switch loadShedder.Update(watts, charging) {
+			case loadShedExceeded:
+				text = fmt.Sprintf("⚠️ Grid import %.1fkW exceeds the %.1fkW limit while charging.", watts/1000, loadShedder.limit/1000)
+				if loadShedder.autoPause && evse != nil && features.Enabled("evse") {
+					if err := evse.Pause(client); err != nil {
+						log.Println("Failed to pause EVSE:", err)
+					} else {
+						loadShedder.paused = true
+						text += " Pausing wallbox."
+					}
+				}
+			case loadShedCleared:
+				text = fmt.Sprintf("✅ Grid import back to %.1fkW.", watts/1000)
+				if loadShedder.paused {
+					loadShedder.paused = false
+					if err := evse.Resume(client); err != nil {
+						log.Println("Failed to resume EVSE:", err)
+					} else {
+						text += " Resuming wallbox."
+					}
+				}
+			}
+			if text != "" {
+				notify(Event{Type: "grid_import", At: time.Now(), Message: text}, "")
+			}
 		case car := <-carUpdates:
-			log.Printf("State update: %+v", car.carState)
-			if car.charging && car.carState.chargerPower == 0 {
-				log.Printf("Finished charging: %+v", car.carState)
-				car.charging = false
-				text := finishChargingMessage(car.chargeStart, car.carState, car.chargePeak)
-				if text == "" {
-					break
+			if _, ok := cars[car.id]; !ok {
+				// a standby replica discovers cars long before it becomes
+				// leader, so warm up from here rather than from discovery
+				car.added = time.Now()
+				car.DriveGrace = config.DriveGrace
+				store.restoreHistory(car, time.Now())
+				cars[car.id] = car
+				// chats that haven't chosen with /car get the lowest ID, so
+				// the default doesn't depend on discovery order
+				if defaultCar == 0 || car.id < defaultCar {
+					defaultCar = car.id
 				}
-				msg := tgbotapi.NewMessage(chatId, text)
-				msg.ParseMode = "HTML"
-				bot.Send(msg)
-			} else if car.charging && car.carState.chargerPower > car.chargePeak.chargerPower {
-				car.chargePeak = car.carState
-				log.Printf("New charging peak: %+v", car.carState)
-			} else if !car.charging && car.carState.chargerPower > 0 {
-				log.Printf("Started charging: %+v", car.carState)
-				car.charging = true
-				car.chargeStart = car.carState
-				car.chargePeak = car.carState
-			}
-			if driveShiftState(car.carState.shiftState) && !car.driving {
-				// started driving
-				log.Printf("Started driving: %+v", car.carState)
-				car.driving = true
-				car.driveStart = car.carState
-			} else if !driveShiftState(car.carState.shiftState) && car.driving {
-				// finished driving
-				log.Printf("Finished driving: %+v", car.carState)
-				car.driving = false
-				text := finishDriveMessage(car.driveStart, car.carState)
-				if text == "" {
-					break
+			}
+			car.ApplyPending()
+			// a rounded position can cross a grid line while parked
+			car.NoTowing = privacyMode == PrivacyRound
+			if time.Since(car.added) < config.Warmup {
+				log.Printf("Warm-up state: %+v", car.State)
+				car.Absorb()
+				car.watched = car.State
+				break
+			}
+			log.Printf("State update: %+v", car.State)
+			for _, event := range car.Detect() {
+				event.CarID, event.Car = car.id, car.displayName
+				switch event.Type {
+				case teslamatebridge.Recalibrated:
+					car.recordCalibration(event)
+				case teslamatebridge.ChargeStarted:
+					log.Printf("Started charging: %+v", car.State)
+					car.curve = nil
+					car.energy = nil
+					car.milestone = 0
+					if evse != nil {
+						car.evseStart = evse.Energy()
+					}
+					hook.Emit(event)
+				case teslamatebridge.ChargeFinished:
+					log.Printf("Finished charging: %+v", car.State)
+					text := finishChargingMessage(car.ChargeStart, car.State, car.ChargePeak)
+					if text == "" {
+						continue
+					}
+					text = renderTemplate(config.Templates, "charge_finished", TemplateData{car.displayName, car.ChargeStart, car.State, car.ChargePeak}, text)
+					car.learnEfficiency(car.ChargeStart, car.State)
+					car.recordBattery(car.State)
+					if car.ChargePeak.ChargerPower > DCChargerPowerKw {
+						car.totals.dcCharges++
+					} else {
+						car.totals.acCharges++
+					}
+					var costs []BandCost
+					if features.Enabled("costs") {
+						costs = car.chargeCosts(config.PricesURL)
+					}
+					car.totals.chargeCost += totalCost(costs)
+					place := placeName(car.ChargeStart)
+					text += car.recordFinishedCharge(place, costs)
+					if evse != nil && features.Enabled("evse") && car.ChargeStart.Geofence == "Home" {
+						text += evseEnergyMessage(car.State.ChargeEnergyAdded-car.ChargeStart.ChargeEnergyAdded, car.evseStart, evse.Energy())
+					}
+					if features.Enabled("carbon") && car.ChargeStart.Geofence == "Home" {
+						text += carbonChargingMessage(car.ChargeStart, car.State)
+					}
+					if car.ChargePeak.ChargerPower > DCChargerPowerKw && len(config.CurveReference) > 0 {
+						text += curveMessage(car.curve, config.CurveReference)
+					}
+					text += calibrationMessage(car.ChargeCalibration)
+					car.recordTimeline(car.ChargeStart.At, fmt.Sprintf("🔌 Charged at %s, %d→%d%% until %s",
+						place, car.ChargeStart.BatteryLevel, car.State.BatteryLevel, clock(car.State.At)))
+					saveStore()
+					event.Message = text
+					if chargeInterrupted(car.State) {
+						notify(carEvent("charge_interrupted", car, car.ChargeStart, car.State, interruptedMessage(car.displayName, car.ChargeStart, car.State)), "")
+					} else {
+						notify(event, "HTML")
+					}
+					if config.FullChargeSticker != "" && fullCharge(car.ChargeStart, car.State) {
+						celebrate(event, func(chatID int64) tgbotapi.Chattable {
+							return tgbotapi.NewStickerShare(chatID, config.FullChargeSticker)
+						})
+					}
+					hook.Emit(event)
+				case teslamatebridge.PluggedIn:
+					if features.Enabled("carbon") && car.State.Geofence == "Home" {
+						text := fmt.Sprintf("🔌 Plugged in at Home. 🔋 %d%%", car.State.BatteryLevel)
+						text += carbonWindowMessage(time.Now(), config.CarbonWindowHours)
+						notify(carEvent(event.Type, car, car.State, car.State, text), "")
+					}
+					hook.Emit(event)
+				case teslamatebridge.Towed:
+					log.Printf("Moved without driving: %+v", car.State)
+					event.Message = towMessage(car.displayName, *event.Start, car.State)
+					notify(event, "")
+					hook.Emit(event)
+				case teslamatebridge.DriveStarted:
+					log.Printf("Started driving: %+v", car.State)
+					if privateDrive(car.DriveStart, car.DriveStart) {
+						start := redactPlace(car.DriveStart)
+						event.Start, event.End = &start, &start
+					}
+					hook.Emit(event)
+				case teslamatebridge.DriveFinished:
+					log.Printf("Finished driving: %+v", car.State)
+					start, end := *event.Start, *event.End
+					if car.Manoeuvre(start, end) {
+						log.Println("Reverse only manoeuvre, skipping")
+						continue
+					}
+					private := privateDrive(start, end)
+					if private {
+						start, end = redactPlace(start), redactPlace(end)
+						event.Start, event.End = &start, &end
+					}
+					text := finishDriveMessage(start, end, car.kmPerKwh())
+					if text == "" {
+						continue
+					}
+					text = renderTemplate(config.Templates, "drive_finished", TemplateData{car.displayName, start, end, end}, text)
+					precipitation := drivePrecipitation(end)
+					from, place := placeName(start), placeName(end)
+					car.recordDrive(start, end, from, place, precipitation)
+					text += speedMessage(car.drives[len(car.drives)-1])
+					text += weatherMessage(precipitation)
+					car.recordTimeline(start.At, fmt.Sprintf("🚗 Drove %s→%s (%.1f %s)",
+						from, place, distance(end.Odometer-start.Odometer), distanceUnit()))
+					car.recordTimeline(end.At, "🅿️ Parked at "+place)
+					car.leave(start.At)
+					car.recordVisit(end.At, place)
+					saveStore()
+					text += calibrationMessage(car.DriveCalibration)
+					event.Message = text
+					switch {
+					case private:
+						log.Println("Private drive, not notifying")
+					default:
+						notify(event, "HTML")
+					}
+					if passed, ok := milestone(config.MilestoneEvery, start.Odometer, end.Odometer); ok && config.MilestoneAnimation != "" && !private {
+						celebrate(event, func(chatID int64) tgbotapi.Chattable {
+							msg := tgbotapi.NewAnimationShare(chatID, config.MilestoneAnimation)
+							msg.Caption = milestoneMessage(car.displayName, passed)
+							return msg
+						})
+					}
+					hook.Emit(event)
 				}
-				msg := tgbotapi.NewMessage(chatId, text)
-				msg.ParseMode = "HTML"
-				bot.Send(msg)
 			}
-			if car.carState.geofence == "Home" {
-				power := car.carState.chargerActualCurrent * car.carState.chargerVoltage
+			if car.Charging {
+				car.sampleCurve()
+				car.sampleEnergy()
+			}
+			if text := car.checkChargeMilestone(config.ChargeMilestones); text != "" {
+				notify(carEvent("charge_milestone", car, car.ChargeStart, car.State, text), "")
+			}
+			if event, text := car.checkVenting(config.VentInsideTemp, config.VentOutsideTemp); event != "" {
+				notify(carEvent(teslamatebridge.EventType(event), car, car.State, car.State, text), "")
+			}
+			changes := teslamatebridge.Diff(car.watched, car.State)
+			if car.watched.At.IsZero() {
+				changes = nil
+			}
+			car.watched = car.State
+			for chatID, settings := range store.Chats {
+				if len(settings.Watch) == 0 || carIDFor(chatID) != car.id {
+					continue
+				}
+				if text := watchMessage(car, changes, settings.Watch); text != "" {
+					notifyTo(carEvent("watch", car, car.State, car.State, text), "", []int64{chatID})
+				}
+			}
+			if car.State.Geofence == "Home" {
+				power := car.State.ChargerActualCurrent * car.State.ChargerVoltage
 				event := map[string]interface{}{
 					"topic":     "power",
 					"device":    "power.zappi",
 					"power":     power,
-					"soc":       car.carState.batteryLevel,
+					"soc":       car.State.BatteryLevel,
 					"timestamp": time.Now().UTC().Format(TimeFormat),
-					"voltage":   car.carState.chargerVoltage,
+					"voltage":   car.State.ChargerVoltage,
 				}
 				payload, _ := json.Marshal(event)
 				token := client.Publish("gohome/power/power.zappi", 1, true, payload)
@@ -324,46 +1394,238 @@ func formatDuration(d time.Duration) string {
 }
 
 func finishChargingMessage(start, end, peak CarState) string {
-	battery := end.batteryLevel - start.batteryLevel
+	battery := end.BatteryLevel - start.BatteryLevel
 	if battery == 0 {
 		return ""
 	}
-	duration := end.at.Sub(start.at)
-	averagePower := float64(end.chargeEnergyAdded-start.chargeEnergyAdded) / duration.Hours()
-	milesAdded := (end.ratedBatteryRangeKm - start.ratedBatteryRangeKm) / KMPerMile
-	text := fmt.Sprintf("🔌 Charging finished at %s.\n🕗 %s→%s (%s)\n🔋 %d→%d%% (+ %d%%)\n🚗 %0.f→%.0f miles (+ %.1f miles).\n⚡ + %.1fkWh\nAverage Power: %.2fkW (Peak %dkW at %d%%)",
-		start.placeName(),
-		start.at.Format("15:04"), end.at.Format("15:04"), formatDuration(duration),
-		start.batteryLevel, end.batteryLevel, battery,
-		start.ratedBatteryRangeKm/KMPerMile, end.ratedBatteryRangeKm/KMPerMile, milesAdded,
-		end.chargeEnergyAdded, averagePower, peak.chargerPower, peak.batteryLevel)
+	duration := end.At.Sub(start.At)
+	averagePower := float64(end.ChargeEnergyAdded-start.ChargeEnergyAdded) / duration.Hours()
+	added := distance(end.RatedBatteryRangeKm - start.RatedBatteryRangeKm)
+	unit := distanceUnit()
+	text := fmt.Sprintf(tr("charge_finished"),
+		placeName(start),
+		clock(start.At), clock(end.At), formatDuration(duration),
+		start.BatteryLevel, end.BatteryLevel, battery,
+		distance(start.RatedBatteryRangeKm), distance(end.RatedBatteryRangeKm), unit, added, unit,
+		end.ChargeEnergyAdded, averagePower, peak.ChargerPower, peak.BatteryLevel)
 	return text
 }
 
-func finishDriveMessage(start, end CarState) string {
-	distance := (end.odometer - start.odometer) / KMPerMile
-	if distance < 0.1 {
+func finishDriveMessage(start, end CarState, kmPerKwh float32) string {
+	driven := distance(end.Odometer - start.Odometer)
+	if driven < 0.1 {
 		return ""
 	}
-	battery := end.batteryLevel - start.batteryLevel
-	eff := efficiency(start, end)
-	duration := end.at.Sub(start.at)
-	miles := (start.ratedBatteryRangeKm - end.ratedBatteryRangeKm) / KMPerMile
-	text := fmt.Sprintf("🚗 %s->%s <code>%.1f</code> miles 🌡 %.1f°C\n🕗 %s→%s (%s)\n🔋 %d→%d%% (%d%%)\n🚘 %0.f→%.0f miles (%.1f miles @ %.0fWh/mi)",
-		start.placeName(), end.placeName(), distance,
-		start.outsideTemp,
-		start.at.Format("15:04"), end.at.Format("15:04"), formatDuration(duration),
-		start.batteryLevel, end.batteryLevel, battery,
-		start.ratedBatteryRangeKm/KMPerMile, end.ratedBatteryRangeKm/KMPerMile, miles,
-		eff)
+	battery := end.BatteryLevel - start.BatteryLevel
+	eff := efficiency(start, end, kmPerKwh)
+	duration := end.At.Sub(start.At)
+	used := distance(start.RatedBatteryRangeKm - end.RatedBatteryRangeKm)
+	unit := distanceUnit()
+	text := fmt.Sprintf(tr("drive_finished"),
+		placeName(start), placeName(end), driven, unit,
+		formatTemperature(start.OutsideTemp),
+		clock(start.At), clock(end.At), formatDuration(duration),
+		start.BatteryLevel, end.BatteryLevel, battery,
+		distance(start.RatedBatteryRangeKm), distance(end.RatedBatteryRangeKm), unit, used, unit,
+		eff, efficiencyUnit())
 	return text
 }
 
-func statusMessage(car *Car) string {
-	text := fmt.Sprintf("🔋%d%%", car.carState.batteryLevel)
+// towMessage alerts that the car moved from where it was parked without
+// being driven.
+func towMessage(name string, parked, now CarState) string {
+	moved := distance(float32(teslamatebridge.DistanceKm(parked, now)))
+	return fmt.Sprintf("🚨 %s has moved %.1f %s from %s without being driven. Towed or transported?",
+		name, moved, distanceUnit(), placeName(parked))
+}
+
+// statusMessage is a compact HTML overview of the car.
+func statusMessage(car *Car, templates map[string]*template.Template, now time.Time) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	s := car.State
+	lines := []string{
+		carPrefix(car.id, fmt.Sprintf("<b>%s</b> %s", html.EscapeString(car.displayName), activity(car))),
+		fmt.Sprintf(tr("status"), s.BatteryLevel) + fmt.Sprintf(" · %.0f %s", distance(s.RatedBatteryRangeKm), distanceUnit()),
+	}
+	if s.PluggedIn {
+		lines = append(lines, tr("status_plugged_in"))
+	} else {
+		lines = append(lines, tr("status_unplugged"))
+	}
+	lines = append(lines, fmt.Sprintf(tr("status_temperature"), formatTemperature(s.InsideTemp), formatTemperature(s.OutsideTemp)))
+	if s.Geofence != "" || s.Latitude != 0 || s.Longitude != 0 {
+		lines = append(lines, "📍 "+html.EscapeString(placeName(s)))
+	}
+	lines = append(lines, fmt.Sprintf("🛣 %.0f %s", distance(s.Odometer), distanceUnit()))
+	if !s.At.IsZero() {
+		if ago := now.Sub(s.At); ago < time.Minute {
+			lines = append(lines, tr("status_updated_now"))
+		} else {
+			lines = append(lines, fmt.Sprintf(tr("status_updated"), formatDuration(ago)))
+		}
+	}
+	text := strings.Join(lines, "\n")
+	return renderTemplate(templates, "status", TemplateData{car.displayName, car.State, car.State, car.State}, text)
+}
+
+// activity describes what the car is doing.
+func activity(car *Car) string {
+	switch {
+	case car.Driving:
+		return tr("status_driving")
+	case car.Charging:
+		return fmt.Sprintf(tr("status_charging"), car.State.ChargerPower)
+	case car.state == "asleep" || car.state == "suspended":
+		return tr("status_asleep")
+	case car.state == "offline":
+		return tr("status_offline")
+	}
+	return tr("status_parked")
+}
+
+func ordinal(n int) string {
+	suffix := "th"
+	switch n % 10 {
+	case 1:
+		suffix = "st"
+	case 2:
+		suffix = "nd"
+	case 3:
+		suffix = "rd"
+	}
+	if n%100 >= 11 && n%100 <= 13 {
+		suffix = "th"
+	}
+	return fmt.Sprintf("%d%s", n, suffix)
+}
+
+func locationsMessage(car *Car) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	counts := map[string]int{}
+	for _, c := range car.charges {
+		if c.geofence != "" {
+			counts[c.geofence]++
+		}
+	}
+	if len(counts) == 0 {
+		return "No charges recorded yet."
+	}
+	places := make([]string, 0, len(counts))
+	max := 0
+	for place, n := range counts {
+		places = append(places, place)
+		if n > max {
+			max = n
+		}
+	}
+	sort.Slice(places, func(i, j int) bool {
+		if counts[places[i]] != counts[places[j]] {
+			return counts[places[i]] > counts[places[j]]
+		}
+		return places[i] < places[j]
+	})
+	text := "📍 Charges by location"
+	for _, place := range places {
+		n := counts[place]
+		bar := strings.Repeat("█", (n*10+max-1)/max)
+		text += fmt.Sprintf("\n%s %s %d", bar, place, n)
+	}
+	return text
+}
+
+func handoverMessage(car *Car) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	t := car.totals
+	text := fmt.Sprintf("🚗 Handover summary for %s\n", car.displayName)
+	text += fmt.Sprintf("Drives: %d (%.0f %s)\n", t.drives, distance(t.distanceKm), distanceUnit())
+	if t.distanceKm > 0 {
+		text += fmt.Sprintf("Efficiency: %.0f%s\n", t.ratedKmUsed/car.kmPerKwh()*1000/distance(t.distanceKm), efficiencyUnit())
+	}
+	text += fmt.Sprintf("Charges: %d AC, %d DC\n", t.acCharges, t.dcCharges)
+	if t.chargeCost > 0 {
+		text += fmt.Sprintf("Charging cost: ≈ %s at Home\n", tariff.formatCost(t.chargeCost))
+	}
+	if lost, ok := degradation(car.id); ok {
+		text += fmt.Sprintf("Degradation: %.1f%%\n", lost)
+	} else {
+		text += "Degradation: not tracked\n"
+	}
+	text += "(totals since the bridge started)\n\n"
+	text += "Before handing over:\n"
+	text += "☐ Remove the car from TeslaMate\n"
+	text += "☐ Wipe bridge data with /wipe car"
 	return text
 }
 
+func wipeCommand(store *Store, cars map[int]*Car, carId int, args string) string {
+	fields := strings.Fields(args)
+	if len(fields) == 0 || (fields[0] != "car" && fields[0] != "all") {
+		return "Usage: /wipe car|all"
+	}
+	if len(fields) < 2 || fields[1] != "confirm" {
+		what := "recorded history"
+		if fields[0] == "all" {
+			what = "recorded history and chats' preferences"
+		}
+		return fmt.Sprintf("⚠️ This permanently deletes %s. Send /wipe %s confirm to continue.", what, fields[0])
+	}
+	wiped := map[int]bool{}
+	if fields[0] == "all" {
+		for id, car := range cars {
+			car.wipe()
+			wiped[id] = true
+		}
+	} else if car, ok := cars[carId]; ok {
+		car.wipe()
+		wiped[carId] = true
+	}
+	// including cars not seen since the bridge restarted
+	for id := range store.Charges {
+		if wiped[id] || fields[0] == "all" {
+			delete(store.Charges, id)
+		}
+	}
+	for id := range store.Drives {
+		if wiped[id] || fields[0] == "all" {
+			delete(store.Drives, id)
+		}
+	}
+	for id := range store.Timeline {
+		if wiped[id] || fields[0] == "all" {
+			delete(store.Timeline, id)
+		}
+	}
+	for id := range store.Visits {
+		if wiped[id] || fields[0] == "all" {
+			delete(store.Visits, id)
+		}
+	}
+	if fields[0] == "all" {
+		// chats' preferences and schedules go too, the chats themselves
+		// stay set up
+		for _, settings := range store.Chats {
+			*settings = ChatSettings{}
+		}
+		for name := range store.Jobs {
+			delete(store.Jobs, name)
+		}
+		return "🗑 History and preferences deleted."
+	}
+	// chats that chose a wiped car go back to the default
+	for _, settings := range store.Chats {
+		if wiped[settings.CarID] {
+			settings.CarID = 0
+		}
+	}
+	return "🗑 History deleted."
+}
+
 type LookupResult struct {
 	DisplayName string `json:"display_name"`
 	Name        string `json:"name"`
@@ -10,9 +10,9 @@ import (
 func TestFinishChargingMessageHome(t *testing.T) {
 	startAt := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
 	endAt := startAt.Add(90 * time.Minute)
-	start := CarState{at: startAt, chargerPower: 7, chargeEnergyAdded: 0.0, batteryLevel: 50}
-	end := CarState{at: endAt, chargerPower: 0, chargeEnergyAdded: 3.8, batteryLevel: 55}
-	peak := CarState{chargerPower: 8, chargeEnergyAdded: 1, batteryLevel: 52}
+	start := CarState{At: startAt, ChargerPower: 7, ChargeEnergyAdded: 0.0, BatteryLevel: 50}
+	end := CarState{At: endAt, ChargerPower: 0, ChargeEnergyAdded: 3.8, BatteryLevel: 55}
+	peak := CarState{ChargerPower: 8, ChargeEnergyAdded: 1, BatteryLevel: 52}
 	message := finishChargingMessage(start, end, peak)
 	assert.Equal(t, message, "🔌 Charging finished at Soul Buoy.\n🕗 06:39→08:09 (1h30m)\n🔋 50→55% (+ 5%)\n🚗 0→0 miles (+ 0.0 miles).\n⚡ + 3.8kWh\nAverage Power: 2.53kW (Peak 8kW at 52%)")
 }
@@ -28,12 +28,21 @@ func TestFinishChargingMessageZero(t *testing.T) {
 func TestFinishDriveMessage(t *testing.T) {
 	startAt := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
 	endAt := startAt.Add(8 * time.Minute)
-	start := CarState{at: startAt, chargerPower: 7, chargeEnergyAdded: 0.0, batteryLevel: 50, odometer: 976, outsideTemp: 7.5, ratedBatteryRangeKm: 400, geofence: "Home"}
-	end := CarState{at: endAt, chargerPower: 0, chargeEnergyAdded: 3.8, batteryLevel: 48, odometer: 986, outsideTemp: 8.0, ratedBatteryRangeKm: 390, geofence: "", latitude: 52.3, longitude: 0.1}
-	message := finishDriveMessage(start, end)
+	start := CarState{At: startAt, ChargerPower: 7, ChargeEnergyAdded: 0.0, BatteryLevel: 50, Odometer: 976, OutsideTemp: 7.5, RatedBatteryRangeKm: 400, Geofence: "Home"}
+	end := CarState{At: endAt, ChargerPower: 0, ChargeEnergyAdded: 3.8, BatteryLevel: 48, Odometer: 986, OutsideTemp: 8.0, RatedBatteryRangeKm: 390, Geofence: "", Latitude: 52.3, Longitude: 0.1}
+	message := finishDriveMessage(start, end, RatedKMPerKwh)
 	assert.Equal(t, message, "🚗 Home->Cow Lane <code>6.2</code> miles 🌡 7.5°C\n🕗 06:39→06:47 (8m)\n🔋 50→48% (-2%)\n🚘 248→242 miles (6.2 miles @ 216Wh/mi)")
 }
 
+func TestSpeedMessage(t *testing.T) {
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	d := driveRecord{at: at, until: at.Add(15 * time.Minute), distanceKm: 16}
+	assert.Equal(t, "\n🏁 Average 40 mph", speedMessage(d))
+	d.maxSpeedKmh = 113
+	assert.Equal(t, "\n🏁 Average 40 mph, max 70 mph", speedMessage(d))
+	assert.Equal(t, "", speedMessage(driveRecord{at: at, until: at}))
+}
+
 func TestTruncate(t *testing.T) {
 	assert.Equal(t, "A", truncate("A", 20))
 	assert.Equal(t, "3, Hurrell Road", truncate("3, Hurrell Road, Cambridge, Cambridgeshire, East of England, England, CB4 3RQ, United Kingdom", 20))
@@ -41,11 +50,191 @@ func TestTruncate(t *testing.T) {
 }
 
 func TestPlaceNameLookup(t *testing.T) {
-	state := CarState{latitude: 52.223, longitude: 0.116}
-	assert.Equal(t, "19, Acton Way", state.placeName())
+	state := CarState{Latitude: 52.223, Longitude: 0.116}
+	assert.Equal(t, "19, Acton Way", placeName(state))
 }
 
 func TestPlaceNameGeofence(t *testing.T) {
-	state := CarState{latitude: 52.223, longitude: 0.116, geofence: "Home"}
-	assert.Equal(t, "Home", state.placeName())
+	state := CarState{Latitude: 52.223, Longitude: 0.116, Geofence: "Home"}
+	assert.Equal(t, "Home", placeName(state))
+}
+
+func TestOrdinal(t *testing.T) {
+	assert.Equal(t, "1st", ordinal(1))
+	assert.Equal(t, "2nd", ordinal(2))
+	assert.Equal(t, "4th", ordinal(4))
+	assert.Equal(t, "11th", ordinal(11))
+	assert.Equal(t, "22nd", ordinal(22))
+}
+
+func TestRecordCharge(t *testing.T) {
+	car := &Car{}
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	assert.Equal(t, 1, car.recordCharge(chargeRecord{at: at, geofence: "Home"}))
+	assert.Equal(t, 1, car.recordCharge(chargeRecord{at: at, geofence: "Work"}))
+	assert.Equal(t, 2, car.recordCharge(chargeRecord{at: at.AddDate(0, 0, 1), geofence: "Home"}))
+	assert.Equal(t, 1, car.recordCharge(chargeRecord{at: at.AddDate(0, 1, 0), geofence: "Home"}))
+	assert.Equal(t, "📍 Charges by location\n██████████ Home 3\n████ Work 1", locationsMessage(car))
+	assert.Equal(t, "No car data received yet.", locationsMessage(nil))
+}
+
+func TestRecordCalibration(t *testing.T) {
+	car := &Car{}
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car.recordCalibration(Event{At: at, Start: &CarState{BatteryLevel: 60, RatedBatteryRangeKm: 293}, End: &CarState{BatteryLevel: 60, RatedBatteryRangeKm: 313}})
+	assert.Equal(t, []calibrationRecord{{at: at, batteryLevel: 60, fromKm: 293, toKm: 313}}, car.calibrations)
+	assert.Equal(t, "\n🔧 Rated range recalibrated +12.4 miles (excluded)", calibrationMessage(20))
+}
+
+func TestPrivacyStrict(t *testing.T) {
+	defer func(mode string) { privacyMode = mode }(privacyMode)
+	privacyMode = PrivacyStrict
+	car := &Car{}
+	car.Update("latitude", "52.223")
+	assert.Equal(t, float32(0), car.State.Latitude)
+	assert.Equal(t, "away", placeName(car.State))
+	car.State.Geofence = "Home"
+	assert.Equal(t, "Home", placeName(car.State))
+}
+
+func TestPrivacyRound(t *testing.T) {
+	defer func(mode string) { privacyMode = mode }(privacyMode)
+	privacyMode = PrivacyRound
+	car := &Car{}
+	car.Update("latitude", "52.22345")
+	assert.InDelta(t, 52.22, car.State.Latitude, 0.0001)
+	assert.Equal(t, "away", placeName(car.State))
+	car.State.Geofence = "Home"
+	assert.Equal(t, "Home", placeName(car.State))
+}
+
+func TestPrune(t *testing.T) {
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car := &Car{}
+	car.recordCharge(chargeRecord{at: at, geofence: "Home"})
+	car.recordCharge(chargeRecord{at: at.AddDate(1, 0, 0), geofence: "Home"})
+	assert.Len(t, car.charges, 2)
+	car.recordCharge(chargeRecord{at: at.AddDate(2, 0, 1), geofence: "Home"})
+	assert.Len(t, car.charges, 2)
+	assert.Equal(t, at.AddDate(1, 0, 0), car.charges[0].at)
+}
+
+func TestWipeCommand(t *testing.T) {
+	defer func(l map[int]*EfficiencyFit, b map[int][]BatterySample, e map[int]string) {
+		learnedKMPerKwh, batteryHistory, carEmoji = l, b, e
+	}(learnedKMPerKwh, batteryHistory, carEmoji)
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	cars := map[int]*Car{1: {id: 1}, 2: {id: 2}}
+	store := &Store{Chats: map[int64]*ChatSettings{10: {CarID: 1}, 20: {CarID: 2, QuietHours: "22:00-07:00", Language: "de"}}}
+	store.init()
+	store.Charges[3] = []chargeRecord{{at: at}} // a car not seen since the restart
+	store.Drives[4] = []driveRecord{{at: at}}   // one with drives only
+	store.Timeline[1] = []timelineEntry{{at: at}}
+	store.Visits[1] = []visitRecord{{at: at}}
+	store.Jobs["status:20"] = at
+	learnedKMPerKwh = map[int]*EfficiencyFit{1: {}, 2: {}}
+	batteryHistory = map[int][]BatterySample{1: {{}}, 2: {{}}}
+	carEmoji = map[int]string{1: "🔴", 2: "🔵"}
+	cars[1].recordCharge(chargeRecord{at: at, geofence: "Home"})
+	cars[2].recordCharge(chargeRecord{at: at, geofence: "Home"})
+	assert.Equal(t, "Usage: /wipe car|all", wipeCommand(store, cars, 1, ""))
+	assert.Contains(t, wipeCommand(store, cars, 1, "car"), "/wipe car confirm")
+	assert.Len(t, cars[1].charges, 1)
+	wipeCommand(store, cars, 1, "car confirm")
+	assert.Empty(t, cars[1].charges)
+	assert.NotContains(t, learnedKMPerKwh, 1)
+	assert.NotContains(t, batteryHistory, 1)
+	assert.NotContains(t, carEmoji, 1)
+	assert.Equal(t, 0, store.Chats[10].CarID)
+	assert.NotContains(t, store.Timeline, 1)
+	assert.NotContains(t, store.Visits, 1)
+	assert.Len(t, cars[2].charges, 1)
+	assert.Contains(t, carEmoji, 2)
+	assert.Equal(t, 2, store.Chats[20].CarID)
+	assert.Contains(t, store.Charges, 3)
+	assert.Equal(t, "🗑 History and preferences deleted.", wipeCommand(store, cars, 1, "all confirm"))
+	assert.Empty(t, cars[2].charges)
+	assert.Empty(t, learnedKMPerKwh)
+	assert.Empty(t, batteryHistory)
+	assert.Empty(t, carEmoji)
+	assert.Equal(t, &ChatSettings{}, store.Chats[20])
+	assert.Empty(t, store.Charges)
+	assert.Empty(t, store.Drives)
+	assert.Empty(t, store.Jobs)
+}
+
+func TestHandoverMessage(t *testing.T) {
+	car := &Car{displayName: "Snowflake"}
+	car.recordDrive(CarState{Odometer: 976, RatedBatteryRangeKm: 400}, CarState{Odometer: 986, RatedBatteryRangeKm: 390}, "", "", NoPrecipitation)
+	car.totals.acCharges = 3
+	car.totals.dcCharges = 1
+	assert.Equal(t, "🚗 Handover summary for Snowflake\nDrives: 1 (6 miles)\nEfficiency: 216Wh/mi\nCharges: 3 AC, 1 DC\nDegradation: not tracked\n(totals since the bridge started)\n\nBefore handing over:\n☐ Remove the car from TeslaMate\n☐ Wipe bridge data with /wipe car", handoverMessage(car))
+	assert.Equal(t, "No car data received yet.", handoverMessage(nil))
+}
+
+func TestQueueCoalesces(t *testing.T) {
+	car := &Car{update: time.NewTimer(time.Hour)}
+	car.update.Stop()
+	car.Queue("battery_level", "60", time.Hour)
+	car.Queue("battery_level", "61", time.Hour)
+	car.Queue("shift_state", "D", time.Hour)
+	assert.True(t, car.scheduled)
+	assert.Equal(t, 0, car.State.BatteryLevel)
+	car.ApplyPending()
+	assert.False(t, car.scheduled)
+	assert.Equal(t, 61, car.State.BatteryLevel)
+	assert.Equal(t, "D", car.State.ShiftState)
+}
+
+func TestClientOptions(t *testing.T) {
+	opts, err := clientOptions(&Config{MQTTURL: "tcp://broker.local:8883", MQTTUsername: "teslamate", MQTTPassword: "secret"})
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://broker.local:8883", opts.Servers[0].String())
+	assert.Equal(t, "teslamate", opts.Username)
+	assert.Equal(t, "secret", opts.Password)
+}
+
+func TestClientOptionsTLS(t *testing.T) {
+	opts, err := clientOptions(&Config{MQTTURL: "tcp://broker.local:8883", MQTTTLS: true, MQTTTLSInsecure: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "ssl://broker.local:8883", opts.Servers[0].String())
+	assert.True(t, opts.TLSConfig.InsecureSkipVerify)
+
+	_, err = clientOptions(&Config{MQTTURL: "ssl://broker.local:8883", MQTTTLS: true, MQTTCACert: "missing.pem"})
+	assert.Error(t, err)
+}
+
+func TestClientOptionsWebsocket(t *testing.T) {
+	opts, err := clientOptions(&Config{MQTTURL: "ws://broker.local:8080/mqtt"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ws://broker.local:8080/mqtt", opts.Servers[0].String())
+
+	opts, err = clientOptions(&Config{MQTTURL: "ws://broker.local:8080/mqtt", MQTTTLS: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "wss://broker.local:8080/mqtt", opts.Servers[0].String())
+	assert.NotNil(t, opts.TLSConfig)
+
+	_, err = clientOptions(&Config{MQTTURL: "http://broker.local"})
+	assert.EqualError(t, err, "unsupported MQTT_URL scheme: http")
+}
+
+func TestTowMessage(t *testing.T) {
+	parked := CarState{Geofence: "Home", Latitude: 51.5, Longitude: -0.12}
+	now := CarState{Latitude: 51.52, Longitude: -0.12}
+	assert.Equal(t, "🚨 Snowflake has moved 1.4 miles from Home without being driven. Towed or transported?", towMessage("Snowflake", parked, now))
+}
+
+func TestStatusMessage(t *testing.T) {
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car := &Car{displayName: "Snow & Flake", state: "online"}
+	car.State = CarState{At: at, BatteryLevel: 61, RatedBatteryRangeKm: 335, PluggedIn: true, InsideTemp: 21, OutsideTemp: 7.5, Geofence: "Home", Odometer: 19876}
+	assert.Equal(t, "<b>Snow &amp; Flake</b> 🅿️ Parked\n🔋61% · 208 miles\n🔌 Plugged in\n🌡 Inside 21.0°C · outside 7.5°C\n📍 Home\n🛣 12345 miles\n🕗 Updated 5m ago", statusMessage(car, nil, at.Add(5*time.Minute)))
+
+	car.Charging = true
+	car.State.ChargerPower = 11
+	assert.Contains(t, statusMessage(car, nil, at), "</b> ⚡ Charging at 11kW\n")
+	assert.Contains(t, statusMessage(car, nil, at), "\n🕗 Updated just now")
+	car.Charging, car.state = false, "asleep"
+	assert.Contains(t, statusMessage(car, nil, at), "</b> 😴 Asleep\n")
+	assert.Equal(t, "No car data received yet.", statusMessage(nil, nil, at))
 }
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/barnybug/teslamate-telegram/pkg/teslamatebridge"
+)
+
+// Event categories that can be routed to their own chats.
+const (
+	CategoryCharge = "charge"
+	CategoryDrive  = "drive"
+	CategoryAlert  = "alert"
+	CategoryStatus = "status"
+)
+
+func eventCategory(eventType string) string {
+	switch {
+	case eventType == "charge_interrupted":
+		return CategoryAlert
+	case strings.HasPrefix(eventType, "charge_"), eventType == "plugged_in":
+		return CategoryCharge
+	case strings.HasPrefix(eventType, "drive_"):
+		return CategoryDrive
+	case eventType == "grid_import", eventType == "towed", eventType == "trip_warning", strings.HasPrefix(eventType, "vent_"):
+		return CategoryAlert
+	case eventType == "status":
+		return CategoryStatus
+	}
+	return ""
+}
+
+// parseRoutes parses TELEGRAM_ROUTES, e.g. "charge=123;drive=456,789".
+func parseRoutes(value string) (map[string][]int64, error) {
+	routes := map[string][]int64{}
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ' ' }) {
+		i := strings.Index(entry, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("expected category=chat_ids, got %q", entry)
+		}
+		category := entry[:i]
+		switch category {
+		case CategoryCharge, CategoryDrive, CategoryAlert, CategoryStatus:
+		default:
+			return nil, fmt.Errorf("unknown category %q", category)
+		}
+		ids, err := parseChatIDs(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		routes[category] = ids
+	}
+	return routes, nil
+}
+
+// parseCarRoutes parses TELEGRAM_CAR_ROUTES, e.g. "1=123;2=456,789", mapping
+// TeslaMate car IDs to chats.
+func parseCarRoutes(value string) (map[int][]int64, error) {
+	routes := map[int][]int64{}
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ' ' }) {
+		i := strings.Index(entry, "=")
+		if i == -1 {
+			return nil, fmt.Errorf("expected car_id=chat_ids, got %q", entry)
+		}
+		carID, err := strconv.Atoi(entry[:i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid car id %q", entry[:i])
+		}
+		ids, err := parseChatIDs(entry[i+1:])
+		if err != nil {
+			return nil, err
+		}
+		routes[carID] = ids
+	}
+	return routes, nil
+}
+
+// carChats returns the chats for a car, falling back to the default chats
+// when the car has no route.
+func carChats(routes map[int][]int64, carID int, defaults []int64) []int64 {
+	if chats, ok := routes[carID]; ok {
+		return chats
+	}
+	return defaults
+}
+
+// chatCar returns the car routed to a chat, or 0 if none is.
+func chatCar(routes map[int][]int64, chatID int64) int {
+	for carID, chats := range routes {
+		for _, id := range chats {
+			if id == chatID {
+				return carID
+			}
+		}
+	}
+	return 0
+}
+
+// routeChats returns the chats for an event type, falling back to the
+// default chats when its category has no route.
+func routeChats(routes map[string][]int64, eventType string, defaults []int64) []int64 {
+	if chats, ok := routes[eventCategory(eventType)]; ok {
+		return chats
+	}
+	return defaults
+}
+
+// notifyWanted reports whether a chat's notification preference includes
+// the event type. Events that aren't about charging or driving always go.
+func notifyWanted(settings *ChatSettings, eventType string) bool {
+	if settings == nil || settings.Notify == "" || settings.Notify == "all" {
+		return true
+	}
+	switch eventCategory(eventType) {
+	case CategoryCharge:
+		return settings.Notify == "charging"
+	case CategoryDrive:
+		return settings.Notify == "driving"
+	}
+	return true
+}
+
+// silent reports whether an event is sent without sound. Charges finishing
+// at home rarely need attention, while elsewhere the car usually needs
+// moving, so those keep the default sound.
+func silent(event Event) bool {
+	return event.Type == "charge_finished" && event.Start != nil && event.Start.Geofence == "Home"
+}
+
+// urgent reports whether an event is sent even during quiet hours. The car
+// moving without being driven may be theft, an interrupted charge leaves it
+// short in the morning, and a charge finishing away from Home usually needs
+// the car moving, so none can wait.
+func urgent(event Event) bool {
+	switch event.Type {
+	case teslamatebridge.Towed, "charge_interrupted":
+		return true
+	case teslamatebridge.ChargeFinished:
+		return event.Start != nil && event.Start.Geofence != "Home"
+	}
+	return false
+}
+
+// Thresholds are the smallest drives and charges worth a notification.
+type Thresholds struct {
+	DriveKm       float32
+	DriveDuration time.Duration
+	ChargeEnergy  float32 // kWh
+}
+
+// chatThresholds returns a chat's thresholds: its own from /settings, else
+// MIN_DRIVE_DISTANCE, MIN_DRIVE_DURATION and MIN_CHARGE_ENERGY.
+func chatThresholds(settings *ChatSettings, config *Config) Thresholds {
+	t := Thresholds{
+		DriveKm:       toKm(config.MinDriveDistance, config.Units),
+		DriveDuration: config.MinDriveDuration,
+		ChargeEnergy:  config.MinChargeEnergy,
+	}
+	if settings == nil {
+		return t
+	}
+	if settings.MinDriveDistance != nil {
+		t.DriveKm = toKm(*settings.MinDriveDistance, chatUnits(settings, config.Units))
+	}
+	if settings.MinDriveMinutes != nil {
+		t.DriveDuration = time.Duration(*settings.MinDriveMinutes) * time.Minute
+	}
+	if settings.MinChargeEnergy != nil {
+		t.ChargeEnergy = *settings.MinChargeEnergy
+	}
+	return t
+}
+
+// belowThreshold reports whether a finished drive or charge is too small to
+// be worth a notification.
+func belowThreshold(t Thresholds, event Event) bool {
+	if event.Start == nil || event.End == nil {
+		return false
+	}
+	start, end := event.Start, event.End
+	switch event.Type {
+	case teslamatebridge.DriveFinished:
+		return end.Odometer-start.Odometer < t.DriveKm || end.At.Sub(start.At) < t.DriveDuration
+	case teslamatebridge.ChargeFinished:
+		return end.ChargeEnergyAdded-start.ChargeEnergyAdded < t.ChargeEnergy
+	}
+	return false
+}
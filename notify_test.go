@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRoutes(t *testing.T) {
+	routes, err := parseRoutes("charge=123;drive=456,789 alert=-100")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]int64{"charge": {123}, "drive": {456, 789}, "alert": {-100}}, routes)
+
+	_, err = parseRoutes("weather=123")
+	assert.EqualError(t, err, `unknown category "weather"`)
+	_, err = parseRoutes("charge")
+	assert.EqualError(t, err, `expected category=chat_ids, got "charge"`)
+}
+
+func TestRouteChats(t *testing.T) {
+	routes := map[string][]int64{"charge": {123}, "alert": {-100}}
+	defaults := []int64{1}
+	assert.Equal(t, []int64{123}, routeChats(routes, "charge_finished", defaults))
+	assert.Equal(t, []int64{123}, routeChats(routes, "plugged_in", defaults))
+	assert.Equal(t, []int64{-100}, routeChats(routes, "grid_import", defaults))
+	assert.Equal(t, defaults, routeChats(routes, "drive_finished", defaults))
+	assert.Equal(t, defaults, routeChats(routes, "release", defaults))
+}
+
+func TestParseCarRoutes(t *testing.T) {
+	routes, err := parseCarRoutes("1=123;2=456,789")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int][]int64{1: {123}, 2: {456, 789}}, routes)
+
+	_, err = parseCarRoutes("x=123")
+	assert.EqualError(t, err, `invalid car id "x"`)
+	_, err = parseCarRoutes("1")
+	assert.EqualError(t, err, `expected car_id=chat_ids, got "1"`)
+}
+
+func TestCarChats(t *testing.T) {
+	routes := map[int][]int64{1: {123}, 2: {456, 789}}
+	defaults := []int64{1}
+	assert.Equal(t, []int64{456, 789}, carChats(routes, 2, defaults))
+	assert.Equal(t, defaults, carChats(routes, 3, defaults))
+	assert.Equal(t, 2, chatCar(routes, 789))
+	assert.Equal(t, 0, chatCar(routes, 1))
+}
+
+func TestNotifyWanted(t *testing.T) {
+	assert.True(t, notifyWanted(nil, "drive_finished"))
+	assert.True(t, notifyWanted(&ChatSettings{Notify: "all"}, "drive_finished"))
+	assert.False(t, notifyWanted(&ChatSettings{Notify: "charging"}, "drive_finished"))
+	assert.True(t, notifyWanted(&ChatSettings{Notify: "charging"}, "plugged_in"))
+	assert.False(t, notifyWanted(&ChatSettings{Notify: "none"}, "charge_finished"))
+	assert.True(t, notifyWanted(&ChatSettings{Notify: "none"}, "grid_import"))
+}
+
+func TestSilent(t *testing.T) {
+	home, away := CarState{Geofence: "Home"}, CarState{Geofence: "Supercharger"}
+	assert.True(t, silent(Event{Type: "charge_finished", Start: &home}))
+	assert.False(t, silent(Event{Type: "charge_finished", Start: &away}))
+	assert.False(t, silent(Event{Type: "charge_finished"}))
+	assert.False(t, silent(Event{Type: "drive_finished", Start: &home}))
+}
+
+func TestBelowThreshold(t *testing.T) {
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	config := &Config{MinDriveDistance: 1, MinDriveDuration: 5 * time.Minute, MinChargeEnergy: 2}
+	thresholds := chatThresholds(nil, config)
+	start := CarState{At: at, Odometer: 1000}
+	driveway := CarState{At: at.Add(time.Minute), Odometer: 1000.2}
+	shops := CarState{At: at.Add(10 * time.Minute), Odometer: 1005}
+	assert.True(t, belowThreshold(thresholds, Event{Type: "drive_finished", Start: &start, End: &driveway}))
+	assert.False(t, belowThreshold(thresholds, Event{Type: "drive_finished", Start: &start, End: &shops}))
+	topUp := CarState{ChargeEnergyAdded: 0.8}
+	assert.True(t, belowThreshold(thresholds, Event{Type: "charge_finished", Start: &start, End: &topUp}))
+	assert.False(t, belowThreshold(Thresholds{}, Event{Type: "charge_finished", Start: &start, End: &topUp}))
+}
+
+func TestChatThresholds(t *testing.T) {
+	config := &Config{Units: UnitsImperial, MinDriveDistance: 1, MinDriveDuration: 5 * time.Minute, MinChargeEnergy: 2}
+	assert.Equal(t, Thresholds{DriveKm: KMPerMile, DriveDuration: 5 * time.Minute, ChargeEnergy: 2}, chatThresholds(&ChatSettings{}, config))
+	distance, minutes, energy := float32(5), 0, float32(1)
+	settings := &ChatSettings{Units: UnitsMetric, MinDriveDistance: &distance, MinDriveMinutes: &minutes, MinChargeEnergy: &energy}
+	assert.Equal(t, Thresholds{DriveKm: 5, ChargeEnergy: 1}, chatThresholds(settings, config))
+}
+
+func TestUrgent(t *testing.T) {
+	assert.True(t, urgent(Event{Type: "towed"}))
+	assert.True(t, urgent(Event{Type: "charge_interrupted"}))
+	assert.True(t, urgent(Event{Type: "charge_finished", Start: &CarState{Geofence: "Supercharger"}}))
+	assert.False(t, urgent(Event{Type: "charge_finished", Start: &CarState{Geofence: "Home"}}))
+	assert.False(t, urgent(Event{Type: "grid_import"}))
+	assert.Equal(t, CategoryAlert, eventCategory("towed"))
+	assert.Equal(t, CategoryAlert, eventCategory("charge_interrupted"))
+	assert.Equal(t, CategoryAlert, eventCategory("trip_warning"))
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	onboardingConfirm = iota
+	onboardingUnits
+	onboardingCar
+	onboardingNotify
+)
+
+type carChoice struct {
+	id   int
+	name string
+}
+
+// Onboarding walks a new chat through setup one question at a time.
+type Onboarding struct {
+	chatID   int64
+	step     int
+	settings ChatSettings
+	cars     []carChoice
+}
+
+func newOnboarding(chatID int64, cars []carChoice) *Onboarding {
+	return &Onboarding{chatID: chatID, cars: cars}
+}
+
+// Start returns the first question.
+func (o *Onboarding) Start() (string, [][]string) {
+	o.step = onboardingConfirm
+	return fmt.Sprintf("👋 Welcome! This chat's ID is %d. Send notifications here?", o.chatID), [][]string{{"Yes", "No"}}
+}
+
+// Handle takes the answer to the current question and returns the next one.
+// done is set once setup is finished or abandoned; ok reports which.
+func (o *Onboarding) Handle(answer string) (reply string, keyboard [][]string, done, ok bool) {
+	switch o.step {
+	case onboardingConfirm:
+		switch answer {
+		case "Yes":
+			o.step = onboardingUnits
+			return "Which units?", [][]string{{"Miles", "Kilometres"}}, false, false
+		case "No":
+			return "OK, send /start to set up later.", nil, true, false
+		}
+	case onboardingUnits:
+		switch answer {
+		case "Miles":
+			o.settings.Units = "imperial"
+		case "Kilometres":
+			o.settings.Units = "metric"
+		default:
+			return "Please choose Miles or Kilometres.", [][]string{{"Miles", "Kilometres"}}, false, false
+		}
+		o.step = onboardingCar
+		if len(o.cars) == 0 {
+			return o.askNotify("No cars discovered yet, using the first one seen.")
+		}
+		var names []string
+		for _, car := range o.cars {
+			names = append(names, car.name)
+		}
+		return "Which car?", [][]string{names}, false, false
+	case onboardingCar:
+		for _, car := range o.cars {
+			if car.name == answer {
+				o.settings.CarID = car.id
+				return o.askNotify("")
+			}
+		}
+		return "Please choose one of the cars.", nil, false, false
+	case onboardingNotify:
+		notify := strings.ToLower(answer)
+		switch notify {
+		case "all", "charging", "driving", "none":
+			o.settings.Notify = notify
+			return fmt.Sprintf("✅ All set. Units: %s, notifications: %s.", o.settings.Units, notify), nil, true, true
+		}
+		return "Please choose All, Charging, Driving or None.", notifyChoices, false, false
+	}
+	reply, keyboard = o.Start()
+	return reply, keyboard, false, false
+}
+
+func replyKeyboard(keyboard [][]string) interface{} {
+	if keyboard == nil {
+		return tgbotapi.NewRemoveKeyboard(false)
+	}
+	var rows [][]tgbotapi.KeyboardButton
+	for _, row := range keyboard {
+		var buttons []tgbotapi.KeyboardButton
+		for _, text := range row {
+			buttons = append(buttons, tgbotapi.NewKeyboardButton(text))
+		}
+		rows = append(rows, tgbotapi.NewKeyboardButtonRow(buttons...))
+	}
+	markup := tgbotapi.NewReplyKeyboard(rows...)
+	markup.OneTimeKeyboard = true
+	return markup
+}
+
+func carChoices(cars map[int]*Car) []carChoice {
+	var choices []carChoice
+	for id, car := range cars {
+		name := car.displayName
+		if name == "" {
+			name = fmt.Sprintf("Car %d", id)
+		}
+		choices = append(choices, carChoice{id: id, name: name})
+	}
+	sort.Slice(choices, func(i, j int) bool { return choices[i].id < choices[j].id })
+	return choices
+}
+
+var notifyChoices = [][]string{{"All", "Charging"}, {"Driving", "None"}}
+
+func (o *Onboarding) askNotify(prefix string) (string, [][]string, bool, bool) {
+	o.step = onboardingNotify
+	text := "Which notifications?"
+	if prefix != "" {
+		text = prefix + "\n" + text
+	}
+	return text, notifyChoices, false, false
+}
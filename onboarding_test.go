@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOnboarding(t *testing.T) {
+	o := newOnboarding(1234, []carChoice{{id: 1, name: "Snowflake"}})
+	text, keyboard := o.Start()
+	assert.Equal(t, "👋 Welcome! This chat's ID is 1234. Send notifications here?", text)
+	assert.Equal(t, [][]string{{"Yes", "No"}}, keyboard)
+
+	text, _, done, _ := o.Handle("Yes")
+	assert.Equal(t, "Which units?", text)
+	assert.False(t, done)
+	text, keyboard, _, _ = o.Handle("Kilometres")
+	assert.Equal(t, "Which car?", text)
+	assert.Equal(t, [][]string{{"Snowflake"}}, keyboard)
+	text, _, _, _ = o.Handle("Snowflake")
+	assert.Equal(t, "Which notifications?", text)
+	text, _, _, _ = o.Handle("Sometimes")
+	assert.Equal(t, "Please choose All, Charging, Driving or None.", text)
+	text, _, done, ok := o.Handle("Charging")
+	assert.True(t, done)
+	assert.True(t, ok)
+	assert.Equal(t, "✅ All set. Units: metric, notifications: charging.", text)
+	assert.Equal(t, ChatSettings{Units: "metric", CarID: 1, Notify: "charging"}, o.settings)
+}
+
+func TestOnboardingDeclined(t *testing.T) {
+	o := newOnboarding(1234, nil)
+	o.Start()
+	_, _, done, ok := o.Handle("No")
+	assert.True(t, done)
+	assert.False(t, ok)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleViewer = "viewer"
+)
+
+const PairingValidity = 24 * time.Hour
+
+// Pairing is a one-time token that grants a role to whoever redeems it.
+type Pairing struct {
+	Role    string    `json:"role"`
+	Expires time.Time `json:"expires"`
+}
+
+func (s *Store) NewPairing(role string, now time.Time) (string, error) {
+	if role != RoleAdmin && role != RoleViewer {
+		return "", fmt.Errorf("unknown role %s", role)
+	}
+	for token, p := range s.Pairings {
+		if now.After(p.Expires) {
+			delete(s.Pairings, token)
+		}
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+	s.Pairings[token] = &Pairing{Role: role, Expires: now.Add(PairingValidity)}
+	return token, nil
+}
+
+// Redeem consumes a pairing token, granting its role to the user.
+func (s *Store) Redeem(token string, userID int, now time.Time) (string, bool) {
+	p, ok := s.Pairings[token]
+	if !ok {
+		return "", false
+	}
+	delete(s.Pairings, token)
+	if now.After(p.Expires) {
+		return "", false
+	}
+	s.Users[userID] = p.Role
+	return p.Role, true
+}
+
+func pairingMessage(botName, token string) string {
+	return fmt.Sprintf("🔗 Share this link to pair a new user (single use, valid %s):\nhttps://t.me/%s?start=%s",
+		formatDuration(PairingValidity), botName, token)
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPairing(t *testing.T) {
+	s := &Store{Pairings: map[string]*Pairing{}, Users: map[int]string{}}
+	now := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	token, err := s.NewPairing(RoleViewer, now)
+	assert.NoError(t, err)
+	assert.Len(t, token, 32)
+
+	role, ok := s.Redeem(token, 42, now.Add(time.Hour))
+	assert.True(t, ok)
+	assert.Equal(t, RoleViewer, role)
+	assert.Equal(t, RoleViewer, s.Users[42])
+
+	_, ok = s.Redeem(token, 43, now.Add(time.Hour))
+	assert.False(t, ok, "tokens are single use")
+
+	token, _ = s.NewPairing(RoleAdmin, now)
+	_, ok = s.Redeem(token, 43, now.Add(25*time.Hour))
+	assert.False(t, ok, "tokens expire")
+
+	_, err = s.NewPairing("owner", now)
+	assert.EqualError(t, err, "unknown role owner")
+}
+
+func TestPairingMessage(t *testing.T) {
+	assert.Equal(t, "🔗 Share this link to pair a new user (single use, valid 24h0m):\nhttps://t.me/mytesla_bot?start=abc", pairingMessage("mytesla_bot", "abc"))
+}
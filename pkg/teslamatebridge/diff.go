@@ -0,0 +1,53 @@
+package teslamatebridge
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Change is a field whose value differs between two states, named by its
+// TeslaMate topic key.
+type Change struct {
+	Field  string
+	Before string
+	After  string
+}
+
+// Fields lists the keys of State that can be compared, in declaration order.
+func Fields() []string {
+	var fields []string
+	t := reflect.TypeOf(State{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := fieldName(t.Field(i)); name != "" {
+			fields = append(fields, name)
+		}
+	}
+	return fields
+}
+
+// Diff returns the fields that changed from before to after. The update time
+// isn't a field.
+func Diff(before, after State) []Change {
+	var changes []Change
+	b, a := reflect.ValueOf(before), reflect.ValueOf(after)
+	t := b.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := fieldName(t.Field(i))
+		if name == "" {
+			continue
+		}
+		if from, to := b.Field(i).Interface(), a.Field(i).Interface(); from != to {
+			changes = append(changes, Change{Field: name, Before: fmt.Sprint(from), After: fmt.Sprint(to)})
+		}
+	}
+	return changes
+}
+
+func fieldName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "at" {
+		return ""
+	}
+	return name
+}
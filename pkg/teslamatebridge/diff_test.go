@@ -0,0 +1,20 @@
+package teslamatebridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	before := State{At: time.Now(), BatteryLevel: 60, Geofence: "Home"}
+	after := State{At: time.Now().Add(time.Minute), BatteryLevel: 61, Geofence: "Home", PluggedIn: true}
+	assert.Equal(t, []Change{
+		{Field: "battery_level", Before: "60", After: "61"},
+		{Field: "plugged_in", Before: "false", After: "true"},
+	}, Diff(before, after))
+	assert.Empty(t, Diff(after, after))
+	assert.Contains(t, Fields(), "charge_limit_soc")
+	assert.NotContains(t, Fields(), "at")
+}
@@ -0,0 +1,43 @@
+// Package teslamatebridge turns TeslaMate's MQTT updates into charge and
+// drive sessions, so they can be reported however the embedding program
+// likes. Feed each car's topic values to a Session and collect the Events it
+// detects:
+//
+//	carID, key, err := teslamatebridge.ParseTopic("", msg.Topic())
+//	session := sessions[carID]
+//	session.Update(key, string(msg.Payload()))
+//	for _, event := range session.Detect() {
+//		// event.Type, event.Start, event.End
+//	}
+package teslamatebridge
+
+import "time"
+
+// EventType identifies what happened to the car.
+type EventType string
+
+const (
+	ChargeStarted  EventType = "charge_started"
+	ChargeFinished EventType = "charge_finished"
+	DriveStarted   EventType = "drive_started"
+	DriveFinished  EventType = "drive_finished"
+	PluggedIn      EventType = "plugged_in"
+	// Recalibrated is a jump in rated range at constant battery level, from
+	// Start.RatedBatteryRangeKm to End.RatedBatteryRangeKm.
+	Recalibrated EventType = "recalibrated"
+	// Towed is the car moving away from where it was parked, Start, without
+	// the odometer changing: towed or on a transporter.
+	Towed EventType = "towed"
+)
+
+// Event is something that happened to a car, with its state at the start
+// and end of the session where that applies.
+type Event struct {
+	Type    EventType `json:"type"`
+	CarID   int       `json:"car_id"`
+	Car     string    `json:"car"`
+	At      time.Time `json:"at"`
+	Start   *State    `json:"start,omitempty"`
+	End     *State    `json:"end,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
@@ -0,0 +1,229 @@
+package teslamatebridge
+
+import (
+	"math"
+	"time"
+)
+
+// Rated range jump at constant SOC treated as a BMS recalibration. Larger than
+// the ~5km a single percent is worth so ordinary SOC steps aren't caught.
+const CalibrationJumpKm = 8
+
+// Distance the car can move while paused in a drive's grace period before
+// the pause starts over, as it's evidently still on its way.
+const DrivePauseMoveKm = 0.05
+
+// A drive in reverse only, shorter than both of these, is shunting the car
+// around rather than going anywhere.
+const (
+	ManoeuvreMaxKm       = 0.16
+	ManoeuvreMaxDuration = time.Minute
+)
+
+// Distance a parked car can move without the odometer changing before it's
+// taken as towed or transported. Well above GPS drift.
+const TowDistanceKm = 0.5
+
+// Session follows one car's state and detects when charges and drives start
+// and finish.
+type Session struct {
+	State State
+
+	Charging    bool
+	ChargeStart State
+	ChargePeak  State // state at the highest charger power so far
+
+	Driving       bool
+	DriveStart    State
+	DriveForward  bool // D was used in the drive, rather than only R
+	DriveMaxSpeed int  // km/h, the highest seen in the drive
+	// Time the car must stay out of D and R before a drive finishes, so a
+	// moment in P at traffic lights doesn't split the drive. 0 finishes at
+	// once.
+	DriveGrace time.Duration
+	DrivePause *State           // where the drive paused, during the grace
+	Now        func() time.Time // clock for the grace, time.Now if nil
+
+	PluggedIn bool
+
+	Parked State // where the car was last left
+	Towing bool  // moved from Parked without being driven
+	// Positions are too coarse to tell towing from the position jumping,
+	// e.g. rounded for privacy, so Towed isn't detected.
+	NoTowing bool
+
+	// rated range recalibrations during the current charge/drive
+	ChargeCalibration float32
+	DriveCalibration  float32
+
+	ratedLevel int     // battery level at the last rated range update
+	events     []Event // waiting for Detect
+}
+
+// Update applies a topic value, noting any rated range recalibration.
+func (s *Session) Update(key, value string) {
+	prev := s.State.RatedBatteryRangeKm
+	s.State.Update(key, value)
+	if key != "rated_battery_range_km" {
+		return
+	}
+	rated := s.State.RatedBatteryRangeKm
+	if prev != 0 && s.ratedLevel == s.State.BatteryLevel && abs(rated-prev) >= CalibrationJumpKm {
+		s.recalibrate(prev, rated)
+	}
+	s.ratedLevel = s.State.BatteryLevel
+}
+
+// recalibrate shifts the start of any session in progress so the jump
+// doesn't count as energy used or added.
+func (s *Session) recalibrate(fromKm, toKm float32) {
+	from := s.State
+	from.RatedBatteryRangeKm = fromKm
+	to := s.State
+	s.events = append(s.events, Event{Type: Recalibrated, At: s.State.At, Start: &from, End: &to})
+	delta := toKm - fromKm
+	if s.Driving {
+		s.DriveStart.RatedBatteryRangeKm += delta
+		s.DriveCalibration += delta
+	}
+	if s.Charging {
+		s.ChargeStart.RatedBatteryRangeKm += delta
+		s.ChargeCalibration += delta
+	}
+}
+
+// Detect compares the current state with the sessions in progress and
+// returns the events since it was last called.
+func (s *Session) Detect() []Event {
+	events := s.events
+	s.events = nil
+	event := func(eventType EventType, start State) {
+		end := s.State
+		events = append(events, Event{Type: eventType, At: end.At, Start: &start, End: &end})
+	}
+
+	if s.Charging && s.State.ChargerPower == 0 {
+		s.Charging = false
+		event(ChargeFinished, s.ChargeStart)
+	} else if s.Charging && s.State.ChargerPower > s.ChargePeak.ChargerPower {
+		s.ChargePeak = s.State
+	} else if !s.Charging && s.State.ChargerPower > 0 {
+		s.Charging = true
+		s.ChargeStart = s.State
+		s.ChargePeak = s.State
+		s.ChargeCalibration = 0
+		event(ChargeStarted, s.ChargeStart)
+	}
+	if s.State.PluggedIn && !s.PluggedIn {
+		event(PluggedIn, s.State)
+	}
+	s.PluggedIn = s.State.PluggedIn
+	if s.State.Driving() && !s.Driving {
+		s.Driving = true
+		s.DriveStart = s.State
+		s.DriveCalibration = 0
+		s.DriveForward = s.State.ShiftState == "D"
+		s.DriveMaxSpeed = s.State.Speed
+		event(DriveStarted, s.DriveStart)
+	} else if s.State.Driving() {
+		s.DrivePause = nil
+		s.DriveForward = s.DriveForward || s.State.ShiftState == "D"
+		if s.State.Speed > s.DriveMaxSpeed {
+			s.DriveMaxSpeed = s.State.Speed
+		}
+	} else if s.Driving && s.pauseOver() {
+		s.Driving = false
+		start, end := s.DriveStart, s.State
+		if s.DrivePause != nil {
+			end.At = s.DrivePause.At
+		}
+		s.DrivePause = nil
+		events = append(events, Event{Type: DriveFinished, At: end.At, Start: &start, End: &end})
+	}
+	if s.Driving {
+		s.Towing = false
+		s.Parked = State{}
+	} else if s.Parked.Odometer != s.State.Odometer || !located(s.Parked) {
+		s.Parked = s.State
+	} else if !s.NoTowing && !s.Towing && located(s.State) && DistanceKm(s.Parked, s.State) >= TowDistanceKm {
+		s.Towing = true
+		event(Towed, s.Parked)
+	}
+	return events
+}
+
+// Manoeuvre reports whether a finished drive was only shunting the car, such
+// as reversing in the driveway.
+func (s *Session) Manoeuvre(start, end State) bool {
+	return !s.DriveForward && end.Odometer-start.Odometer < ManoeuvreMaxKm && end.At.Sub(start.At) < ManoeuvreMaxDuration
+}
+
+// pauseOver reports whether the car has been out of D and R, without
+// moving, for the grace period.
+func (s *Session) pauseOver() bool {
+	if s.DriveGrace == 0 {
+		return true
+	}
+	if s.DrivePause == nil || (located(*s.DrivePause) && located(s.State) && DistanceKm(*s.DrivePause, s.State) > DrivePauseMoveKm) {
+		pause := s.State
+		s.DrivePause = &pause
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+	return now().Sub(s.DrivePause.At) >= s.DriveGrace
+}
+
+// DrivePaused reports whether a drive is waiting out its grace period, so
+// Detect should be called again once it has passed.
+func (s *Session) DrivePaused() bool {
+	return s.DrivePause != nil
+}
+
+// located reports whether a state has a position.
+func located(s State) bool {
+	return s.Latitude != 0 || s.Longitude != 0
+}
+
+// DistanceKm is the great circle distance between two states' positions.
+func DistanceKm(a, b State) float64 {
+	const earthRadiusKm = 6371
+	lat1, lat2 := float64(a.Latitude)*math.Pi/180, float64(b.Latitude)*math.Pi/180
+	dlat := lat2 - lat1
+	dlon := float64(b.Longitude-a.Longitude) * math.Pi / 180
+	h := math.Pow(math.Sin(dlat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dlon/2), 2)
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
+
+// Absorb takes on the current state as the baseline without treating it as
+// a transition. Used while retained messages replay after startup, so stale
+// values don't produce events.
+func (s *Session) Absorb() {
+	s.events = nil
+	if s.State.ChargerPower > 0 && !s.Charging {
+		s.Charging = true
+		s.ChargeStart = s.State
+		s.ChargePeak = s.State
+	} else if s.State.ChargerPower == 0 {
+		s.Charging = false
+	}
+	if s.State.Driving() && !s.Driving {
+		s.Driving = true
+		s.DriveStart = s.State
+		s.DriveForward = s.State.ShiftState == "D"
+		s.DriveMaxSpeed = s.State.Speed
+	} else if !s.State.Driving() {
+		s.Driving = false
+	}
+	s.DrivePause = nil
+	s.PluggedIn = s.State.PluggedIn
+	s.Parked = s.State
+}
+
+func abs(f float32) float32 {
+	if f < 0 {
+		return -f
+	}
+	return f
+}
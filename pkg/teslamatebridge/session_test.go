@@ -0,0 +1,208 @@
+package teslamatebridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func eventTypes(events []Event) []EventType {
+	var types []EventType
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	return types
+}
+
+func TestDetectCharge(t *testing.T) {
+	s := &Session{}
+	s.Update("plugged_in", "true")
+	s.Update("charger_power", "7")
+	assert.Equal(t, []EventType{ChargeStarted, PluggedIn}, eventTypes(s.Detect()))
+	s.Update("charger_power", "11")
+	assert.Empty(t, s.Detect())
+	assert.Equal(t, 11, s.ChargePeak.ChargerPower)
+	s.Update("charger_power", "0")
+	events := s.Detect()
+	assert.Equal(t, []EventType{ChargeFinished}, eventTypes(events))
+	assert.Equal(t, 7, events[0].Start.ChargerPower)
+	assert.Equal(t, 0, events[0].End.ChargerPower)
+}
+
+func TestDetectDrive(t *testing.T) {
+	s := &Session{}
+	s.Update("odometer", "976")
+	s.Update("shift_state", "D")
+	assert.Equal(t, []EventType{DriveStarted}, eventTypes(s.Detect()))
+	s.Update("odometer", "986")
+	s.Update("shift_state", "P")
+	events := s.Detect()
+	assert.Equal(t, []EventType{DriveFinished}, eventTypes(events))
+	assert.Equal(t, float32(976), events[0].Start.Odometer)
+	assert.Equal(t, float32(986), events[0].End.Odometer)
+}
+
+func TestDriveMaxSpeed(t *testing.T) {
+	s := &Session{}
+	s.Update("shift_state", "D")
+	s.Update("speed", "30")
+	s.Detect()
+	s.Update("speed", "96")
+	s.Detect()
+	s.Update("speed", "48")
+	s.Detect()
+	assert.Equal(t, 96, s.DriveMaxSpeed)
+	s.Update("speed", "")
+	s.Update("shift_state", "P")
+	s.Detect()
+	assert.Equal(t, 96, s.DriveMaxSpeed)
+	s.Update("shift_state", "D")
+	s.Detect()
+	assert.Equal(t, 0, s.DriveMaxSpeed)
+}
+
+func TestRecalibration(t *testing.T) {
+	s := &Session{Driving: true}
+	s.DriveStart = State{RatedBatteryRangeKm: 300, Odometer: 100}
+	s.State.ShiftState = "D"
+	s.Update("battery_level", "60")
+	s.Update("rated_battery_range_km", "295")
+	s.Update("rated_battery_range_km", "293")
+	s.Update("battery_level", "58")
+	s.Update("rated_battery_range_km", "283")
+	s.Update("battery_level", "60")
+	s.Update("rated_battery_range_km", "293")
+	assert.Empty(t, s.Detect())
+	s.Update("rated_battery_range_km", "313")
+	events := s.Detect()
+	assert.Equal(t, []EventType{Recalibrated}, eventTypes(events))
+	assert.Equal(t, float32(293), events[0].Start.RatedBatteryRangeKm)
+	assert.Equal(t, float32(313), events[0].End.RatedBatteryRangeKm)
+	assert.Equal(t, float32(320), s.DriveStart.RatedBatteryRangeKm)
+	assert.Equal(t, float32(20), s.DriveCalibration)
+}
+
+func TestAbsorb(t *testing.T) {
+	s := &Session{}
+	s.Update("charger_power", "7")
+	s.Update("plugged_in", "true")
+	s.Update("shift_state", "P")
+	s.Absorb()
+	assert.True(t, s.Charging)
+	assert.Equal(t, 7, s.ChargeStart.ChargerPower)
+	assert.True(t, s.PluggedIn)
+	assert.False(t, s.Driving)
+	assert.Empty(t, s.Detect())
+
+	s.Update("charger_power", "0")
+	s.Absorb()
+	assert.False(t, s.Charging)
+}
+
+func TestDetectTow(t *testing.T) {
+	s := &Session{}
+	s.Update("odometer", "986")
+	s.Update("latitude", "51.5000")
+	s.Update("longitude", "-0.1200")
+	assert.Empty(t, s.Detect())
+	s.Update("latitude", "51.5010")
+	assert.Empty(t, s.Detect(), "GPS drift")
+	s.Update("latitude", "51.5100")
+	events := s.Detect()
+	assert.Equal(t, []EventType{Towed}, eventTypes(events))
+	assert.Equal(t, float32(51.5), events[0].Start.Latitude)
+	s.Update("latitude", "51.5200")
+	assert.Empty(t, s.Detect(), "alerts once")
+
+	s.Update("shift_state", "D")
+	assert.Equal(t, []EventType{DriveStarted}, eventTypes(s.Detect()))
+	assert.False(t, s.Towing)
+}
+
+func TestNoTowing(t *testing.T) {
+	s := &Session{NoTowing: true}
+	s.Update("odometer", "986")
+	s.Update("latitude", "51.50")
+	s.Update("longitude", "-0.12")
+	assert.Empty(t, s.Detect())
+	s.Update("latitude", "51.51")
+	assert.Empty(t, s.Detect(), "rounded position crossing a grid line")
+}
+
+func TestParkedMovesWithOdometer(t *testing.T) {
+	s := &Session{}
+	s.Update("latitude", "51.5000")
+	s.Update("longitude", "-0.1200")
+	s.Detect()
+	// position arriving before the odometer after a drive isn't a tow
+	s.Update("latitude", "51.5100")
+	s.Update("odometer", "987")
+	s.Detect()
+	assert.False(t, s.Towing)
+}
+
+func TestDriveGrace(t *testing.T) {
+	now := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	s := &Session{DriveGrace: 2 * time.Minute, Now: func() time.Time { return now }}
+	update := func(key, value string) {
+		s.Update(key, value)
+		s.State.At = now
+	}
+	update("latitude", "51.5000")
+	update("longitude", "-0.1200")
+	update("shift_state", "D")
+	assert.Equal(t, []EventType{DriveStarted}, eventTypes(s.Detect()))
+
+	// a moment in P at the lights
+	now = now.Add(time.Minute)
+	update("shift_state", "P")
+	assert.Empty(t, s.Detect())
+	assert.True(t, s.DrivePaused())
+	now = now.Add(time.Minute)
+	update("shift_state", "D")
+	assert.Empty(t, s.Detect())
+	assert.False(t, s.DrivePaused())
+
+	// parked, but still rolling into the space
+	now = now.Add(time.Minute)
+	update("shift_state", "P")
+	assert.Empty(t, s.Detect())
+	now = now.Add(time.Minute)
+	update("latitude", "51.5010")
+	parkedAt := now
+	assert.Empty(t, s.Detect())
+	now = now.Add(90 * time.Second)
+	assert.Empty(t, s.Detect())
+
+	now = now.Add(time.Minute)
+	events := s.Detect()
+	assert.Equal(t, []EventType{DriveFinished}, eventTypes(events))
+	assert.Equal(t, parkedAt, events[0].End.At)
+	assert.False(t, s.DrivePaused())
+}
+
+func TestManoeuvre(t *testing.T) {
+	s := &Session{}
+	s.Update("odometer", "976")
+	s.Update("shift_state", "R")
+	s.Detect()
+	s.Update("odometer", "976.05")
+	s.Update("shift_state", "P")
+	events := s.Detect()
+	assert.Equal(t, []EventType{DriveFinished}, eventTypes(events))
+	assert.True(t, s.Manoeuvre(*events[0].Start, *events[0].End))
+
+	s.Update("shift_state", "R")
+	s.Detect()
+	s.Update("shift_state", "D")
+	s.Detect()
+	s.Update("shift_state", "P")
+	events = s.Detect()
+	assert.False(t, s.Manoeuvre(*events[0].Start, *events[0].End), "drove forward too")
+
+	start := State{Odometer: 976, At: time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)}
+	s.DriveForward = false
+	assert.False(t, s.Manoeuvre(start, State{Odometer: 976.5, At: start.At}), "too far")
+	assert.False(t, s.Manoeuvre(start, State{Odometer: 976, At: start.At.Add(2 * time.Minute)}), "too long")
+}
@@ -0,0 +1,151 @@
+package teslamatebridge
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// State is a car's latest values from TeslaMate's MQTT topics.
+type State struct {
+	At                   time.Time `json:"at"`
+	Geofence             string    `json:"geofence"`
+	ChargerPower         int       `json:"charger_power"`
+	ChargerVoltage       int       `json:"charger_voltage"`
+	TimeToFullCharge     float32   `json:"time_to_full_charge"`
+	ChargerActualCurrent int       `json:"charger_actual_current"`
+	ChargeEnergyAdded    float32   `json:"charge_energy_added"`
+	EstBatteryRangeKm    float32   `json:"est_battery_range_km"`
+	RatedBatteryRangeKm  float32   `json:"rated_battery_range_km"`
+	IdealBatteryRangeKm  float32   `json:"ideal_battery_range_km"`
+	BatteryLevel         int       `json:"battery_level"`
+	ChargeLimitSoc       int       `json:"charge_limit_soc"`
+	ChargingState        string    `json:"charging_state"` // e.g. Charging, Complete, Stopped
+	// Next scheduled charge, zero if none is set
+	ScheduledChargingStartTime time.Time `json:"scheduled_charging_start_time"`
+	ShiftState                 string    `json:"shift_state"`
+	Speed                      int       `json:"speed"` // km/h, 0 when parked
+	Odometer                   float32   `json:"odometer"`
+	OutsideTemp                float32   `json:"outside_temp"`
+	InsideTemp                 float32   `json:"inside_temp"`
+	IsClimateOn                bool      `json:"is_climate_on"`
+	IsPreconditioning          bool      `json:"is_preconditioning"`
+	DriverTempSetting          float32   `json:"driver_temp_setting"` // 0 unless published
+	PluggedIn                  bool      `json:"plugged_in"`
+	Latitude                   float32   `json:"latitude"`
+	Longitude                  float32   `json:"longitude"`
+}
+
+// Update applies the value of a car's topic, e.g. "battery_level". Unknown
+// keys and values that don't parse are ignored.
+func (s *State) Update(key string, value string) {
+	s.At = time.Now()
+	switch key {
+	case "shift_state":
+		s.ShiftState = value
+	case "geofence":
+		s.Geofence = value
+	case "charger_power":
+		if ivalue, err := strconv.Atoi(value); err == nil {
+			s.ChargerPower = ivalue
+		}
+	case "charger_voltage":
+		if ivalue, err := strconv.Atoi(value); err == nil {
+			s.ChargerVoltage = ivalue
+		}
+	case "time_to_full_charge":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.TimeToFullCharge = float32(fvalue)
+		}
+	case "charger_actual_current":
+		if ivalue, err := strconv.Atoi(value); err == nil {
+			s.ChargerActualCurrent = ivalue
+		}
+	case "charge_energy_added":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.ChargeEnergyAdded = float32(fvalue)
+		}
+	case "est_battery_range_km":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.EstBatteryRangeKm = float32(fvalue)
+		}
+	case "ideal_battery_range_km":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.IdealBatteryRangeKm = float32(fvalue)
+		}
+	case "rated_battery_range_km":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.RatedBatteryRangeKm = float32(fvalue)
+		}
+	case "battery_level":
+		if ivalue, err := strconv.Atoi(value); err == nil {
+			s.BatteryLevel = ivalue
+		}
+	case "charge_limit_soc":
+		if ivalue, err := strconv.Atoi(value); err == nil {
+			s.ChargeLimitSoc = ivalue
+		}
+	case "charging_state":
+		s.ChargingState = value
+	case "scheduled_charging_start_time":
+		// empty when no charge is scheduled
+		s.ScheduledChargingStartTime, _ = time.Parse(time.RFC3339, value)
+	case "speed":
+		// empty while parked
+		s.Speed, _ = strconv.Atoi(value)
+	case "odometer":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.Odometer = float32(fvalue)
+		}
+	case "outside_temp":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.OutsideTemp = float32(fvalue)
+		}
+	case "inside_temp":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.InsideTemp = float32(fvalue)
+		}
+	case "is_climate_on":
+		s.IsClimateOn = (value == "true")
+	case "is_preconditioning":
+		s.IsPreconditioning = (value == "true")
+	case "driver_temp_setting":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.DriverTempSetting = float32(fvalue)
+		}
+	case "plugged_in":
+		s.PluggedIn = (value == "true")
+	case "latitude":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.Latitude = float32(fvalue)
+		}
+	case "longitude":
+		if fvalue, err := strconv.ParseFloat(value, 32); err == nil {
+			s.Longitude = float32(fvalue)
+		}
+	}
+}
+
+// Driving reports whether the car is in a gear that counts as driving.
+func (s State) Driving() bool {
+	return s.ShiftState == "D" || s.ShiftState == "R"
+}
+
+// TopicPrefix is the TeslaMate car topic prefix, under MQTT_NAMESPACE if set.
+func TopicPrefix(namespace string) string {
+	if namespace != "" {
+		return namespace + "/teslamate/cars/"
+	}
+	return "teslamate/cars/"
+}
+
+// ParseTopic splits a TeslaMate car topic into the car ID and key.
+func ParseTopic(namespace, topic string) (carID int, key string, err error) {
+	prefix := TopicPrefix(namespace)
+	if !strings.HasPrefix(topic, prefix) {
+		return 0, "", fmt.Errorf("topic outside %s", prefix)
+	}
+	_, err = fmt.Sscanf(strings.TrimPrefix(topic, prefix), "%d/%s", &carID, &key)
+	return
+}
@@ -0,0 +1,55 @@
+package teslamatebridge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTopic(t *testing.T) {
+	carID, key, err := ParseTopic("", "teslamate/cars/1/battery_level")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, carID)
+	assert.Equal(t, "battery_level", key)
+
+	carID, key, err = ParseTopic("garage", "garage/teslamate/cars/2/shift_state")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, carID)
+	assert.Equal(t, "shift_state", key)
+
+	_, _, err = ParseTopic("garage", "teslamate/cars/2/shift_state")
+	assert.Error(t, err)
+}
+
+func TestStateUpdate(t *testing.T) {
+	var s State
+	s.Update("battery_level", "61")
+	s.Update("rated_battery_range_km", "334.87")
+	s.Update("plugged_in", "true")
+	s.Update("shift_state", "R")
+	s.Update("odometer", "garbage")
+	assert.Equal(t, 61, s.BatteryLevel)
+	assert.InDelta(t, 334.87, s.RatedBatteryRangeKm, 0.001)
+	assert.True(t, s.PluggedIn)
+	assert.True(t, s.Driving())
+	assert.Equal(t, float32(0), s.Odometer)
+}
+
+func TestUpdateClimate(t *testing.T) {
+	var s State
+	s.Update("is_climate_on", "true")
+	s.Update("is_preconditioning", "false")
+	s.Update("driver_temp_setting", "20.5")
+	assert.True(t, s.IsClimateOn)
+	assert.False(t, s.IsPreconditioning)
+	assert.Equal(t, float32(20.5), s.DriverTempSetting)
+}
+
+func TestScheduledChargingStartTime(t *testing.T) {
+	var s State
+	s.Update("scheduled_charging_start_time", "2021-04-09T23:30:00Z")
+	assert.Equal(t, time.Date(2021, 4, 9, 23, 30, 0, 0, time.UTC), s.ScheduledChargingStartTime)
+	s.Update("scheduled_charging_start_time", "")
+	assert.True(t, s.ScheduledChargingStartTime.IsZero())
+}
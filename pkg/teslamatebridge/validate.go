@@ -0,0 +1,46 @@
+package teslamatebridge
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Plausible bounds for numeric topics. Values outside them are corrupt
+// rather than unusual.
+var bounds = map[string][2]float64{
+	"charger_power":          {0, 1000},
+	"charger_voltage":        {0, 1000},
+	"time_to_full_charge":    {0, 100},
+	"charger_actual_current": {0, 1000},
+	"charge_energy_added":    {0, 500},
+	"est_battery_range_km":   {0, 2000},
+	"rated_battery_range_km": {0, 2000},
+	"ideal_battery_range_km": {0, 2000},
+	"battery_level":          {0, 100},
+	"charge_limit_soc":       {0, 100},
+	"speed":                  {0, 400},
+	"odometer":               {0, 5e6},
+	"outside_temp":           {-80, 80},
+	"inside_temp":            {-80, 100},
+	"driver_temp_setting":    {0, 40},
+	"latitude":               {-90, 90},
+	"longitude":              {-180, 180},
+}
+
+// Validate checks a topic value is a plausible number, for the topics that
+// are numbers. Empty values, which TeslaMate sends for unknown, are valid.
+func Validate(key, value string) error {
+	b, ok := bounds[key]
+	if !ok || value == "" {
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s: not a number: %q", key, value)
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) || f < b[0] || f > b[1] {
+		return fmt.Errorf("%s: %v out of range %v to %v", key, f, b[0], b[1])
+	}
+	return nil
+}
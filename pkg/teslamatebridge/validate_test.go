@@ -0,0 +1,18 @@
+package teslamatebridge
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate("battery_level", "61"))
+	assert.NoError(t, Validate("outside_temp", "-7.5"))
+	assert.NoError(t, Validate("shift_state", "D"))
+	assert.NoError(t, Validate("charger_power", ""))
+	assert.EqualError(t, Validate("battery_level", "101"), "battery_level: 101 out of range 0 to 100")
+	assert.EqualError(t, Validate("odometer", "-3"), "odometer: -3 out of range 0 to 5e+06")
+	assert.EqualError(t, Validate("rated_battery_range_km", "NaN"), "rated_battery_range_km: NaN out of range 0 to 2000")
+	assert.EqualError(t, Validate("latitude", "north"), `latitude: not a number: "north"`)
+}
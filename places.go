@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// visitRecord is a stay where the car parked after a drive. until is zero
+// while the car is still there.
+type visitRecord struct {
+	at    time.Time
+	until time.Time
+	place string
+}
+
+func (car *Car) recordVisit(at time.Time, place string) {
+	car.visits = append(car.visits, visitRecord{at: at, place: place})
+	car.prune(at)
+}
+
+// leave ends the current visit, if any.
+func (car *Car) leave(at time.Time) {
+	if n := len(car.visits); n > 0 && car.visits[n-1].until.IsZero() {
+		car.visits[n-1].until = at
+	}
+}
+
+var placesPeriods = map[string]time.Duration{
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"year":  365 * 24 * time.Hour,
+	"all":   0,
+}
+
+// parsePlacesArgs parses the optional count and period for /places,
+// defaulting to the top 5 over the last month.
+func parsePlacesArgs(args string) (n int, period string, err error) {
+	n, period = 5, "month"
+	for _, field := range strings.Fields(args) {
+		if _, ok := placesPeriods[field]; ok {
+			period = field
+		} else if n, err = parseCount(field); err != nil || n < 1 {
+			return 0, "", fmt.Errorf("invalid count %q", field)
+		}
+	}
+	return n, period, nil
+}
+
+func formatDwell(d time.Duration) string {
+	if d < 24*time.Hour {
+		return formatDuration(d)
+	}
+	return fmt.Sprintf("%dd%dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+}
+
+// placesMessage lists the places the car parked most often over the period,
+// with the time spent at each.
+func placesMessage(car *Car, n int, period string, now time.Time) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	var since time.Time
+	if d := placesPeriods[period]; d != 0 {
+		since = now.Add(-d)
+	}
+	visits := map[string]int{}
+	dwell := map[string]time.Duration{}
+	for _, v := range car.visits {
+		until := v.until
+		if until.IsZero() {
+			until = now
+		}
+		if until.Before(since) {
+			continue
+		}
+		at := v.at
+		if at.Before(since) {
+			at = since
+		}
+		visits[v.place]++
+		dwell[v.place] += until.Sub(at)
+	}
+	if len(visits) == 0 {
+		return "No visits recorded yet."
+	}
+	places := make([]string, 0, len(visits))
+	for place := range visits {
+		places = append(places, place)
+	}
+	sort.Slice(places, func(i, j int) bool {
+		if visits[places[i]] != visits[places[j]] {
+			return visits[places[i]] > visits[places[j]]
+		}
+		return places[i] < places[j]
+	})
+	if len(places) > n {
+		places = places[:n]
+	}
+	text := "📍 Most visited"
+	if period != "all" {
+		text += " in the last " + period
+	}
+	for i, place := range places {
+		unit := "visits"
+		if visits[place] == 1 {
+			unit = "visit"
+		}
+		text += fmt.Sprintf("\n%d. %s: %d %s, %s", i+1, place, visits[place], unit, formatDwell(dwell[place]))
+	}
+	return text
+}
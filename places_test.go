@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePlacesArgs(t *testing.T) {
+	n, period, err := parsePlacesArgs("")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	assert.Equal(t, "month", period)
+	n, period, _ = parsePlacesArgs("3 year")
+	assert.Equal(t, 3, n)
+	assert.Equal(t, "year", period)
+	_, _, err = parsePlacesArgs("fortnight")
+	assert.Error(t, err)
+}
+
+func TestPlacesMessage(t *testing.T) {
+	now := time.Date(2021, 4, 9, 18, 0, 0, 0, time.UTC)
+	car := &Car{}
+	assert.Equal(t, "No car data received yet.", placesMessage(nil, 5, "month", now))
+	assert.Equal(t, "No visits recorded yet.", placesMessage(car, 5, "month", now))
+	car.recordVisit(now.AddDate(0, -2, 0), "Gym")
+	car.leave(now.AddDate(0, -2, 0).Add(time.Hour))
+	car.recordVisit(now.Add(-30*time.Hour), "Home")
+	car.leave(now.Add(-20 * time.Hour))
+	car.recordVisit(now.Add(-19*time.Hour), "Work")
+	car.leave(now.Add(-11 * time.Hour))
+	car.recordVisit(now.Add(-10*time.Hour), "Home")
+	assert.Equal(t, "📍 Most visited in the last month\n1. Home: 2 visits, 20h0m\n2. Work: 1 visit, 8h0m", placesMessage(car, 5, "month", now))
+	assert.Equal(t, "📍 Most visited\n1. Home: 2 visits, 20h0m", placesMessage(car, 1, "all", now))
+}
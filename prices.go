@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// PricePeriod is a dynamic electricity price, such as a half hour of
+// Octopus Agile.
+type PricePeriod struct {
+	From  time.Time
+	To    time.Time
+	Price float32 // per kWh
+}
+
+// Octopus standard unit rates, in pence per kWh.
+type pricesResponse struct {
+	Results []struct {
+		ValueIncVAT float32   `json:"value_inc_vat"`
+		ValidFrom   time.Time `json:"valid_from"`
+		ValidTo     time.Time `json:"valid_to"`
+	} `json:"results"`
+}
+
+// priceLookup fetches the prices between from and to from PRICES_URL, an
+// Octopus standard-unit-rates endpoint such as
+// https://api.octopus.energy/v1/products/AGILE-FLEX-22-11-25/electricity-tariffs/E-1R-AGILE-FLEX-22-11-25-C/standard-unit-rates/
+// or any endpoint returning the same JSON.
+func priceLookup(endpoint string, from, to time.Time) ([]PricePeriod, error) {
+	query := url.Values{}
+	query.Add("period_from", from.UTC().Format(time.RFC3339))
+	query.Add("period_to", to.UTC().Format(time.RFC3339))
+	// looked up on the main loop as a charge finishes, so a stalled API
+	// mustn't hold it up
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(endpoint + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("prices lookup: %s", resp.Status)
+	}
+	var result pricesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	var periods []PricePeriod
+	for _, r := range result.Results {
+		periods = append(periods, PricePeriod{From: r.ValidFrom, To: r.ValidTo, Price: r.ValueIncVAT / 100})
+	}
+	return periods, nil
+}
+
+func priceAt(periods []PricePeriod, at time.Time) (float32, bool) {
+	for _, p := range periods {
+		if !at.Before(p.From) && at.Before(p.To) {
+			return p.Price, true
+		}
+	}
+	return 0, false
+}
+
+// splitPrices prices the energy added between each sample at the dynamic
+// price at the time, spreading it evenly over the minutes between them. It
+// returns false if any minute has no price.
+func splitPrices(samples []energySample, periods []PricePeriod) ([]BandCost, bool) {
+	var costs bandTotals
+	for i := 1; i < len(samples); i++ {
+		start, end := samples[i-1].at, samples[i].at
+		minutes := int(end.Sub(start) / time.Minute)
+		if minutes < 1 {
+			minutes = 1
+		}
+		kWh := (samples[i].added - samples[i-1].added) / float32(minutes)
+		for m := 0; m < minutes; m++ {
+			price, ok := priceAt(periods, start.Add(time.Duration(m)*time.Minute))
+			if !ok {
+				return nil, false
+			}
+			costs.add("dynamic", price, kWh)
+		}
+	}
+	return costs, len(costs) > 0
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPriceLookup(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2021-04-09T01:00:00Z", r.URL.Query().Get("period_from"))
+		w.Write([]byte(`{"results":[{"value_inc_vat":15.2,"valid_from":"2021-04-09T01:30:00Z","valid_to":"2021-04-09T02:00:00Z"},{"value_inc_vat":7.5,"valid_from":"2021-04-09T01:00:00Z","valid_to":"2021-04-09T01:30:00Z"}]}`))
+	}))
+	defer server.Close()
+	at := time.Date(2021, 4, 9, 1, 0, 0, 0, time.UTC)
+	periods, err := priceLookup(server.URL, at, at.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, []PricePeriod{
+		{From: at.Add(30 * time.Minute), To: at.Add(time.Hour), Price: 0.152},
+		{From: at, To: at.Add(30 * time.Minute), Price: 0.075},
+	}, periods)
+}
+
+func TestSplitPrices(t *testing.T) {
+	at := time.Date(2021, 4, 9, 1, 0, 0, 0, time.UTC)
+	periods := []PricePeriod{
+		{From: at, To: at.Add(30 * time.Minute), Price: 0.1},
+		{From: at.Add(30 * time.Minute), To: at.Add(time.Hour), Price: 0.2},
+	}
+	costs, ok := splitPrices([]energySample{{at, 0}, {at.Add(time.Hour), 6}}, periods)
+	assert.True(t, ok)
+	assert.InDelta(t, 6, costs[0].Energy, 0.01)
+	assert.InDelta(t, 0.9, costs[0].Cost, 0.01)
+	assert.Equal(t, "\n💷 6.0kWh ≈ £0.90", costMessage(Tariff{Currency: "£"}, costs))
+
+	_, ok = splitPrices([]energySample{{at, 0}, {at.Add(2 * time.Hour), 6}}, periods)
+	assert.False(t, ok)
+}
@@ -0,0 +1,19 @@
+package main
+
+// Geofences where drives are kept private, from PRIVATE_GEOFENCES.
+var privateGeofences = map[string]bool{}
+
+// PrivatePlace replaces the places of private drives.
+const PrivatePlace = "🔒 Private"
+
+// privateDrive reports whether a drive starts or ends at a private geofence.
+func privateDrive(start, end CarState) bool {
+	return privateGeofences[start.Geofence] || privateGeofences[end.Geofence]
+}
+
+// redactPlace hides where the car was.
+func redactPlace(s CarState) CarState {
+	s.Geofence = PrivatePlace
+	s.Latitude, s.Longitude = 0, 0
+	return s
+}
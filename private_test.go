@@ -0,0 +1,22 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivateDrive(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{"PRIVATE_GEOFENCES": "Clinic, Therapist"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Clinic", "Therapist"}, c.PrivateGeofences)
+
+	defer func(p map[string]bool) { privateGeofences = p }(privateGeofences)
+	privateGeofences = map[string]bool{"Clinic": true}
+	home, clinic := CarState{Geofence: "Home"}, CarState{Geofence: "Clinic", Latitude: 51.5, Longitude: -0.12}
+	assert.True(t, privateDrive(home, clinic))
+	assert.True(t, privateDrive(clinic, home))
+	assert.False(t, privateDrive(home, home))
+	assert.Equal(t, CarState{Geofence: PrivatePlace}, redactPlace(clinic))
+	assert.Equal(t, PrivatePlace, placeName(redactPlace(clinic)))
+}
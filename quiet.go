@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseQuietHours parses a range such as "23:00-07:00" into minutes since
+// midnight. The range may wrap past midnight.
+func parseQuietHours(value string) (start, end int, err error) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("quiet hours %q should be like 23:00-07:00", value)
+	}
+	if start, err = parseClock(parts[0]); err != nil {
+		return 0, 0, err
+	}
+	if end, err = parseClock(parts[1]); err != nil {
+		return 0, 0, err
+	}
+	return start, end, nil
+}
+
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inQuietHours reports whether t falls within the quiet hours, if any.
+func inQuietHours(value string, t time.Time) bool {
+	start, end, err := parseQuietHours(value)
+	if value == "" || err != nil {
+		return false
+	}
+	return minuteInRange(start, end, t.Hour()*60+t.Minute())
+}
+
+// minuteInRange reports whether a minute of the day falls from start until
+// end, which may wrap past midnight.
+func minuteInRange(start, end, minute int) bool {
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+func quietMode(settings *ChatSettings) string {
+	if settings.QuietQueue {
+		return "held until morning"
+	}
+	return "sent silently"
+}
+
+// quietCommand sets the chat's quiet hours, during which notifications are
+// sent without sound or held back until they end.
+func quietCommand(store *Store, chatID int64, args string) string {
+	settings, ok := store.Chats[chatID]
+	if !ok {
+		settings = &ChatSettings{}
+		store.Chats[chatID] = settings
+	}
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		if settings.QuietHours == "" {
+			return "No quiet hours. Set them with /quiet 23:00-07:00 [silent|queue]"
+		}
+		return fmt.Sprintf("🌙 Quiet hours %s, notifications %s", settings.QuietHours, quietMode(settings))
+	case len(fields) == 1 && fields[0] == "off":
+		settings.QuietHours = ""
+		return "🌙 Quiet hours turned off"
+	case len(fields) <= 2:
+		if _, _, err := parseQuietHours(fields[0]); err != nil {
+			return fmt.Sprintf("⚠️ %s", err)
+		}
+		queue := false
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "silent":
+			case "queue":
+				queue = true
+			default:
+				return "Usage: /quiet <start>-<end> [silent|queue] or /quiet off"
+			}
+		}
+		settings.QuietHours, settings.QuietQueue = fields[0], queue
+		return fmt.Sprintf("🌙 Quiet hours %s, notifications %s", settings.QuietHours, quietMode(settings))
+	}
+	return "Usage: /quiet <start>-<end> [silent|queue] or /quiet off"
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hour, minute int) time.Time { return time.Date(2021, 4, 9, hour, minute, 0, 0, time.UTC) }
+	assert.True(t, inQuietHours("23:00-07:00", at(23, 30)))
+	assert.True(t, inQuietHours("23:00-07:00", at(6, 59)))
+	assert.False(t, inQuietHours("23:00-07:00", at(7, 0)))
+	assert.True(t, inQuietHours("13:00-14:00", at(13, 15)))
+	assert.False(t, inQuietHours("13:00-14:00", at(23, 30)))
+	assert.False(t, inQuietHours("", at(23, 30)))
+}
+
+func TestQuietCommand(t *testing.T) {
+	store := &Store{}
+	store.init()
+	assert.Equal(t, "No quiet hours. Set them with /quiet 23:00-07:00 [silent|queue]", quietCommand(store, 1, ""))
+	assert.Equal(t, "🌙 Quiet hours 22:30-06:00, notifications held until morning", quietCommand(store, 1, "22:30-06:00 queue"))
+	assert.True(t, store.Chats[1].QuietQueue)
+	assert.Equal(t, "⚠️ invalid time \"25:00\"", quietCommand(store, 1, "25:00-06:00"))
+	assert.Equal(t, "🌙 Quiet hours turned off", quietCommand(store, 1, "off"))
+	assert.Equal(t, "", store.Chats[1].QuietHours)
+}
@@ -0,0 +1,23 @@
+package main
+
+import "fmt"
+
+// odometerMessage answers /odometer.
+func odometerMessage(car *Car) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	return fmt.Sprintf("🛣 %.0f %s", distance(car.State.Odometer), distanceUnit())
+}
+
+// rangeMessage answers /range with the battery level and each range
+// estimate TeslaMate publishes.
+func rangeMessage(car *Car) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	s := car.State
+	unit := distanceUnit()
+	return fmt.Sprintf("🔋 %d%%\nRated: %.0f %s\nEstimated: %.0f %s\nIdeal: %.0f %s",
+		s.BatteryLevel, distance(s.RatedBatteryRangeKm), unit, distance(s.EstBatteryRangeKm), unit, distance(s.IdealBatteryRangeKm), unit)
+}
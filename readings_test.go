@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadings(t *testing.T) {
+	car := &Car{}
+	car.State = CarState{Odometer: 19876, BatteryLevel: 61, RatedBatteryRangeKm: 335, EstBatteryRangeKm: 290, IdealBatteryRangeKm: 350}
+	assert.Equal(t, "🛣 12345 miles", odometerMessage(car))
+	assert.Equal(t, "🔋 61%\nRated: 208 miles\nEstimated: 180 miles\nIdeal: 217 miles", rangeMessage(car))
+	assert.Equal(t, "No car data received yet.", rangeMessage(nil))
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// receiptKeyboard offers a PDF receipt under a charge summary. The charge is
+// identified by its car and start time, which stay in the callback data.
+func receiptKeyboard(carID int, at time.Time) tgbotapi.InlineKeyboardMarkup {
+	data := fmt.Sprintf("receipt:%d:%d", carID, at.Unix())
+	return tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🧾 Receipt", data)))
+}
+
+// parseReceiptData parses the callback data from receiptKeyboard.
+func parseReceiptData(data string) (int, time.Time, bool) {
+	fields := strings.Split(data, ":")
+	if len(fields) != 3 || fields[0] != "receipt" {
+		return 0, time.Time{}, false
+	}
+	carID, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return 0, time.Time{}, false
+	}
+	return carID, time.Unix(unix, 0), true
+}
+
+// findCharge looks up a recorded charge by its start time.
+func findCharge(car *Car, at time.Time) (chargeRecord, bool) {
+	for _, c := range car.charges {
+		if c.at.Unix() == at.Unix() {
+			return c, true
+		}
+	}
+	return chargeRecord{}, false
+}
+
+// receiptLines are the details of a charge for an expense claim.
+func receiptLines(car *Car, c chargeRecord, now time.Time) []string {
+	start, end := c.at.In(location), c.until.In(location)
+	lines := []string{
+		"Car: " + car.displayName,
+		fmt.Sprintf("TeslaMate car ID: %d", car.id),
+		"Location: " + c.place,
+		"Start: " + start.Format("2006-01-02 ") + clock(start),
+		"End: " + end.Format("2006-01-02 ") + clock(end),
+		"Duration: " + formatDuration(c.until.Sub(c.at)),
+		fmt.Sprintf("Battery: %d%% to %d%%", c.startLevel, c.endLevel),
+		fmt.Sprintf("Energy added: %.2f kWh", c.energyAdded),
+	}
+	if c.cost > 0 && c.energyAdded > 0 {
+		lines = append(lines,
+			fmt.Sprintf("Tariff: %s%.3f/kWh average", tariff.Currency, c.cost/c.energyAdded),
+			"Cost: "+tariff.formatCost(c.cost))
+	} else {
+		lines = append(lines, "Cost: not priced")
+	}
+	return append(lines, "", "Generated "+now.In(location).Format("2006-01-02 15:04 MST"))
+}
+
+// receiptPDF lays out a charge receipt as a one page A4 PDF.
+func receiptPDF(car *Car, c chargeRecord, now time.Time) []byte {
+	return simplePDF("Charging receipt", receiptLines(car, c, now))
+}
+
+// simplePDF writes a title and lines of text on A4 pages in Helvetica,
+// starting new pages as needed. Only the WinAnsi character set is available,
+// anything else is replaced with "?".
+func simplePDF(title string, lines []string) []byte {
+	var pages []string
+	content := fmt.Sprintf("BT /F1 18 Tf 56 770 Td (%s) Tj ET\n", pdfString(title))
+	y := 730
+	for _, line := range lines {
+		if y < 50 {
+			pages = append(pages, content)
+			content, y = "", 770
+		}
+		content += fmt.Sprintf("BT /F1 11 Tf 56 %d Td (%s) Tj ET\n", y, pdfString(line))
+		y -= 18
+	}
+	pages = append(pages, content)
+
+	// catalog, page tree and font, then each page and its contents
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+2*i))
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+	}
+	for i, page := range pages {
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 595 842] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(page), page))
+	}
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+// pdfString encodes text for a PDF string literal in WinAnsiEncoding.
+func pdfString(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '€':
+			b.WriteString(`\200`)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, `\%03o`, r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
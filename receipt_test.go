@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReceiptData(t *testing.T) {
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	keyboard := receiptKeyboard(2, at)
+	data := *keyboard.InlineKeyboard[0][0].CallbackData
+	assert.Equal(t, "receipt:2:1617950340", data)
+	carID, parsed, ok := parseReceiptData(data)
+	assert.True(t, ok)
+	assert.Equal(t, 2, carID)
+	assert.True(t, at.Equal(parsed))
+	_, _, ok = parseReceiptData("settings:units")
+	assert.False(t, ok)
+}
+
+func TestReceiptLines(t *testing.T) {
+	defer func(t Tariff) { tariff = t }(tariff)
+	tariff = Tariff{Price: 0.25, Currency: "£"}
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car := &Car{id: 1, displayName: "Snowflake"}
+	car.recordCharge(chargeRecord{at: at, until: at.Add(90 * time.Minute), geofence: "Home", place: "Home", startLevel: 50, endLevel: 80, energyAdded: 8, cost: 2})
+	charge, ok := findCharge(car, at)
+	assert.True(t, ok)
+	_, ok = findCharge(car, at.Add(time.Minute))
+	assert.False(t, ok)
+	assert.Equal(t, []string{
+		"Car: Snowflake",
+		"TeslaMate car ID: 1",
+		"Location: Home",
+		"Start: 2021-04-09 06:39",
+		"End: 2021-04-09 08:09",
+		"Duration: 1h30m",
+		"Battery: 50% to 80%",
+		"Energy added: 8.00 kWh",
+		"Tariff: £0.250/kWh average",
+		"Cost: £2.00",
+		"",
+		"Generated 2021-04-09 09:00 UTC",
+	}, receiptLines(car, charge, at.Add(141*time.Minute)))
+}
+
+func TestSimplePDF(t *testing.T) {
+	pdf := simplePDF("Charging receipt", []string{"Cost: £2.00 (estimate)"})
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4\n")))
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+	assert.Contains(t, string(pdf), `(Cost: \2432.00 \(estimate\)) Tj`)
+	// the xref offsets point at each object
+	for i := 1; i <= 5; i++ {
+		assert.Contains(t, string(pdf), fmt.Sprintf("%010d 00000 n", bytes.Index(pdf, []byte(fmt.Sprintf("\n%d 0 obj", i)))+1))
+	}
+}
+
+func TestSimplePDFPages(t *testing.T) {
+	pdf := string(simplePDF("Statement", make([]string, 60)))
+	assert.Contains(t, pdf, "/Kids [4 0 R 6 0 R] /Count 2")
+	assert.Contains(t, pdf, "/Size 8 ")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseRecentArgs parses the optional count for /drives and /charges,
+// defaulting to 5.
+func parseRecentArgs(args string) (int, error) {
+	fields := strings.Fields(args)
+	switch len(fields) {
+	case 0:
+		return 5, nil
+	case 1:
+		n, err := parseCount(fields[0])
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid count %q", fields[0])
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("too many arguments")
+}
+
+// drivesMessage lists the car's last n drives, most recent first.
+func drivesMessage(car *Car, n int) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	if len(car.drives) == 0 {
+		return "No drives recorded yet."
+	}
+	drives := car.drives
+	if len(drives) > n {
+		drives = drives[len(drives)-n:]
+	}
+	text := "🚗 Recent drives"
+	kmPerKwh := car.kmPerKwh()
+	for i := len(drives) - 1; i >= 0; i-- {
+		d := drives[i]
+		at := d.at.In(location)
+		text += fmt.Sprintf("\n%s %s %s→%s %.1f %s, %s", at.Format("Mon"), clock(at), d.from, d.to,
+			distance(d.distanceKm), distanceUnit(), formatDuration(d.until.Sub(d.at)))
+		if d.distanceKm >= 1 {
+			text += fmt.Sprintf(", %.0f%s", driveEfficiency(d, kmPerKwh), efficiencyUnit())
+		}
+	}
+	return text
+}
+
+// chargesMessage lists the car's last n charges, most recent first.
+func chargesMessage(car *Car, n int) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	if len(car.charges) == 0 {
+		return "No charges recorded yet."
+	}
+	charges := car.charges
+	if len(charges) > n {
+		charges = charges[len(charges)-n:]
+	}
+	text := "🔌 Recent charges"
+	for i := len(charges) - 1; i >= 0; i-- {
+		c := charges[i]
+		at := c.at.In(location)
+		text += fmt.Sprintf("\n%s %s %s %d→%d%%, +%.1fkWh, %s", at.Format("Mon"), clock(at), c.place,
+			c.startLevel, c.endLevel, c.energyAdded, formatDuration(c.until.Sub(c.at)))
+		if c.cost > 0 {
+			text += ", ≈ " + tariff.formatCost(c.cost)
+		}
+	}
+	return text
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDrivesMessage(t *testing.T) {
+	car := &Car{}
+	assert.Equal(t, "No drives recorded yet.", drivesMessage(car, 5))
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car.recordDrive(CarState{At: at, Odometer: 976, RatedBatteryRangeKm: 400}, CarState{At: at.Add(8 * time.Minute), Odometer: 986, RatedBatteryRangeKm: 390}, "Home", "Work", NoPrecipitation)
+	car.recordDrive(CarState{At: at.Add(9 * time.Hour), Odometer: 986}, CarState{At: at.Add(9*time.Hour + 2*time.Minute), Odometer: 986.5}, "Work", "Shop", NoPrecipitation)
+	assert.Equal(t, "🚗 Recent drives\nFri 15:39 Work→Shop 0.3 miles, 2m\nFri 06:39 Home→Work 6.2 miles, 8m, 216Wh/mi", drivesMessage(car, 5))
+	assert.Equal(t, "🚗 Recent drives\nFri 15:39 Work→Shop 0.3 miles, 2m", drivesMessage(car, 1))
+}
+
+func TestParseRecentArgs(t *testing.T) {
+	n, err := parseRecentArgs("")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, n)
+	n, err = parseRecentArgs("10")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, n)
+	_, err = parseRecentArgs("0")
+	assert.Error(t, err)
+	_, err = parseRecentArgs("ten")
+	assert.Error(t, err)
+}
+
+func TestChargesMessage(t *testing.T) {
+	defer func(t Tariff) { tariff = t }(tariff)
+	tariff = Tariff{Price: 0.25, Currency: "£"}
+	car := &Car{}
+	assert.Equal(t, "No charges recorded yet.", chargesMessage(car, 5))
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car.recordCharge(chargeRecord{at: at, until: at.Add(90 * time.Minute), geofence: "Home", place: "Home", startLevel: 50, endLevel: 80, energyAdded: 8, cost: 2})
+	car.recordCharge(chargeRecord{at: at.Add(5 * time.Hour), until: at.Add(5*time.Hour + 25*time.Minute), place: "Cow Lane", startLevel: 40, endLevel: 75, energyAdded: 24.5})
+	assert.Equal(t, "🔌 Recent charges\nFri 11:39 Cow Lane 40→75%, +24.5kWh, 25m\nFri 06:39 Home 50→80%, +8.0kWh, 1h30m, ≈ £2.00", chargesMessage(car, 5))
+}
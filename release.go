@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Set at build time with -ldflags "-X main.version=v1.2.3".
+var version = "dev"
+
+const DefaultReleaseFeed = "https://api.github.com/repos/barnybug/teslamate-telegram/releases/latest"
+
+type Release struct {
+	TagName string `json:"tag_name"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+}
+
+func latestRelease(feed string) (*Release, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(feed)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("release feed returned %s", resp.Status)
+	}
+	var release Release
+	err = json.NewDecoder(resp.Body).Decode(&release)
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// checkRelease fetches the latest release in the background, so a slow feed
+// doesn't hold up the scheduler, and sends it on releases.
+func checkRelease(feed string, releases chan<- *Release) {
+	go func() {
+		release, err := latestRelease(feed)
+		if err != nil {
+			log.Println("Failed to check for releases:", err)
+			return
+		}
+		releases <- release
+	}()
+}
+
+func releaseMessage(release *Release) string {
+	text := fmt.Sprintf("🆕 teslamate-telegram %s is available (running %s)", release.TagName, version)
+	if excerpt := changelogExcerpt(release.Body, 5); excerpt != "" {
+		text += "\n" + excerpt
+	}
+	if release.HTMLURL != "" {
+		text += "\n" + release.HTMLURL
+	}
+	return text
+}
+
+func changelogExcerpt(body string, maxLines int) string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		if len(lines) == maxLines {
+			lines = append(lines, "…")
+			break
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangelogExcerpt(t *testing.T) {
+	assert.Equal(t, "- one\n- two", changelogExcerpt("- one\r\n\r\n- two\r\n", 5))
+	assert.Equal(t, "- one\n- two\n…", changelogExcerpt("- one\n- two\n- three", 2))
+}
+
+func TestReleaseMessage(t *testing.T) {
+	defer func(v string) { version = v }(version)
+	version = "v1.0.0"
+	release := &Release{TagName: "v1.1.0", Body: "- MQTT TLS support", HTMLURL: "https://github.com/barnybug/teslamate-telegram/releases/tag/v1.1.0"}
+	assert.Equal(t, "🆕 teslamate-telegram v1.1.0 is available (running v1.0.0)\n- MQTT TLS support\nhttps://github.com/barnybug/teslamate-telegram/releases/tag/v1.1.0", releaseMessage(release))
+}
+
+func TestReleaseConfig(t *testing.T) {
+	_, err := parseConfig(lookupMap(map[string]string{"RELEASE_CHECK_INTERVAL": "0s"}))
+	assert.EqualError(t, err, "invalid RELEASE_CHECK_INTERVAL: 0s is under a minute")
+}
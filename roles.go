@@ -0,0 +1,49 @@
+package main
+
+import "strings"
+
+// adminCommands change settings or control equipment, so only admins may
+// run them. Everyone else who is permitted can only query.
+var adminCommands = map[string]bool{
+	"allow":    true,
+	"debug":    true,
+	"evse":     true,
+	"language": true,
+	"pair":     true,
+	"quiet":    true,
+	"reload":   true,
+	"schedule": true,
+	"selftest": true,
+	"settings": true,
+	"watch":    true,
+	"wipe":     true,
+}
+
+// adminOnly reports whether a command may only be run by admins. Some are
+// open to everyone to query but change settings when given arguments, as
+// /car does.
+func adminOnly(command, args string) bool {
+	if command == "car" {
+		return strings.TrimSpace(args) != ""
+	}
+	return adminCommands[command]
+}
+
+// userRole returns a user's role as declared by TELEGRAM_ADMINS or
+// TELEGRAM_VIEWERS, else as granted by pairing, else the fallback.
+func userRole(config *Config, store *Store, userID int, fallback string) string {
+	for _, id := range config.Admins {
+		if id == int64(userID) {
+			return RoleAdmin
+		}
+	}
+	for _, id := range config.Viewers {
+		if id == int64(userID) {
+			return RoleViewer
+		}
+	}
+	if role, ok := store.Users[userID]; ok {
+		return role
+	}
+	return fallback
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUserRole(t *testing.T) {
+	store := &Store{}
+	store.init()
+	store.Users[3] = RoleAdmin
+	store.Users[4] = RoleViewer
+	config := &Config{Admins: []int64{1}, Viewers: []int64{2, 3}}
+	assert.Equal(t, RoleAdmin, userRole(config, store, 1, ""))
+	assert.Equal(t, RoleViewer, userRole(config, store, 2, RoleAdmin))
+	assert.Equal(t, RoleViewer, userRole(config, store, 3, ""), "config overrides pairing")
+	assert.Equal(t, RoleViewer, userRole(config, store, 4, RoleAdmin))
+	assert.Equal(t, RoleAdmin, userRole(config, store, 5, RoleAdmin))
+	assert.Equal(t, "", userRole(config, store, 5, ""))
+}
+
+func TestAdminOnly(t *testing.T) {
+	assert.True(t, adminOnly("wipe", ""))
+	assert.False(t, adminOnly("status", ""))
+	assert.False(t, adminOnly("cars", ""))
+	assert.False(t, adminOnly("car", " "))
+	assert.True(t, adminOnly("car", "2"))
+	assert.True(t, adminOnly("car", "set-emoji 🔴"))
+}
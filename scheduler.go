@@ -0,0 +1,95 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// Job is work the Scheduler runs on a cron schedule, or at an interval.
+type Job struct {
+	Name     string
+	Schedule *Cron
+	// Runs this long after the last run instead of on Schedule, and
+	// straight away if it has never run.
+	Every time.Duration
+	// How late a run missed while the bridge was down may still be made,
+	// once, when it's back. 0 drops missed runs.
+	CatchUp time.Duration
+	// Runs aren't kept in the store, for housekeeping every minute that
+	// would otherwise save it each time.
+	Untracked bool
+	Run       func(now time.Time)
+}
+
+// Scheduler runs jobs from the minute tick. The time of each job's last run
+// is kept in the store, so runs missed while the bridge was down can be
+// caught up.
+type Scheduler struct {
+	jobs    map[string]*Job
+	lastRun map[string]time.Time
+}
+
+func newScheduler(lastRun map[string]time.Time) *Scheduler {
+	return &Scheduler{jobs: map[string]*Job{}, lastRun: lastRun}
+}
+
+// Add schedules a job, replacing any of the same name.
+func (s *Scheduler) Add(job *Job) {
+	s.jobs[job.Name] = job
+}
+
+// Remove unschedules the jobs with a name prefix, e.g. "status:".
+func (s *Scheduler) Remove(prefix string) {
+	for name := range s.jobs {
+		if strings.HasPrefix(name, prefix) {
+			delete(s.jobs, name)
+		}
+	}
+}
+
+// due reports whether a job should run now: it's scheduled for this minute,
+// or a run since its last was missed within its catch-up window, or its
+// interval has passed.
+func (s *Scheduler) due(job *Job, now time.Time) bool {
+	last, ok := s.lastRun[job.Name]
+	if job.Every > 0 {
+		return !ok || now.Sub(last) >= job.Every
+	}
+	if job.Schedule.Matches(now) {
+		return true
+	}
+	if !ok {
+		return false
+	}
+	for t := now.Add(-time.Minute); t.After(last) && now.Sub(t) <= job.CatchUp; t = t.Add(-time.Minute) {
+		if job.Schedule.Matches(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// Tick runs the jobs due at now, in name order, and reports whether any
+// tracked job ran so their run times can be saved.
+func (s *Scheduler) Tick(now time.Time) bool {
+	now = now.Truncate(time.Minute)
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	ran := false
+	for _, name := range names {
+		job := s.jobs[name]
+		if !s.due(job, now) {
+			continue
+		}
+		job.Run(now)
+		if !job.Untracked {
+			s.lastRun[name] = now
+			ran = true
+		}
+	}
+	return ran
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScheduler(t *testing.T) {
+	lastRun := map[string]time.Time{}
+	s := newScheduler(lastRun)
+	var runs []string
+	add := func(name, expr string, catchUp time.Duration) {
+		c, err := parseCron(expr)
+		assert.NoError(t, err)
+		s.Add(&Job{Name: name, Schedule: c, CatchUp: catchUp, Run: func(now time.Time) {
+			runs = append(runs, name+" "+now.Format("15:04"))
+		}})
+	}
+	add("digest", "0 20 * * *", 2*time.Hour)
+	add("status:1", "0 8 * * *", 0)
+
+	at := time.Date(2021, 4, 9, 8, 0, 30, 0, time.UTC)
+	assert.True(t, s.Tick(at))
+	assert.False(t, s.Tick(at.Add(time.Minute)))
+	assert.True(t, s.Tick(at.Add(12*time.Hour)))
+	assert.Equal(t, []string{"status:1 08:00", "digest 20:00"}, runs)
+	assert.Equal(t, time.Date(2021, 4, 9, 20, 0, 0, 0, time.UTC), lastRun["digest"])
+
+	// down over the next day's runs: digest is caught up once, status isn't
+	runs = nil
+	back := time.Date(2021, 4, 10, 21, 30, 0, 0, time.UTC)
+	assert.True(t, s.Tick(back))
+	assert.False(t, s.Tick(back.Add(time.Minute)))
+	assert.Equal(t, []string{"digest 21:30"}, runs)
+
+	// too late to catch up
+	runs = nil
+	assert.False(t, s.Tick(time.Date(2021, 4, 11, 23, 0, 0, 0, time.UTC)))
+	assert.Empty(t, runs)
+
+	s.Remove("status:")
+	assert.False(t, s.Tick(time.Date(2021, 4, 12, 8, 0, 0, 0, time.UTC)))
+}
+
+func TestSchedulerEvery(t *testing.T) {
+	lastRun := map[string]time.Time{}
+	s := newScheduler(lastRun)
+	var runs []string
+	s.Add(&Job{Name: "release", Every: 24 * time.Hour, Run: func(now time.Time) {
+		runs = append(runs, "release "+now.Format("02 15:04"))
+	}})
+	everyMinute, err := parseCron("* * * * *")
+	assert.NoError(t, err)
+	flushes := 0
+	s.Add(&Job{Name: "digest", Schedule: everyMinute, Untracked: true, Run: func(now time.Time) { flushes++ }})
+
+	at := time.Date(2021, 4, 9, 8, 0, 0, 0, time.UTC)
+	assert.True(t, s.Tick(at))
+	assert.False(t, s.Tick(at.Add(time.Minute)))
+	assert.False(t, s.Tick(at.Add(23*time.Hour)))
+	assert.True(t, s.Tick(at.Add(24*time.Hour)))
+	assert.Equal(t, []string{"release 09 08:00", "release 10 08:00"}, runs)
+	assert.Equal(t, 4, flushes)
+	assert.NotContains(t, lastRun, "digest")
+}
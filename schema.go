@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// SchemaVersion is the version of the event JSON passed to HOOK_COMMAND.
+// Within a version fields are only ever added, so consumers should ignore
+// fields they don't know. Removing a field, renaming it or changing its
+// meaning or type bumps the version.
+const SchemaVersion = 1
+
+// hookPayload is an event as sent to HOOK_COMMAND.
+type hookPayload struct {
+	SchemaVersion int `json:"schema_version"`
+	Event
+}
+
+// eventSchema is the JSON Schema of hookPayload, served on /api/schema.
+const eventSchema = `{
+  "$schema": "http://json-schema.org/draft-07/schema#",
+  "title": "teslamate-telegram event",
+  "description": "An event passed on stdin to HOOK_COMMAND. Fields are only added within a schema_version; ignore unknown fields.",
+  "type": "object",
+  "required": ["schema_version", "type", "car_id", "car", "at"],
+  "properties": {
+    "schema_version": {"const": 1},
+    "type": {
+      "type": "string",
+      "description": "charge_started, charge_finished, drive_started, drive_finished, plugged_in, recalibrated, towed, grid_import, vent_*, hook_reply or release. New types may be added."
+    },
+    "car_id": {"type": "integer", "description": "TeslaMate car ID, 0 if not about a car"},
+    "car": {"type": "string", "description": "Car display name"},
+    "at": {"type": "string", "format": "date-time"},
+    "start": {"$ref": "#/definitions/state", "description": "State at the start of the session"},
+    "end": {"$ref": "#/definitions/state", "description": "State at the end of the session"},
+    "message": {"type": "string", "description": "Notification text, if one was sent"}
+  },
+  "definitions": {
+    "state": {
+      "type": "object",
+      "properties": {
+        "at": {"type": "string", "format": "date-time"},
+        "geofence": {"type": "string"},
+        "charger_power": {"type": "integer", "description": "kW"},
+        "charger_voltage": {"type": "integer", "description": "V"},
+        "time_to_full_charge": {"type": "number", "description": "hours"},
+        "charger_actual_current": {"type": "integer", "description": "A"},
+        "charge_energy_added": {"type": "number", "description": "kWh"},
+        "est_battery_range_km": {"type": "number"},
+        "rated_battery_range_km": {"type": "number"},
+        "ideal_battery_range_km": {"type": "number"},
+        "battery_level": {"type": "integer", "description": "%"},
+        "charge_limit_soc": {"type": "integer", "description": "%"},
+        "charging_state": {"type": "string"},
+        "scheduled_charging_start_time": {"type": "string", "format": "date-time", "description": "zero time if none is scheduled"},
+        "shift_state": {"type": "string"},
+        "speed": {"type": "integer", "description": "km/h"},
+        "odometer": {"type": "number", "description": "km"},
+        "outside_temp": {"type": "number", "description": "°C"},
+        "inside_temp": {"type": "number", "description": "°C"},
+        "is_climate_on": {"type": "boolean"},
+        "is_preconditioning": {"type": "boolean"},
+        "driver_temp_setting": {"type": "number", "description": "°C, 0 if unknown"},
+        "plugged_in": {"type": "boolean"},
+        "latitude": {"type": "number", "description": "0 when hidden by PRIVACY_MODE"},
+        "longitude": {"type": "number", "description": "0 when hidden by PRIVACY_MODE"}
+      }
+    }
+  }
+}
+`
+
+// serveSchema serves the event JSON Schema.
+func serveSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write([]byte(eventSchema))
+}
+
+func marshalEvent(event Event) ([]byte, error) {
+	return json.Marshal(hookPayload{SchemaVersion, event})
+}
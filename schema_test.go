@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSchema(t *testing.T) {
+	var schema struct {
+		Properties  map[string]interface{}
+		Definitions struct {
+			State struct {
+				Properties map[string]interface{}
+			}
+		}
+	}
+	assert.NoError(t, json.Unmarshal([]byte(eventSchema), &schema))
+	assert.Equal(t, map[string]interface{}{"const": float64(SchemaVersion)}, schema.Properties["schema_version"])
+	// every field a consumer can receive is documented
+	state := reflect.TypeOf(CarState{})
+	for i := 0; i < state.NumField(); i++ {
+		tag := strings.Split(state.Field(i).Tag.Get("json"), ",")[0]
+		assert.Contains(t, schema.Definitions.State.Properties, tag)
+	}
+	event := reflect.TypeOf(Event{})
+	for i := 0; i < event.NumField(); i++ {
+		tag := strings.Split(event.Field(i).Tag.Get("json"), ",")[0]
+		assert.Contains(t, schema.Properties, tag)
+	}
+}
+
+func TestMarshalEvent(t *testing.T) {
+	payload, err := marshalEvent(Event{Type: "drive_finished", Car: "Snowflake"})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(payload), `{"schema_version":1,"type":"drive_finished",`))
+}
+
+func TestServeSchema(t *testing.T) {
+	w := httptest.NewRecorder()
+	serveSchema(w, httptest.NewRequest("GET", "/api/schema", nil))
+	assert.Equal(t, "application/schema+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, eventSchema, w.Body.String())
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// selfCheck is a line of the /selftest checklist. A check that doesn't
+// apply has a reason it was skipped rather than a result.
+type selfCheck struct {
+	name    string
+	err     error
+	skipped string
+}
+
+func selftestMessage(checks []selfCheck) string {
+	text := "🩺 Self test"
+	for _, c := range checks {
+		switch {
+		case c.skipped != "":
+			text += fmt.Sprintf("\n⏭ %s: %s", c.name, c.skipped)
+		case c.err != nil:
+			text += fmt.Sprintf("\n❌ %s: %s", c.name, c.err)
+		default:
+			text += "\n✅ " + c.name
+		}
+	}
+	return text
+}
+
+// configuredChats returns every chat notifications can be sent to, once
+// each.
+func configuredChats(config *Config, notifyChats []int64) []int64 {
+	seen := map[int64]bool{}
+	add := func(chats []int64) {
+		for _, id := range chats {
+			seen[id] = true
+		}
+	}
+	add(notifyChats)
+	for _, chats := range config.Routes {
+		add(chats)
+	}
+	for _, chats := range config.CarRoutes {
+		add(chats)
+	}
+	chats := make([]int64, 0, len(seen))
+	for id := range seen {
+		chats = append(chats, id)
+	}
+	sort.Slice(chats, func(i, j int) bool { return chats[i] < chats[j] })
+	return chats
+}
+
+// checkMQTT reports whether the broker connection is up.
+func checkMQTT(connected bool) selfCheck {
+	c := selfCheck{name: "MQTT"}
+	if !connected {
+		c.err = errors.New("not connected")
+	}
+	return c
+}
+
+// checkChat shows a typing indicator in a chat, which fails as a message
+// would if the bot can't post there.
+func checkChat(bot *tgbotapi.BotAPI, chatID int64) selfCheck {
+	_, err := bot.MakeRequest("sendChatAction", url.Values{
+		"chat_id": {strconv.FormatInt(chatID, 10)},
+		"action":  {tgbotapi.ChatTyping},
+	})
+	return selfCheck{name: fmt.Sprintf("Telegram chat %d", chatID), err: err}
+}
+
+// checkGeocoder looks up a known position, unless geocoding is off.
+func checkGeocoder() selfCheck {
+	c := selfCheck{name: "Geocoder"}
+	switch {
+	case privacyMode != PrivacyOff:
+		c.skipped = "off with PRIVACY_MODE=" + privacyMode
+	case !features.Enabled("geocoding"):
+		c.skipped = "off in /settings"
+	default:
+		_, c.err = nominatimLookup(51.5, -0.125)
+	}
+	return c
+}
+
+// checkStorage writes the state file.
+func checkStorage(store *Store) selfCheck {
+	return selfCheck{name: "Storage", err: store.Save()}
+}
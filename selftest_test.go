@@ -0,0 +1,31 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelftestMessage(t *testing.T) {
+	checks := []selfCheck{checkMQTT(true), checkMQTT(false), {name: "Geocoder", skipped: "off in /settings"}, {name: "Storage", err: errors.New("read-only file system")}}
+	assert.Equal(t, "🩺 Self test\n✅ MQTT\n❌ MQTT: not connected\n⏭ Geocoder: off in /settings\n❌ Storage: read-only file system", selftestMessage(checks))
+}
+
+func TestConfiguredChats(t *testing.T) {
+	config := &Config{Routes: map[string][]int64{"alert": {3, 1}}, CarRoutes: map[int][]int64{1: {2}}}
+	assert.Equal(t, []int64{1, 2, 3}, configuredChats(config, []int64{1}))
+}
+
+func TestCheckStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "selftest")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store := &Store{path: filepath.Join(dir, "state.json")}
+	assert.NoError(t, checkStorage(store).err)
+	store.path = filepath.Join(dir, "missing", "state.json")
+	assert.Error(t, checkStorage(store).err)
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Order in which the notifications button cycles.
+var notifyCycle = []string{"all", "charging", "driving", "none"}
+
+func unitsName(units string) string {
+	if units == "metric" {
+		return "kilometres"
+	}
+	return "miles"
+}
+
+// Quiet hours the menu button switches on. Others can be set with /quiet.
+const defaultQuietHours = "23:00-07:00"
+
+func quietName(settings *ChatSettings) string {
+	if settings.QuietHours == "" {
+		return "off"
+	}
+	return settings.QuietHours + ", " + quietMode(settings)
+}
+
+// Thresholds the menu buttons step through, after the MIN_* default and
+// before going back to it. 0 is off.
+var (
+	minDriveDistanceSteps = []float32{0, 1, 5, 10} // in the chat's units
+	minDriveMinuteSteps   = []int{0, 2, 5, 10}
+	minChargeEnergySteps  = []float32{0, 1, 2, 5} // kWh
+)
+
+// nextStep returns the step after current, or nil for the default after
+// the last.
+func nextStep(current *float32, steps []float32) *float32 {
+	i := 0
+	if current != nil {
+		for i < len(steps) && steps[i] <= *current {
+			i++
+		}
+	}
+	if i == len(steps) {
+		return nil
+	}
+	step := steps[i]
+	return &step
+}
+
+func nextMinuteStep(current *int, steps []int) *int {
+	i := 0
+	if current != nil {
+		for i < len(steps) && steps[i] <= *current {
+			i++
+		}
+	}
+	if i == len(steps) {
+		return nil
+	}
+	step := steps[i]
+	return &step
+}
+
+func minDriveDistanceName(settings *ChatSettings) string {
+	switch d := settings.MinDriveDistance; {
+	case d == nil:
+		return "default"
+	case *d == 0:
+		return "off"
+	default:
+		return fmt.Sprintf("%.0f %s", *d, distanceUnit())
+	}
+}
+
+func minDriveDurationName(settings *ChatSettings) string {
+	switch m := settings.MinDriveMinutes; {
+	case m == nil:
+		return "default"
+	case *m == 0:
+		return "off"
+	default:
+		return formatDuration(time.Duration(*m) * time.Minute)
+	}
+}
+
+func minChargeEnergyName(settings *ChatSettings) string {
+	switch e := settings.MinChargeEnergy; {
+	case e == nil:
+		return "default"
+	case *e == 0:
+		return "off"
+	default:
+		return fmt.Sprintf("%.0f kWh", *e)
+	}
+}
+
+// settingsMenu shows a chat's preferences and the feature toggles, with an
+// inline button to change each.
+func settingsMenu(settings *ChatSettings, f *Features) (string, tgbotapi.InlineKeyboardMarkup) {
+	notify := settings.Notify
+	if notify == "" {
+		notify = "all"
+	}
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🔔 Notifications: "+notify, "settings:notify")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📏 Units: "+unitsName(settings.Units), "settings:units")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🌙 Quiet hours: "+quietName(settings), "settings:quiet")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("🚗 Skip drives under: "+minDriveDistanceName(settings), "settings:min_distance")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⏱ Skip drives shorter than: "+minDriveDurationName(settings), "settings:min_duration")),
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("⚡ Skip charges under: "+minChargeEnergyName(settings), "settings:min_energy")),
+	}
+	names := make([]string, 0, len(featureDescriptions))
+	for name := range featureDescriptions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		mark := "❌"
+		if f.Enabled(name) {
+			mark = "✅"
+		}
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%s %s: %s", mark, name, featureDescriptions[name]), "settings:feature:"+name)))
+	}
+	return "⚙️ Settings\nTap to change.", tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// settingsCallback applies a tap on the settings menu and returns the
+// confirmation to show.
+func settingsCallback(settings *ChatSettings, f *Features, data string) string {
+	switch {
+	case data == "settings:notify":
+		current, next := settings.Notify, notifyCycle[0]
+		if current == "" {
+			current = "all"
+		}
+		for i, notify := range notifyCycle {
+			if notify == current {
+				next = notifyCycle[(i+1)%len(notifyCycle)]
+			}
+		}
+		settings.Notify = next
+		return "Notifications: " + next
+	case data == "settings:units":
+		if settings.Units == "metric" {
+			settings.Units = "imperial"
+		} else {
+			settings.Units = "metric"
+		}
+		return "Units: " + unitsName(settings.Units)
+	case data == "settings:quiet":
+		// off, then silent, then held, then off again
+		switch {
+		case settings.QuietHours == "":
+			settings.QuietHours, settings.QuietQueue = defaultQuietHours, false
+		case !settings.QuietQueue:
+			settings.QuietQueue = true
+		default:
+			settings.QuietHours, settings.QuietQueue = "", false
+		}
+		return "Quiet hours: " + quietName(settings)
+	case data == "settings:min_distance":
+		settings.MinDriveDistance = nextStep(settings.MinDriveDistance, minDriveDistanceSteps)
+		return "Skip drives under: " + minDriveDistanceName(settings)
+	case data == "settings:min_duration":
+		settings.MinDriveMinutes = nextMinuteStep(settings.MinDriveMinutes, minDriveMinuteSteps)
+		return "Skip drives shorter than: " + minDriveDurationName(settings)
+	case data == "settings:min_energy":
+		settings.MinChargeEnergy = nextStep(settings.MinChargeEnergy, minChargeEnergySteps)
+		return "Skip charges under: " + minChargeEnergyName(settings)
+	case strings.HasPrefix(data, "settings:feature:"):
+		name := strings.TrimPrefix(data, "settings:feature:")
+		on := !f.Enabled(name)
+		if err := f.Set(name, on); err != nil {
+			return err.Error()
+		}
+		if on {
+			return name + " on"
+		}
+		return name + " off"
+	}
+	return "Unknown setting"
+}
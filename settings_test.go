@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingsMenu(t *testing.T) {
+	f := &Features{overrides: map[string]bool{}}
+	f.configure(&Config{Geocoding: true})
+	settings := &ChatSettings{}
+	text, keyboard := settingsMenu(settings, f)
+	assert.Equal(t, "⚙️ Settings\nTap to change.", text)
+	assert.Len(t, keyboard.InlineKeyboard, 6+len(featureDescriptions))
+	assert.Equal(t, "🔔 Notifications: all", keyboard.InlineKeyboard[0][0].Text)
+	assert.Equal(t, "settings:units", *keyboard.InlineKeyboard[1][0].CallbackData)
+	assert.Equal(t, "✅ geocoding: Reverse geocode place names", keyboard.InlineKeyboard[10][0].Text)
+	assert.Equal(t, "🚗 Skip drives under: default", keyboard.InlineKeyboard[3][0].Text)
+}
+
+func TestSettingsCallback(t *testing.T) {
+	f := &Features{overrides: map[string]bool{}}
+	f.configure(&Config{Geocoding: true})
+	settings := &ChatSettings{}
+	assert.Equal(t, "Notifications: charging", settingsCallback(settings, f, "settings:notify"))
+	settingsCallback(settings, f, "settings:notify")
+	settingsCallback(settings, f, "settings:notify")
+	assert.Equal(t, "Notifications: all", settingsCallback(settings, f, "settings:notify"))
+	assert.Equal(t, "Units: kilometres", settingsCallback(settings, f, "settings:units"))
+	assert.Equal(t, "metric", settings.Units)
+	assert.Equal(t, "Quiet hours: 23:00-07:00, sent silently", settingsCallback(settings, f, "settings:quiet"))
+	assert.Equal(t, "Quiet hours: 23:00-07:00, held until morning", settingsCallback(settings, f, "settings:quiet"))
+	assert.Equal(t, "Quiet hours: off", settingsCallback(settings, f, "settings:quiet"))
+	assert.Equal(t, "Skip drives under: off", settingsCallback(settings, f, "settings:min_distance"))
+	assert.Equal(t, "Skip drives under: 1 miles", settingsCallback(settings, f, "settings:min_distance"))
+	settingsCallback(settings, f, "settings:min_distance")
+	assert.Equal(t, "Skip drives under: 10 miles", settingsCallback(settings, f, "settings:min_distance"))
+	assert.Equal(t, "Skip drives under: default", settingsCallback(settings, f, "settings:min_distance"))
+	assert.Nil(t, settings.MinDriveDistance)
+	settingsCallback(settings, f, "settings:min_duration")
+	assert.Equal(t, "Skip drives shorter than: 2m", settingsCallback(settings, f, "settings:min_duration"))
+	assert.Equal(t, 2, *settings.MinDriveMinutes)
+	settingsCallback(settings, f, "settings:min_energy")
+	assert.Equal(t, "Skip charges under: 1 kWh", settingsCallback(settings, f, "settings:min_energy"))
+	assert.Equal(t, "geocoding off", settingsCallback(settings, f, "settings:feature:geocoding"))
+	assert.False(t, f.Enabled("geocoding"))
+	assert.Equal(t, "unknown feature charts", settingsCallback(settings, f, "settings:feature:charts"))
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tariffs are the alternatives /simulate can compare against, from TARIFFS.
+var tariffs map[string]Tariff
+
+// simulateMessage answers /simulate tariff <name>, costing the last month of
+// Home charges on another tariff. Each charge's energy is spread evenly over
+// it, as the charging samples aren't kept.
+func simulateMessage(car *Car, args string, now time.Time) string {
+	names := make([]string, 0, len(tariffs))
+	for name := range tariffs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return "No other tariffs configured. Add them with TARIFFS."
+	}
+	fields := strings.Fields(args)
+	alt, ok := Tariff{}, false
+	if len(fields) == 2 && fields[0] == "tariff" {
+		alt, ok = tariffs[fields[1]]
+	}
+	if !ok {
+		return "Usage: /simulate tariff " + strings.Join(names, "|")
+	}
+	if car == nil {
+		return "No car data received yet."
+	}
+	since := now.Add(-placesPeriods["month"])
+	var charges int
+	var energy, current, simulated float32
+	for _, c := range car.charges {
+		if c.geofence != "Home" || c.at.Before(since) {
+			continue
+		}
+		charges++
+		energy += c.energyAdded
+		current += c.cost
+		simulated += totalCost(alt.Split(c.at, c.until, c.energyAdded))
+	}
+	if charges == 0 {
+		return "No charges at Home in the last month."
+	}
+	text := fmt.Sprintf("💷 Last month at Home: %s, %.1fkWh\nCurrent: ≈ %s\n%s: ≈ %s",
+		plural(charges, "charge"), energy, tariff.formatCost(current), fields[1], tariff.formatCost(simulated))
+	switch diff := simulated - current; {
+	case diff < -0.005:
+		text += ", " + tariff.formatCost(-diff) + " less"
+	case diff > 0.005:
+		text += ", " + tariff.formatCost(diff) + " more"
+	default:
+		text += ", the same"
+	}
+	return text
+}
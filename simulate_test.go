@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimulateMessage(t *testing.T) {
+	defer func(t Tariff, alts map[string]Tariff) { tariff, tariffs = t, alts }(tariff, tariffs)
+	tariff = Tariff{Price: 0.25, Currency: "£"}
+	assert.Equal(t, "No other tariffs configured. Add them with TARIFFS.", simulateMessage(nil, "tariff go", time.Now()))
+
+	bands, err := parseTariffBands("off-peak=00:30-04:30@0.075")
+	assert.NoError(t, err)
+	tariffs = map[string]Tariff{"go": {Price: 0.3, Currency: "£", Bands: bands}, "flat": {Price: 0.25, Currency: "£"}}
+	assert.Equal(t, "Usage: /simulate tariff flat|go", simulateMessage(nil, "tariff agile", time.Now()))
+
+	now := time.Date(2021, 4, 9, 12, 0, 0, 0, time.UTC)
+	car := &Car{}
+	assert.Equal(t, "No charges at Home in the last month.", simulateMessage(car, "tariff go", now))
+	night := time.Date(2021, 4, 8, 1, 0, 0, 0, time.UTC)
+	car.recordCharge(chargeRecord{at: night, until: night.Add(2 * time.Hour), geofence: "Home", energyAdded: 14, cost: 3.5})
+	car.recordCharge(chargeRecord{at: night.AddDate(0, -2, 0), until: night.AddDate(0, -2, 0).Add(time.Hour), geofence: "Home", energyAdded: 7, cost: 1.75})
+	car.recordCharge(chargeRecord{at: night, until: night.Add(time.Hour), geofence: "Work", energyAdded: 7})
+	assert.Equal(t, "💷 Last month at Home: 1 charge, 14.0kWh\nCurrent: ≈ £3.50\ngo: ≈ £1.05, £2.45 less", simulateMessage(car, "tariff go", now))
+	assert.Equal(t, "💷 Last month at Home: 1 charge, 14.0kWh\nCurrent: ≈ £3.50\nflat: ≈ £3.50, the same", simulateMessage(car, "tariff flat", now))
+}
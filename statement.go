@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseStatementArgs parses /statement's optional month, e.g. 2021-04, and
+// format. The month defaults to the last full one and the format to pdf.
+func parseStatementArgs(args string, now time.Time) (time.Time, string, error) {
+	now = now.In(location)
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location).AddDate(0, -1, 0)
+	format := "pdf"
+	for _, field := range strings.Fields(args) {
+		switch field {
+		case "pdf", "csv":
+			format = field
+			continue
+		}
+		t, err := time.ParseInLocation("2006-01", field, location)
+		if err != nil {
+			return time.Time{}, "", fmt.Errorf("expected a month like 2021-04, got %q", field)
+		}
+		month = t
+	}
+	return month, format, nil
+}
+
+// statementCharges are the car's charges at Home starting in the month.
+func statementCharges(car *Car, month time.Time) []chargeRecord {
+	var charges []chargeRecord
+	next := month.AddDate(0, 1, 0)
+	for _, c := range car.charges {
+		if c.geofence == "Home" && !c.at.Before(month) && c.at.Before(next) {
+			charges = append(charges, c)
+		}
+	}
+	return charges
+}
+
+// statementCSV lists a month's home charges for reimbursement, one row per
+// charge and a total at the end.
+func statementCSV(car *Car, month time.Time) ([]byte, int) {
+	charges := statementCharges(car, month)
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"car", "start", "end", "kwh_added", "cost"})
+	var energy, cost float32
+	for _, c := range charges {
+		w.Write([]string{car.displayName, c.at.In(location).Format(time.RFC3339), c.until.In(location).Format(time.RFC3339),
+			formatFloat(c.energyAdded, 2), formatFloat(c.cost, 2)})
+		energy += c.energyAdded
+		cost += c.cost
+	}
+	w.Write([]string{car.displayName, "total", "", formatFloat(energy, 2), formatFloat(cost, 2)})
+	w.Flush()
+	return buf.Bytes(), len(charges)
+}
+
+// statementLines set out a month's home charges and their total for an
+// employer's reimbursement claim.
+func statementLines(car *Car, month time.Time, now time.Time) []string {
+	charges := statementCharges(car, month)
+	lines := []string{
+		"Car: " + car.displayName,
+		fmt.Sprintf("TeslaMate car ID: %d", car.id),
+		"Period: " + month.Format("January 2006"),
+		"",
+	}
+	var energy, cost float32
+	for _, c := range charges {
+		at := c.at.In(location)
+		lines = append(lines, fmt.Sprintf("%s %s   %.2f kWh   %s", at.Format("2006-01-02"), clock(at), c.energyAdded, tariff.formatCost(c.cost)))
+		energy += c.energyAdded
+		cost += c.cost
+	}
+	lines = append(lines, "",
+		fmt.Sprintf("Total: %s, %.2f kWh, %s", plural(len(charges), "charge"), energy, tariff.formatCost(cost)))
+	if energy > 0 && cost > 0 {
+		lines = append(lines, fmt.Sprintf("Average rate: %s%.3f/kWh", tariff.Currency, cost/energy))
+	}
+	return append(lines, "", "Generated "+now.In(location).Format("2006-01-02 15:04 MST"))
+}
+
+// statementPDF lays out statementLines as a PDF.
+func statementPDF(car *Car, month time.Time, now time.Time) []byte {
+	return simplePDF("Home charging statement", statementLines(car, month, now))
+}
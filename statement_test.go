@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStatementArgs(t *testing.T) {
+	now := time.Date(2021, 5, 3, 12, 0, 0, 0, time.UTC)
+	month, format, err := parseStatementArgs("", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC), month)
+	assert.Equal(t, "pdf", format)
+	month, format, err = parseStatementArgs("2021-01 csv", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), month)
+	assert.Equal(t, "csv", format)
+	_, _, err = parseStatementArgs("april", now)
+	assert.EqualError(t, err, `expected a month like 2021-04, got "april"`)
+}
+
+func TestStatement(t *testing.T) {
+	defer func(t Tariff) { tariff = t }(tariff)
+	tariff = Tariff{Price: 0.25, Currency: "£"}
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	car := &Car{id: 1, displayName: "Snowflake"}
+	car.recordCharge(chargeRecord{at: at.AddDate(0, -1, 0), until: at.AddDate(0, -1, 0).Add(time.Hour), geofence: "Home", energyAdded: 5, cost: 1.25})
+	car.recordCharge(chargeRecord{at: at, until: at.Add(90 * time.Minute), geofence: "Home", energyAdded: 8, cost: 2})
+	car.recordCharge(chargeRecord{at: at.Add(5 * time.Hour), until: at.Add(6 * time.Hour), geofence: "", place: "Cow Lane", energyAdded: 24.5})
+	car.recordCharge(chargeRecord{at: at.AddDate(0, 0, 2), until: at.AddDate(0, 0, 2).Add(time.Hour), geofence: "Home", energyAdded: 4, cost: 1})
+	month := time.Date(2021, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	data, rows := statementCSV(car, month)
+	assert.Equal(t, 2, rows)
+	assert.Equal(t, "car,start,end,kwh_added,cost\n"+
+		"Snowflake,2021-04-09T06:39:00Z,2021-04-09T08:09:00Z,8.00,2.00\n"+
+		"Snowflake,2021-04-11T06:39:00Z,2021-04-11T07:39:00Z,4.00,1.00\n"+
+		"Snowflake,total,,12.00,3.00\n", string(data))
+
+	assert.Equal(t, []string{
+		"Car: Snowflake",
+		"TeslaMate car ID: 1",
+		"Period: April 2021",
+		"",
+		"2021-04-09 06:39   8.00 kWh   £2.00",
+		"2021-04-11 06:39   4.00 kWh   £1.00",
+		"",
+		"Total: 2 charges, 12.00 kWh, £3.00",
+		"Average rate: £0.250/kWh",
+		"",
+		"Generated 2021-05-03 12:00 UTC",
+	}, statementLines(car, month, time.Date(2021, 5, 3, 12, 0, 0, 0, time.UTC)))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// statsMessage answers /stats, totalling the drives and charges recorded
+// over the last week or month.
+func statsMessage(car *Car, period string, now time.Time) string {
+	if period == "" {
+		period = "week"
+	}
+	if period != "week" && period != "month" {
+		return "Usage: /stats [week|month]"
+	}
+	if car == nil {
+		return "No car data received yet."
+	}
+	since := now.Add(-placesPeriods[period])
+	var drives, charges int
+	var distanceKm, ratedKmUsed, energy, cost float32
+	for _, d := range car.drives {
+		if !d.at.Before(since) {
+			drives++
+			distanceKm += d.distanceKm
+			ratedKmUsed += d.ratedKmUsed
+		}
+	}
+	for _, c := range car.charges {
+		if !c.at.Before(since) {
+			charges++
+			energy += c.energyAdded
+			cost += c.cost
+		}
+	}
+	if drives == 0 && charges == 0 {
+		return "Nothing recorded in the last " + period + "."
+	}
+	lines := []string{
+		"📊 Last " + period,
+		fmt.Sprintf("🚗 %s, %.1f %s", plural(drives, "drive"), distance(distanceKm), distanceUnit()),
+	}
+	if distanceKm >= 1 {
+		lines = append(lines, fmt.Sprintf("Efficiency: %.0f%s", ratedKmUsed/car.kmPerKwh()*1000/distance(distanceKm), efficiencyUnit()))
+	}
+	lines = append(lines, fmt.Sprintf("🔌 %s, %.1fkWh", plural(charges, "charge"), energy))
+	if cost > 0 {
+		lines = append(lines, "Charging cost: ≈ "+tariff.formatCost(cost))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// plural counts n of a thing, e.g. "1 drive" or "2 drives".
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
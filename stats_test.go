@@ -0,0 +1,24 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatsMessage(t *testing.T) {
+	defer func(t Tariff) { tariff = t }(tariff)
+	tariff = Tariff{Price: 0.25, Currency: "£"}
+	now := time.Date(2021, 4, 9, 12, 0, 0, 0, time.UTC)
+	car := &Car{}
+	assert.Equal(t, "Nothing recorded in the last week.", statsMessage(car, "", now))
+	assert.Equal(t, "Usage: /stats [week|month]", statsMessage(car, "year", now))
+
+	at := now.Add(-48 * time.Hour)
+	car.recordDrive(CarState{At: at.AddDate(0, 0, -14), Odometer: 900, RatedBatteryRangeKm: 400}, CarState{At: at.AddDate(0, 0, -14), Odometer: 950, RatedBatteryRangeKm: 340}, "Home", "Shop", NoPrecipitation)
+	car.recordDrive(CarState{At: at, Odometer: 976, RatedBatteryRangeKm: 400}, CarState{At: at.Add(8 * time.Minute), Odometer: 986, RatedBatteryRangeKm: 390}, "Home", "Work", NoPrecipitation)
+	car.recordCharge(chargeRecord{at: at, geofence: "Home", energyAdded: 8, cost: 2})
+	assert.Equal(t, "📊 Last week\n🚗 1 drive, 6.2 miles\nEfficiency: 216Wh/mi\n🔌 1 charge, 8.0kWh\nCharging cost: ≈ £2.00", statsMessage(car, "week", now))
+	assert.Equal(t, "📊 Last month\n🚗 2 drives, 37.3 miles\nEfficiency: 251Wh/mi\n🔌 1 charge, 8.0kWh\nCharging cost: ≈ £2.00", statsMessage(car, "month", now))
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ChatSettings are preferences chosen by a chat.
+type ChatSettings struct {
+	Units          string   `json:"units"`
+	CarID          int      `json:"car_id"`
+	Notify         string   `json:"notify"`
+	StatusSchedule string   `json:"status_schedule,omitempty"`
+	Watch          []string `json:"watch,omitempty"` // fields to report changes to
+	QuietHours     string   `json:"quiet_hours,omitempty"`
+	QuietQueue     bool     `json:"quiet_queue,omitempty"` // hold notifications rather than send silently
+	Language       string   `json:"language,omitempty"`    // for /status replies, LANGUAGE if empty
+
+	// notification thresholds from /settings, MIN_* if unset
+	MinDriveDistance *float32 `json:"min_drive_distance,omitempty"` // in the chat's units
+	MinDriveMinutes  *int     `json:"min_drive_minutes,omitempty"`
+	MinChargeEnergy  *float32 `json:"min_charge_energy,omitempty"` // kWh
+}
+
+// Store is bridge state persisted as JSON to STATE_FILE.
+type Store struct {
+	path string
+
+	ChatID     int64                   `json:"chat_id"` // chosen during setup if TELEGRAM_CHAT_ID is unset
+	Chats      map[int64]*ChatSettings `json:"chats"`
+	Users      map[int]string          `json:"users"` // user ID to role
+	Pairings   map[string]*Pairing     `json:"pairings"`
+	Allowed    map[int64]bool          `json:"allowed"`    // user or chat IDs added with /allow
+	Bots       map[int64]string        `json:"bots"`       // chat ID to the bot it last wrote to
+	Features   map[string]bool         `json:"features"`   // toggles from /settings
+	Efficiency map[int]*EfficiencyFit  `json:"efficiency"` // rated km per kWh learned from charges
+	Battery    map[int][]BatterySample `json:"battery"`    // full range projected from charges
+	Jobs       map[string]time.Time    `json:"jobs"`       // last run of each scheduled job
+	CarEmoji   map[int]string          `json:"car_emoji"`  // set with /car set-emoji
+	Charges    map[int][]chargeRecord  `json:"charges"`    // each car's recorded charges
+	Drives     map[int][]driveRecord   `json:"drives"`     // and drives
+	Timeline   map[int][]timelineEntry `json:"timeline"`   // for /timeline
+	Visits     map[int][]visitRecord   `json:"visits"`     // for /places
+}
+
+func openStore(path string) (*Store, error) {
+	s := &Store{path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		s.init()
+		return s, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	s.init()
+	return s, nil
+}
+
+func (s *Store) init() {
+	if s.Chats == nil {
+		s.Chats = map[int64]*ChatSettings{}
+	}
+	if s.Users == nil {
+		s.Users = map[int]string{}
+	}
+	if s.Pairings == nil {
+		s.Pairings = map[string]*Pairing{}
+	}
+	if s.Allowed == nil {
+		s.Allowed = map[int64]bool{}
+	}
+	if s.Bots == nil {
+		s.Bots = map[int64]string{}
+	}
+	if s.Features == nil {
+		s.Features = map[string]bool{}
+	}
+	if s.Efficiency == nil {
+		s.Efficiency = map[int]*EfficiencyFit{}
+	}
+	if s.Battery == nil {
+		s.Battery = map[int][]BatterySample{}
+	}
+	if s.Jobs == nil {
+		s.Jobs = map[string]time.Time{}
+	}
+	if s.CarEmoji == nil {
+		s.CarEmoji = map[int]string{}
+	}
+	if s.Charges == nil {
+		s.Charges = map[int][]chargeRecord{}
+	}
+	if s.Drives == nil {
+		s.Drives = map[int][]driveRecord{}
+	}
+	if s.Timeline == nil {
+		s.Timeline = map[int][]timelineEntry{}
+	}
+	if s.Visits == nil {
+		s.Visits = map[int][]visitRecord{}
+	}
+}
+
+// Save writes the store atomically via a temporary file.
+func (s *Store) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), ".state")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// restoreHistory gives a car the history recorded before the bridge last
+// stopped, less any past SESSION_RETENTION_DAYS.
+func (s *Store) restoreHistory(car *Car, now time.Time) {
+	car.charges, car.drives = s.Charges[car.id], s.Drives[car.id]
+	car.timeline, car.visits = s.Timeline[car.id], s.Visits[car.id]
+	car.prune(now)
+}
+
+// keepHistory copies a car's history into the store to be saved.
+func (s *Store) keepHistory(car *Car) {
+	s.Charges[car.id], s.Drives[car.id] = car.charges, car.drives
+	s.Timeline[car.id], s.Visits[car.id] = car.timeline, car.visits
+	if len(car.charges) == 0 {
+		delete(s.Charges, car.id)
+	}
+	if len(car.drives) == 0 {
+		delete(s.Drives, car.id)
+	}
+	if len(car.timeline) == 0 {
+		delete(s.Timeline, car.id)
+	}
+	if len(car.visits) == 0 {
+		delete(s.Visits, car.id)
+	}
+}
+
+// storedCharge is chargeRecord as kept in the store.
+type storedCharge struct {
+	At          time.Time `json:"at"`
+	Until       time.Time `json:"until"`
+	Geofence    string    `json:"geofence,omitempty"`
+	Place       string    `json:"place"`
+	StartLevel  int       `json:"start_level"`
+	EndLevel    int       `json:"end_level"`
+	EnergyAdded float32   `json:"energy_added"`
+	Cost        float32   `json:"cost,omitempty"`
+}
+
+func (r chargeRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(storedCharge{
+		At: r.at, Until: r.until, Geofence: r.geofence, Place: r.place,
+		StartLevel: r.startLevel, EndLevel: r.endLevel, EnergyAdded: r.energyAdded, Cost: r.cost,
+	})
+}
+
+func (r *chargeRecord) UnmarshalJSON(data []byte) error {
+	var c storedCharge
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+	*r = chargeRecord{
+		at: c.At, until: c.Until, geofence: c.Geofence, place: c.Place,
+		startLevel: c.StartLevel, endLevel: c.EndLevel, energyAdded: c.EnergyAdded, cost: c.Cost,
+	}
+	return nil
+}
+
+// storedDrive is driveRecord as kept in the store.
+type storedDrive struct {
+	At            time.Time `json:"at"`
+	Until         time.Time `json:"until"`
+	From          string    `json:"from"`
+	To            string    `json:"to"`
+	DistanceKm    float32   `json:"distance_km"`
+	RatedKmUsed   float32   `json:"rated_km_used"`
+	OutsideTemp   float32   `json:"outside_temp"`
+	Precipitation float32   `json:"precipitation"`
+	MaxSpeedKmh   int       `json:"max_speed_kmh,omitempty"`
+}
+
+func (r driveRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(storedDrive{
+		At: r.at, Until: r.until, From: r.from, To: r.to, DistanceKm: r.distanceKm, RatedKmUsed: r.ratedKmUsed,
+		OutsideTemp: r.outsideTemp, Precipitation: r.precipitation, MaxSpeedKmh: r.maxSpeedKmh,
+	})
+}
+
+func (r *driveRecord) UnmarshalJSON(data []byte) error {
+	var d storedDrive
+	if err := json.Unmarshal(data, &d); err != nil {
+		return err
+	}
+	*r = driveRecord{
+		at: d.At, until: d.Until, from: d.From, to: d.To, distanceKm: d.DistanceKm, ratedKmUsed: d.RatedKmUsed,
+		outsideTemp: d.OutsideTemp, precipitation: d.Precipitation, maxSpeedKmh: d.MaxSpeedKmh,
+	}
+	return nil
+}
+
+// storedTimelineEntry is timelineEntry as kept in the store.
+type storedTimelineEntry struct {
+	At   time.Time `json:"at"`
+	Text string    `json:"text"`
+}
+
+func (e timelineEntry) MarshalJSON() ([]byte, error) {
+	return json.Marshal(storedTimelineEntry{At: e.at, Text: e.text})
+}
+
+func (e *timelineEntry) UnmarshalJSON(data []byte) error {
+	var t storedTimelineEntry
+	if err := json.Unmarshal(data, &t); err != nil {
+		return err
+	}
+	*e = timelineEntry{at: t.At, text: t.Text}
+	return nil
+}
+
+// storedVisit is visitRecord as kept in the store.
+type storedVisit struct {
+	At    time.Time `json:"at"`
+	Until time.Time `json:"until"`
+	Place string    `json:"place"`
+}
+
+func (r visitRecord) MarshalJSON() ([]byte, error) {
+	return json.Marshal(storedVisit{At: r.at, Until: r.until, Place: r.place})
+}
+
+func (r *visitRecord) UnmarshalJSON(data []byte) error {
+	var v storedVisit
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*r = visitRecord{at: v.At, until: v.Until, place: v.Place}
+	return nil
+}
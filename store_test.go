@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	s, err := openStore(path)
+	assert.NoError(t, err)
+	assert.Empty(t, s.Chats)
+	s.ChatID = 1234
+	s.Chats[1234] = &ChatSettings{Units: "metric", CarID: 1, Notify: "all"}
+	assert.NoError(t, s.Save())
+
+	s, err = openStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1234), s.ChatID)
+	assert.Equal(t, &ChatSettings{Units: "metric", CarID: 1, Notify: "all"}, s.Chats[1234])
+}
+
+func TestStoreHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "store")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state.json")
+
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	charge := chargeRecord{at: at, until: at.Add(90 * time.Minute), geofence: "Home", place: "Home", startLevel: 50, endLevel: 80, energyAdded: 8, cost: 2}
+	drive := driveRecord{at: at, until: at.Add(8 * time.Minute), from: "Home", to: "Work", distanceKm: 10, ratedKmUsed: 10, outsideTemp: 7.5, precipitation: NoPrecipitation, maxSpeedKmh: 64}
+	s, err := openStore(path)
+	assert.NoError(t, err)
+	s.Charges[1] = []chargeRecord{charge}
+	s.Drives[1] = []driveRecord{drive}
+	s.Timeline[1] = []timelineEntry{{at: at, text: "🅿️ Parked at Work"}}
+	s.Visits[1] = []visitRecord{{at: at, until: at.Add(time.Hour), place: "Work"}}
+	assert.NoError(t, s.Save())
+
+	s, err = openStore(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []chargeRecord{charge}, s.Charges[1])
+	assert.Equal(t, []driveRecord{drive}, s.Drives[1])
+	assert.Equal(t, []timelineEntry{{at: at, text: "🅿️ Parked at Work"}}, s.Timeline[1])
+	assert.Equal(t, []visitRecord{{at: at, until: at.Add(time.Hour), place: "Work"}}, s.Visits[1])
+}
+
+func TestRestoreHistory(t *testing.T) {
+	defer func(d time.Duration) { sessionRetention = d }(sessionRetention)
+	sessionRetention = 30 * 24 * time.Hour
+	now := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	old, recent := now.AddDate(0, 0, -40), now.AddDate(0, 0, -2)
+	s := &Store{}
+	s.init()
+	s.Charges[1] = []chargeRecord{{at: old}, {at: recent}}
+	s.Drives[1] = []driveRecord{{at: old}, {at: recent}}
+	s.Timeline[1] = []timelineEntry{{at: old}, {at: recent}}
+	s.Visits[1] = []visitRecord{{at: old}, {at: recent}}
+	car := &Car{id: 1}
+	s.restoreHistory(car, now)
+	assert.Equal(t, []chargeRecord{{at: recent}}, car.charges)
+	assert.Equal(t, []driveRecord{{at: recent}}, car.drives)
+	assert.Equal(t, []timelineEntry{{at: recent}}, car.timeline)
+	assert.Equal(t, []visitRecord{{at: recent}}, car.visits)
+
+	car.charges, car.timeline = nil, nil
+	s.keepHistory(car)
+	assert.NotContains(t, s.Charges, 1)
+	assert.NotContains(t, s.Timeline, 1)
+	assert.Equal(t, []driveRecord{{at: recent}}, s.Drives[1])
+	assert.Equal(t, []visitRecord{{at: recent}}, s.Visits[1])
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TariffBand is a time of use rate, e.g. off-peak overnight.
+type TariffBand struct {
+	Name       string
+	Start, End int // minutes since midnight, may wrap past midnight
+	Price      float32
+}
+
+// Tariff is the electricity price for charging at Home.
+type Tariff struct {
+	Price    float32 // per kWh outside the bands, 0 if unset
+	Currency string
+	Bands    []TariffBand
+	// Billing interval, e.g. 30 minutes, priced at the rate at its start.
+	// 0 prices each minute at its own rate.
+	Interval time.Duration
+}
+
+var tariff Tariff
+
+// parseTariffBands parses TARIFF_SCHEDULE, e.g.
+// "off-peak=00:30-04:30@0.075;day=10:00-16:00@0.2".
+func parseTariffBands(value string) ([]TariffBand, error) {
+	var bands []TariffBand
+	for _, entry := range strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == ' ' }) {
+		i, j := strings.Index(entry, "="), strings.LastIndex(entry, "@")
+		if i == -1 || j < i {
+			return nil, fmt.Errorf("expected name=HH:MM-HH:MM@price, got %q", entry)
+		}
+		start, end, err := parseQuietHours(entry[i+1 : j])
+		if err != nil {
+			return nil, err
+		}
+		price, err := strconv.ParseFloat(entry[j+1:], 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price %q", entry[j+1:])
+		}
+		bands = append(bands, TariffBand{Name: entry[:i], Start: start, End: end, Price: float32(price)})
+	}
+	return bands, nil
+}
+
+// band returns the name and price of the rate at a time. Outside the bands
+// it's peak, or unnamed for a flat rate.
+func (t Tariff) band(at time.Time) (string, float32) {
+	at = at.In(location)
+	minute := at.Hour()*60 + at.Minute()
+	for _, b := range t.Bands {
+		if minuteInRange(b.Start, b.End, minute) {
+			return b.Name, b.Price
+		}
+	}
+	if len(t.Bands) == 0 {
+		return "", t.Price
+	}
+	return "peak", t.Price
+}
+
+// rate returns the band that energy drawn at a time is billed at: the band
+// at the start of its billing interval, counted from local midnight.
+func (t Tariff) rate(at time.Time) (string, float32) {
+	if t.Interval > 0 {
+		local := at.In(location)
+		midnight := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+		at = midnight.Add(local.Sub(midnight) / t.Interval * t.Interval)
+	}
+	return t.band(at)
+}
+
+// BandCost is the energy charged and its cost in one tariff band.
+type BandCost struct {
+	Band   string
+	Energy float32 // kWh
+	Cost   float32
+}
+
+// bandTotals accumulates energy per band in order of first use.
+type bandTotals []BandCost
+
+func (b *bandTotals) add(name string, price, kWh float32) {
+	for i := range *b {
+		if (*b)[i].Band == name {
+			(*b)[i].Energy += kWh
+			(*b)[i].Cost += kWh * price
+			return
+		}
+	}
+	*b = append(*b, BandCost{name, kWh, kWh * price})
+}
+
+// Split shares the energy of a charge between the bands, assuming it was
+// drawn evenly from start to end. Bands are in order of first use.
+func (t Tariff) Split(start, end time.Time, kWh float32) []BandCost {
+	var costs bandTotals
+	t.spread(&costs, start, end, kWh)
+	return costs
+}
+
+// spread adds energy drawn evenly from start to end, minute by minute.
+func (t Tariff) spread(costs *bandTotals, start, end time.Time, kWh float32) {
+	minutes := int(end.Sub(start) / time.Minute)
+	if minutes < 1 {
+		minutes = 1
+	}
+	for i := 0; i < minutes; i++ {
+		name, price := t.rate(start.Add(time.Duration(i) * time.Minute))
+		costs.add(name, price, kWh/float32(minutes))
+	}
+}
+
+// energySample is the energy added so far in a charge at a time.
+type energySample struct {
+	at    time.Time
+	added float32 // kWh
+}
+
+// SplitSamples shares the energy of a charge between the bands using the
+// energy added between each sample, so it follows changes in power. With a
+// billing interval, energy between samples is spread over the intervals it
+// crosses.
+func (t Tariff) SplitSamples(samples []energySample) []BandCost {
+	var costs bandTotals
+	for i := 1; i < len(samples); i++ {
+		kWh := samples[i].added - samples[i-1].added
+		if t.Interval > 0 {
+			t.spread(&costs, samples[i-1].at, samples[i].at, kWh)
+			continue
+		}
+		name, price := t.band(samples[i-1].at)
+		costs.add(name, price, kWh)
+	}
+	return costs
+}
+
+// totalCost adds up the cost across bands.
+func totalCost(costs []BandCost) float32 {
+	var total float32
+	for _, c := range costs {
+		total += c.Cost
+	}
+	return total
+}
+
+// formatCost formats an amount in the tariff's currency, e.g. £2.45.
+func (t Tariff) formatCost(amount float32) string {
+	return fmt.Sprintf("%s%.2f", t.Currency, amount)
+}
+
+// costMessage estimates the cost of a charge, broken down by band when it
+// used more than one, or is empty without a price.
+func costMessage(t Tariff, costs []BandCost) string {
+	if totalCost(costs) == 0 {
+		return ""
+	}
+	if len(costs) == 1 {
+		return fmt.Sprintf("\n💷 %.1fkWh ≈ %s", costs[0].Energy, t.formatCost(costs[0].Cost))
+	}
+	var parts []string
+	for _, c := range costs {
+		parts = append(parts, fmt.Sprintf("%.1fkWh %s %s", c.Energy, c.Band, t.formatCost(c.Cost)))
+	}
+	return fmt.Sprintf("\n💷 %s ≈ %s", strings.Join(parts, " + "), t.formatCost(totalCost(costs)))
+}
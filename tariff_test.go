@@ -0,0 +1,89 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCostMessage(t *testing.T) {
+	flat := Tariff{Price: 0.25, Currency: "£"}
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	assert.Equal(t, "\n💷 9.8kWh ≈ £2.45", costMessage(flat, flat.Split(at, at.Add(time.Hour), 9.8)))
+	none := Tariff{Currency: "£"}
+	assert.Equal(t, "", costMessage(none, none.Split(at, at.Add(time.Hour), 9.8)))
+}
+
+func TestTariffConfig(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{"TARIFF_PRICE": "0.3", "TARIFF_CURRENCY": "€"}))
+	assert.NoError(t, err)
+	assert.Equal(t, Tariff{Price: 0.3, Currency: "€"}, c.Tariff)
+	c, err = parseConfig(lookupMap(map[string]string{}))
+	assert.NoError(t, err)
+	assert.Equal(t, Tariff{Currency: "£"}, c.Tariff)
+
+	c, err = parseConfig(lookupMap(map[string]string{"TARIFF_SCHEDULE": "off-peak=00:30-04:30@0.075"}))
+	assert.NoError(t, err)
+	assert.Equal(t, []TariffBand{{Name: "off-peak", Start: 30, End: 270, Price: 0.075}}, c.Tariff.Bands)
+	_, err = parseConfig(lookupMap(map[string]string{"TARIFF_SCHEDULE": "off-peak=00:30-04:30"}))
+	assert.EqualError(t, err, `invalid TARIFF_SCHEDULE: expected name=HH:MM-HH:MM@price, got "off-peak=00:30-04:30"`)
+	_, err = parseConfig(lookupMap(map[string]string{"TARIFF_SCHEDULE": "off-peak=00:30-04:30@cheap"}))
+	assert.EqualError(t, err, `invalid TARIFF_SCHEDULE: invalid price "cheap"`)
+}
+
+func TestTariffSplit(t *testing.T) {
+	tou := Tariff{Price: 0.3, Currency: "£", Bands: []TariffBand{{Name: "off-peak", Start: 30, End: 270, Price: 0.075}}}
+	// 3 hours off-peak then 1 hour peak
+	start := time.Date(2021, 4, 9, 1, 30, 0, 0, time.UTC)
+	costs := tou.Split(start, start.Add(4*time.Hour), 40)
+	assert.Equal(t, "off-peak", costs[0].Band)
+	assert.InDelta(t, 30, costs[0].Energy, 0.01)
+	assert.InDelta(t, 2.25, costs[0].Cost, 0.01)
+	assert.Equal(t, "peak", costs[1].Band)
+	assert.InDelta(t, 10, costs[1].Energy, 0.01)
+	assert.InDelta(t, 3, costs[1].Cost, 0.01)
+	assert.Equal(t, "\n💷 30.0kWh off-peak £2.25 + 10.0kWh peak £3.00 ≈ £5.25", costMessage(tou, costs))
+
+	overnight := tou.Split(start, start.Add(time.Hour), 8)
+	assert.Equal(t, "\n💷 8.0kWh ≈ £0.60", costMessage(tou, overnight))
+}
+
+func TestHandoverCost(t *testing.T) {
+	defer func(t Tariff) { tariff = t }(tariff)
+	tariff = Tariff{Price: 0.25, Currency: "£"}
+	car := &Car{displayName: "Snowflake", totals: Totals{acCharges: 2, chargeCost: 12.3}}
+	assert.Contains(t, handoverMessage(car), "Charges: 2 AC, 0 DC\nCharging cost: ≈ £12.30 at Home\n")
+}
+
+func TestTariffSplitSamples(t *testing.T) {
+	tou := Tariff{Price: 0.3, Currency: "£", Bands: []TariffBand{{Name: "off-peak", Start: 30, End: 270, Price: 0.075}}}
+	start := time.Date(2021, 4, 9, 1, 30, 0, 0, time.UTC)
+	// fast while off-peak, topping up slowly after 04:30
+	samples := []energySample{
+		{start, 0},
+		{start.Add(time.Hour), 14},
+		{start.Add(3 * time.Hour), 28.1},
+		{start.Add(4 * time.Hour), 31.3},
+	}
+	assert.Equal(t, "\n💷 28.1kWh off-peak £2.11 + 3.2kWh peak £0.96 ≈ £3.07", costMessage(tou, tou.SplitSamples(samples)))
+}
+
+func TestTariffInterval(t *testing.T) {
+	tou := Tariff{Price: 0.3, Currency: "£", Bands: []TariffBand{{Name: "off-peak", Start: 30, End: 270, Price: 0.075}}}
+	start := time.Date(2021, 4, 9, 4, 0, 0, 0, time.UTC)
+	samples := []energySample{{start, 0}, {start.Add(time.Hour), 12}}
+	assert.Equal(t, "\n💷 12.0kWh ≈ £0.90", costMessage(tou, tou.SplitSamples(samples)))
+	tou.Interval = 30 * time.Minute
+	assert.Equal(t, "\n💷 6.0kWh off-peak £0.45 + 6.0kWh peak £1.80 ≈ £2.25", costMessage(tou, tou.SplitSamples(samples)))
+
+	// billed hourly, 00:00-01:00 is all at the peak rate
+	tou.Interval = time.Hour
+	assert.Equal(t, "\n💷 8.0kWh ≈ £2.40", costMessage(tou, tou.Split(start.Add(-4*time.Hour), start.Add(-3*time.Hour), 8)))
+
+	c, err := parseConfig(lookupMap(map[string]string{"TARIFF_INTERVAL": "30m"}))
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Minute, c.Tariff.Interval)
+	_, err = parseConfig(lookupMap(map[string]string{"TARIFF_INTERVAL": "7m"}))
+	assert.EqualError(t, err, "invalid TARIFF_INTERVAL: 7m0s doesn't divide a day into whole minutes")
+}
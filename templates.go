@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Messages whose format can be replaced with a template, set by e.g.
+// DRIVE_FINISHED_TEMPLATE or read from the file named by
+// DRIVE_FINISHED_TEMPLATE_FILE.
+var templateNames = []string{"drive_finished", "charge_finished", "status"}
+
+var templateFuncs = template.FuncMap{
+	"miles":    func(km float32) float32 { return km / KMPerMile },
+	"distance": distance,
+	"unit":     distanceUnit,
+	"temp":     formatTemperature,
+	"place":    placeName,
+	"clock":    clock,
+	"duration": formatDuration,
+	"sub":      func(a, b time.Time) time.Duration { return a.Sub(b) },
+}
+
+// TemplateData is passed to message templates. Start and End are the car's
+// state at the start and end of the session, and Peak its state at the
+// highest charger power. For status messages all three are the current
+// state.
+type TemplateData struct {
+	Car   string
+	Start CarState
+	End   CarState
+	Peak  CarState
+}
+
+func parseTemplates(lookup func(string) (string, bool)) (map[string]*template.Template, error) {
+	templates := map[string]*template.Template{}
+	for _, name := range templateNames {
+		key := strings.ToUpper(name) + "_TEMPLATE"
+		text, ok := lookup(key)
+		if path, isFile := lookup(key + "_FILE"); isFile && !ok {
+			data, err := ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			text, ok = string(data), true
+		}
+		if !ok {
+			continue
+		}
+		t, err := template.New(name).Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", key, err)
+		}
+		templates[name] = t
+	}
+	return templates, nil
+}
+
+// renderTemplate formats a message with its template, returning fallback if
+// there is none or it fails.
+func renderTemplate(templates map[string]*template.Template, name string, data TemplateData, fallback string) string {
+	t, ok := templates[name]
+	if !ok {
+		return fallback
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		log.Printf("Template %s failed: %s", name, err)
+		return fallback
+	}
+	return strings.TrimSpace(buf.String())
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTemplates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "templates")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "status.tmpl")
+	ioutil.WriteFile(path, []byte("{{.Car}} {{.End.BatteryLevel}}%\n"), 0644)
+
+	_, err = parseTemplates(lookupMap(map[string]string{"DRIVE_FINISHED_TEMPLATE": "{{.End"}))
+	assert.EqualError(t, err, "invalid DRIVE_FINISHED_TEMPLATE: template: drive_finished:1: unclosed action")
+
+	templates, err := parseTemplates(lookupMap(map[string]string{
+		"DRIVE_FINISHED_TEMPLATE": "🚗 {{printf \"%.1f\" (miles .End.Odometer)}} miles in {{duration (sub .End.At .Start.At)}}, left at {{clock .Start.At}}",
+		"STATUS_TEMPLATE_FILE":    path,
+	}))
+	assert.NoError(t, err)
+	assert.Len(t, templates, 2)
+
+	at := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	data := TemplateData{Car: "Snowflake", Start: CarState{At: at}, End: CarState{At: at.Add(8 * time.Minute), Odometer: 16.1, BatteryLevel: 61}}
+	assert.Equal(t, "🚗 10.0 miles in 8m, left at 06:39", renderTemplate(templates, "drive_finished", data, "fallback"))
+	assert.Equal(t, "Snowflake 61%", renderTemplate(templates, "status", data, "fallback"))
+	assert.Equal(t, "fallback", renderTemplate(templates, "charge_finished", data, "fallback"))
+}
+
+func TestRenderTemplateError(t *testing.T) {
+	templates, err := parseTemplates(lookupMap(map[string]string{"STATUS_TEMPLATE": "{{.Missing}}"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "🔋61%", renderTemplate(templates, "status", TemplateData{}, "🔋61%"))
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// timelineEntry is something the car did, for /timeline.
+type timelineEntry struct {
+	at   time.Time
+	text string
+}
+
+func (car *Car) recordTimeline(at time.Time, text string) {
+	car.timeline = append(car.timeline, timelineEntry{at: at, text: text})
+	car.prune(at)
+}
+
+// recordStateChange notes the car waking up or going to sleep.
+func (car *Car) recordStateChange(from, to string, at time.Time) {
+	switch {
+	case to == "asleep":
+		car.recordTimeline(at, "😴 Went to sleep")
+	case to == "online" && (from == "asleep" || from == "offline" || from == "suspended"):
+		car.recordTimeline(at, "☀️ Woke up")
+	}
+}
+
+// parseDay parses the /timeline argument: empty for today, "yesterday" or a
+// date as YYYY-MM-DD.
+func parseDay(args string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch args {
+	case "", "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	return time.ParseInLocation("2006-01-02", args, now.Location())
+}
+
+// timelineMessage lists what the car did on the day starting at day, in
+// time order.
+func timelineMessage(car *Car, day time.Time) string {
+	if car == nil {
+		return "No car data received yet."
+	}
+	end := day.AddDate(0, 0, 1)
+	var entries []timelineEntry
+	for _, e := range car.timeline {
+		if !e.at.Before(day) && e.at.Before(end) {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) == 0 {
+		return fmt.Sprintf("Nothing recorded on %s.", day.Format("Mon 2 Jan"))
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].at.Before(entries[j].at) })
+	lines := []string{"🗓 " + day.Format("Mon 2 Jan")}
+	for _, e := range entries {
+		lines = append(lines, clock(e.at)+" "+e.text)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDay(t *testing.T) {
+	now := time.Date(2021, 4, 9, 18, 30, 0, 0, time.UTC)
+	day, err := parseDay("", now)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2021, 4, 9, 0, 0, 0, 0, time.UTC), day)
+	day, _ = parseDay("yesterday", now)
+	assert.Equal(t, time.Date(2021, 4, 8, 0, 0, 0, 0, time.UTC), day)
+	day, _ = parseDay("2021-03-01", now)
+	assert.Equal(t, time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC), day)
+	_, err = parseDay("last week", now)
+	assert.Error(t, err)
+}
+
+func TestTimelineMessage(t *testing.T) {
+	day := time.Date(2021, 4, 9, 0, 0, 0, 0, time.UTC)
+	car := &Car{}
+	car.recordStateChange("asleep", "online", day.Add(7*time.Hour))
+	car.recordTimeline(day.Add(8*time.Hour), "🅿️ Parked at Work")
+	car.recordTimeline(day.Add(7*time.Hour+10*time.Minute), "🚗 Drove Home→Work (6.2 miles)")
+	car.recordStateChange("online", "asleep", day.Add(9*time.Hour))
+	car.recordStateChange("online", "driving", day.Add(26*time.Hour))
+	assert.Equal(t, "🗓 Fri 9 Apr\n07:00 ☀️ Woke up\n07:10 🚗 Drove Home→Work (6.2 miles)\n08:00 🅿️ Parked at Work\n09:00 😴 Went to sleep", timelineMessage(car, day))
+	assert.Equal(t, "Nothing recorded on Sat 10 Apr.", timelineMessage(car, day.AddDate(0, 0, 1)))
+	assert.Equal(t, "No car data received yet.", timelineMessage(nil, day))
+}
@@ -0,0 +1,81 @@
+package main
+
+import "fmt"
+
+const (
+	UnitsImperial = "imperial"
+	UnitsMetric   = "metric"
+)
+
+var units = UnitsImperial
+
+const (
+	Celsius    = "C"
+	Fahrenheit = "F"
+)
+
+var temperatureUnit = Celsius
+
+// chatUnits is the units replies to a chat use: its own choice from
+// /settings or setup, or UNITS.
+func chatUnits(settings *ChatSettings, fallback string) string {
+	if settings != nil && (settings.Units == UnitsMetric || settings.Units == UnitsImperial) {
+		return settings.Units
+	}
+	return fallback
+}
+
+// toKm converts a distance in miles or km, as units are, to km.
+func toKm(d float32, in string) float32 {
+	if in == UnitsMetric {
+		return d
+	}
+	return d * KMPerMile
+}
+
+// distance converts km to the configured unit.
+func distance(km float32) float32 {
+	if units == UnitsMetric {
+		return km
+	}
+	return km / KMPerMile
+}
+
+// speed converts km/h to the configured unit.
+func speed(kmh float32) float32 {
+	return distance(kmh)
+}
+
+func speedUnit() string {
+	if units == UnitsMetric {
+		return "km/h"
+	}
+	return "mph"
+}
+
+func distanceUnit() string {
+	if units == UnitsMetric {
+		return tr("km")
+	}
+	return tr("miles")
+}
+
+func efficiencyUnit() string {
+	if units == UnitsMetric {
+		return "Wh/km"
+	}
+	return "Wh/mi"
+}
+
+// temperature converts °C to the configured unit.
+func temperature(c float32) float32 {
+	if temperatureUnit == Fahrenheit {
+		return c*9/5 + 32
+	}
+	return c
+}
+
+// formatTemperature formats a °C temperature in the configured unit.
+func formatTemperature(c float32) string {
+	return fmt.Sprintf("%.1f°%s", temperature(c), temperatureUnit)
+}
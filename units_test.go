@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricDriveMessage(t *testing.T) {
+	defer func(u string) { units = u }(units)
+	units = UnitsMetric
+	startAt := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	start := CarState{At: startAt, BatteryLevel: 50, Odometer: 976, OutsideTemp: 7.5, RatedBatteryRangeKm: 400, Geofence: "Home"}
+	end := CarState{At: startAt.Add(8 * time.Minute), BatteryLevel: 48, Odometer: 986, RatedBatteryRangeKm: 390, Geofence: "Work"}
+	assert.Equal(t, "🚗 Home->Work <code>10.0</code> km 🌡 7.5°C\n🕗 06:39→06:47 (8m)\n🔋 50→48% (-2%)\n🚘 400→390 km (10.0 km @ 134Wh/km)", finishDriveMessage(start, end, RatedKMPerKwh))
+	assert.Equal(t, "\n🔧 Rated range recalibrated +20.0 km (excluded)", calibrationMessage(20))
+}
+
+func TestUnitsConfig(t *testing.T) {
+	c, err := parseConfig(lookupMap(map[string]string{"UNITS": "metric"}))
+	assert.NoError(t, err)
+	assert.Equal(t, UnitsMetric, c.Units)
+	_, err = parseConfig(lookupMap(map[string]string{"UNITS": "furlongs"}))
+	assert.EqualError(t, err, "invalid UNITS: furlongs")
+}
+
+func TestFahrenheit(t *testing.T) {
+	defer func(u string) { temperatureUnit = u }(temperatureUnit)
+	temperatureUnit = Fahrenheit
+	assert.Equal(t, "98.6°F", formatTemperature(37))
+	car := &Car{}
+	car.State = CarState{InsideTemp: 40, OutsideTemp: 30}
+	_, text := car.checkVenting(35, 25)
+	assert.Equal(t, "🌡 Cabin is 104.0°F (outside 86.0°F). Consider venting the windows.", text)
+}
+
+func TestChatUnits(t *testing.T) {
+	assert.Equal(t, UnitsImperial, chatUnits(nil, UnitsImperial))
+	assert.Equal(t, UnitsMetric, chatUnits(&ChatSettings{Units: "metric"}, UnitsImperial))
+	assert.Equal(t, UnitsMetric, chatUnits(&ChatSettings{}, UnitsMetric))
+}
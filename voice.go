@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// synthesize renders text as speech with the TTS_URL engine. The text is
+// POSTed as text/plain and the response should be OGG/Opus audio, as
+// Telegram voice notes require.
+func synthesize(endpoint, text string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(endpoint, "text/plain; charset=utf-8", strings.NewReader(text))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tts: %s", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// spoken strips the HTML and emoji from a message so it reads aloud cleanly.
+func spoken(text string) string {
+	text = htmlTag.ReplaceAllString(text, "")
+	text = strings.ReplaceAll(text, "→", " to ")
+	text = strings.Map(func(r rune) rune {
+		if unicode.Is(unicode.So, r) || r == '\uFE0F' {
+			return -1
+		}
+		return r
+	}, text)
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if words := strings.Fields(line); len(words) > 0 {
+			lines = append(lines, strings.Join(words, " "))
+		}
+	}
+	return strings.Join(lines, ".\n")
+}
+
+// digestScript is the daily digest read out in the voice note: the car's
+// status and what it did yesterday.
+func digestScript(car *Car, status string, now time.Time) string {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return spoken(status + "\nYesterday\n" + timelineMessage(car, today.AddDate(0, 0, -1)))
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpoken(t *testing.T) {
+	assert.Equal(t, "Home->Work 6.2 miles.\n06:39 to 06:47 (8m)", spoken("🚗 Home->Work <code>6.2</code> miles\n\n🕗 06:39→06:47 (8m)"))
+	assert.Equal(t, "Settings", spoken("⚙️ Settings"))
+}
+
+func TestDigestScript(t *testing.T) {
+	now := time.Date(2021, 4, 10, 7, 0, 0, 0, time.UTC)
+	car := &Car{}
+	car.recordTimeline(now.Add(-20*time.Hour), "🅿️ Parked at Work")
+	assert.Equal(t, "61%.\nYesterday.\nFri 9 Apr.\n11:00 Parked at Work", digestScript(car, "🔋61%", now))
+}
+
+func TestSynthesize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		assert.Equal(t, "61%", string(body))
+		w.Write([]byte("OggS"))
+	}))
+	defer server.Close()
+	audio, err := synthesize(server.URL, "61%")
+	assert.NoError(t, err)
+	assert.Equal(t, "OggS", string(audio))
+}
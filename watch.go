@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/barnybug/teslamate-telegram/pkg/teslamatebridge"
+)
+
+// watchCommand adds or removes fields the chat is told about whenever they
+// change.
+func watchCommand(store *Store, chatID int64, args string) string {
+	settings, ok := store.Chats[chatID]
+	if !ok {
+		settings = &ChatSettings{}
+		store.Chats[chatID] = settings
+	}
+	fields := strings.Fields(args)
+	switch {
+	case len(fields) == 0:
+		if len(settings.Watch) == 0 {
+			return "Not watching anything. Watch a field with /watch <field>, one of: " + strings.Join(teslamatebridge.Fields(), ", ")
+		}
+		return "👀 Watching " + strings.Join(settings.Watch, ", ")
+	case len(fields) == 2 && fields[0] == "off":
+		for i, field := range settings.Watch {
+			if field == fields[1] {
+				settings.Watch = append(settings.Watch[:i], settings.Watch[i+1:]...)
+				return fmt.Sprintf("👀 Stopped watching %s", field)
+			}
+		}
+		return fmt.Sprintf("⚠️ Not watching %s", fields[1])
+	case len(fields) == 1:
+		if !validField(fields[0]) {
+			return fmt.Sprintf("⚠️ Unknown field %s", fields[0])
+		}
+		for _, field := range settings.Watch {
+			if field == fields[0] {
+				return fmt.Sprintf("👀 Already watching %s", field)
+			}
+		}
+		settings.Watch = append(settings.Watch, fields[0])
+		return fmt.Sprintf("👀 Watching %s", fields[0])
+	}
+	return "Usage: /watch [field] or /watch off <field>"
+}
+
+func validField(name string) bool {
+	for _, field := range teslamatebridge.Fields() {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// watchMessage describes the changes to the watched fields, or returns ""
+// if none of them changed.
+func watchMessage(car *Car, changes []teslamatebridge.Change, watch []string) string {
+	var lines []string
+	for _, change := range changes {
+		for _, field := range watch {
+			if change.Field == field {
+				lines = append(lines, fmt.Sprintf("%s: %s → %s", change.Field, change.Before, change.After))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("👀 %s\n%s", car.displayName, strings.Join(lines, "\n"))
+}
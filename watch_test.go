@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/barnybug/teslamate-telegram/pkg/teslamatebridge"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchCommand(t *testing.T) {
+	store := &Store{}
+	store.init()
+	assert.Contains(t, watchCommand(store, 1, ""), "Not watching anything")
+	assert.Equal(t, "👀 Watching charge_limit_soc", watchCommand(store, 1, "charge_limit_soc"))
+	assert.Equal(t, "👀 Already watching charge_limit_soc", watchCommand(store, 1, "charge_limit_soc"))
+	assert.Equal(t, "⚠️ Unknown field tyre_pressure", watchCommand(store, 1, "tyre_pressure"))
+	assert.Equal(t, "👀 Watching charge_limit_soc", watchCommand(store, 1, ""))
+	assert.Equal(t, "👀 Stopped watching charge_limit_soc", watchCommand(store, 1, "off charge_limit_soc"))
+	assert.Empty(t, store.Chats[1].Watch)
+}
+
+func TestWatchMessage(t *testing.T) {
+	car := &Car{displayName: "Snowflake"}
+	before := CarState{ChargeLimitSoc: 80, BatteryLevel: 60}
+	after := CarState{ChargeLimitSoc: 90, BatteryLevel: 61}
+	changes := teslamatebridge.Diff(before, after)
+	assert.Equal(t, "👀 Snowflake\ncharge_limit_soc: 80 → 90", watchMessage(car, changes, []string{"charge_limit_soc"}))
+	assert.Equal(t, "", watchMessage(car, changes, []string{"odometer"}))
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// How long each long poll for Telegram updates waits for one to arrive.
+const PollTimeout = 60
+
+// Without a successful poll for this long a bot's updates are stalled.
+const PollStall = 3 * PollTimeout * time.Second
+
+// Watchdog tracks when each bot last polled Telegram successfully, so a
+// dead update stream shows up in health checks instead of commands
+// silently going unanswered.
+type Watchdog struct {
+	mu       sync.Mutex
+	started  time.Time
+	last     map[string]time.Time
+	failures map[string]int
+}
+
+func newWatchdog(now time.Time) *Watchdog {
+	return &Watchdog{started: now, last: map[string]time.Time{}, failures: map[string]int{}}
+}
+
+// Polled records a successful poll, returning the failures it ended.
+func (w *Watchdog) Polled(bot string, at time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	failures := w.failures[bot]
+	w.last[bot], w.failures[bot] = at, 0
+	return failures
+}
+
+// Failed records a failed poll.
+func (w *Watchdog) Failed(bot string) {
+	w.mu.Lock()
+	w.failures[bot]++
+	w.mu.Unlock()
+}
+
+// Watch starts tracking a bot before its first poll.
+func (w *Watchdog) Watch(bot string) {
+	w.mu.Lock()
+	w.last[bot] = w.started
+	w.mu.Unlock()
+}
+
+// Stalled lists the bots without a successful poll within PollStall.
+func (w *Watchdog) Stalled(now time.Time) []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var stalled []string
+	for bot, last := range w.last {
+		if now.Sub(last) > PollStall {
+			stalled = append(stalled, bot)
+		}
+	}
+	sort.Strings(stalled)
+	return stalled
+}
+
+// Ages returns how long since each bot last polled successfully.
+func (w *Watchdog) Ages(now time.Time) map[string]time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	ages := map[string]time.Duration{}
+	for bot, last := range w.last {
+		ages[bot] = now.Sub(last)
+	}
+	return ages
+}
+
+// ServeHTTP answers health checks, failing while any bot's updates are
+// stalled.
+func (w *Watchdog) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if stalled := w.Stalled(time.Now()); len(stalled) > 0 {
+		rw.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(rw, "telegram updates stalled: %s\n", strings.Join(stalled, ", "))
+		return
+	}
+	fmt.Fprintln(rw, "ok")
+}
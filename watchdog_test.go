@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchdog(t *testing.T) {
+	start := time.Date(2021, 4, 9, 6, 39, 0, 0, time.UTC)
+	w := newWatchdog(start)
+	w.Watch("family_bot")
+	w.Watch("work_bot")
+	assert.Empty(t, w.Stalled(start.Add(time.Minute)))
+
+	w.Polled("family_bot", start.Add(2*time.Minute))
+	w.Failed("work_bot")
+	w.Failed("work_bot")
+	assert.Equal(t, []string{"work_bot"}, w.Stalled(start.Add(4*time.Minute)))
+	assert.Equal(t, 2, w.Polled("work_bot", start.Add(4*time.Minute)))
+	assert.Empty(t, w.Stalled(start.Add(4*time.Minute)))
+	assert.Equal(t, map[string]time.Duration{"family_bot": 3 * time.Minute, "work_bot": time.Minute}, w.Ages(start.Add(5*time.Minute)))
+}
+
+func TestHealthz(t *testing.T) {
+	w := newWatchdog(time.Now())
+	w.Watch("family_bot")
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, 200, rec.Code)
+
+	w = newWatchdog(time.Now().Add(-time.Hour))
+	w.Watch("family_bot")
+	rec = httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+	assert.Equal(t, 503, rec.Code)
+	assert.Equal(t, "telegram updates stalled: family_bot\n", rec.Body.String())
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+type weatherResponse struct {
+	Current struct {
+		Precipitation float32 `json:"precipitation"`
+	} `json:"current"`
+}
+
+// precipitationLookup returns the current precipitation in mm at a place
+// from Open-Meteo.
+func precipitationLookup(latitude, longitude float32) (float32, error) {
+	query := url.Values{}
+	query.Add("latitude", fmt.Sprint(latitude))
+	query.Add("longitude", fmt.Sprint(longitude))
+	query.Add("current", "precipitation")
+	// looked up on the main loop, so a stalled API mustn't hold it up
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get("https://api.open-meteo.com/v1/forecast?" + query.Encode())
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	var result weatherResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return 0, err
+	}
+	return result.Current.Precipitation, nil
+}
+
+// drivePrecipitation looks up the rain at the end of a drive, or returns
+// NoPrecipitation if weather is off or the location is unknown.
+func drivePrecipitation(end CarState) float32 {
+	if !features.Enabled("weather") || privacyMode == PrivacyStrict || (end.Latitude == 0 && end.Longitude == 0) {
+		return NoPrecipitation
+	}
+	mm, err := precipitationLookup(end.Latitude, end.Longitude)
+	if err != nil {
+		return NoPrecipitation
+	}
+	return mm
+}
+
+func weatherMessage(precipitation float32) string {
+	if precipitation <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("\n🌧 Raining (%.1fmm)", precipitation)
+}
+
+// rainEfficiencyMessage compares efficiency over the last 30 days' drives
+// in the rain with the dry ones, or is empty without both. Drives without a
+// weather lookup aren't counted.
+func rainEfficiencyMessage(car *Car, now time.Time) string {
+	since := now.AddDate(0, 0, -30)
+	var wet, dry []driveRecord
+	for _, d := range car.drives {
+		switch {
+		case d.at.Before(since) || d.distanceKm < 1 || d.precipitation == NoPrecipitation:
+		case d.precipitation > 0:
+			wet = append(wet, d)
+		default:
+			dry = append(dry, d)
+		}
+	}
+	if len(wet) == 0 || len(dry) == 0 {
+		return ""
+	}
+	kmPerKwh := car.kmPerKwh()
+	average := func(drives []driveRecord) float32 {
+		var distanceKm, ratedKmUsed float32
+		for _, d := range drives {
+			distanceKm += d.distanceKm
+			ratedKmUsed += d.ratedKmUsed
+		}
+		return ratedKmUsed / kmPerKwh * 1000 / distance(distanceKm)
+	}
+	return fmt.Sprintf("\n🌧 In the rain: %.0f%s over %s, dry %.0f%s", average(wet), efficiencyUnit(), plural(len(wet), "drive"), average(dry), efficiencyUnit())
+}
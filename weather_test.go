@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeatherMessage(t *testing.T) {
+	assert.Equal(t, "", weatherMessage(NoPrecipitation))
+	assert.Equal(t, "", weatherMessage(0))
+	assert.Equal(t, "\n🌧 Raining (1.2mm)", weatherMessage(1.2))
+}
+
+func TestRecordDriveWeather(t *testing.T) {
+	car := &Car{}
+	car.recordDrive(CarState{Odometer: 976, RatedBatteryRangeKm: 400, OutsideTemp: 4.5}, CarState{Odometer: 986, RatedBatteryRangeKm: 390}, "", "", 0.4)
+	assert.Equal(t, []driveRecord{{distanceKm: 10, ratedKmUsed: 10, outsideTemp: 4.5, precipitation: 0.4}}, car.drives)
+}
+
+func TestRainEfficiencyMessage(t *testing.T) {
+	now := time.Date(2021, 4, 9, 12, 0, 0, 0, time.UTC)
+	car := &Car{}
+	at := now.AddDate(0, 0, -2)
+	car.recordDrive(CarState{At: at, Odometer: 976, RatedBatteryRangeKm: 400}, CarState{At: at, Odometer: 986, RatedBatteryRangeKm: 390}, "Home", "Work", 0)
+	assert.Equal(t, "", rainEfficiencyMessage(car, now))
+	car.recordDrive(CarState{At: at, Odometer: 986, RatedBatteryRangeKm: 390}, CarState{At: at, Odometer: 996, RatedBatteryRangeKm: 378}, "Work", "Home", 2.5)
+	car.recordDrive(CarState{At: at, Odometer: 996, RatedBatteryRangeKm: 378}, CarState{At: at, Odometer: 1006, RatedBatteryRangeKm: 360}, "Home", "Shop", NoPrecipitation)
+	assert.Equal(t, "\n🌧 In the rain: 259Wh/mi over 1 drive, dry 216Wh/mi", rainEfficiencyMessage(car, now))
+}